@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a single record in the audit log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Character string    `json:"character,omitempty"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target,omitempty"`
+	Details   string    `json:"details,omitempty"`
+}
+
+// Matches returns true if the search text appears in any of the entry's fields
+func (self Entry) Matches(search string) bool {
+	search = strings.ToLower(search)
+
+	for _, field := range []string{self.User, self.Character, self.Action, self.Target, self.Details} {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var path = "audit.log"
+var mutex sync.Mutex
+
+// SetPath changes the file that the audit log is written to
+func SetPath(p string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	path = p
+}
+
+// Log appends an entry to the audit log. Entries are only ever added to the
+// end of the file, never modified.
+func Log(entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// Query returns up to limit of the most recent entries for which the filter
+// returns true, oldest first. A nil filter matches everything.
+func Query(filter func(Entry) bool, limit int) ([]Entry, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []Entry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+
+		if filter == nil || filter(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	return entries, scanner.Err()
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type AuditSuite struct {
+	dir string
+}
+
+var _ = Suite(&AuditSuite{})
+
+func (s *AuditSuite) SetUpTest(c *C) {
+	dir, err := ioutil.TempDir("", "audit")
+	c.Assert(err, IsNil)
+	s.dir = dir
+	SetPath(filepath.Join(dir, "audit.log"))
+}
+
+func (s *AuditSuite) TearDownTest(c *C) {
+	os.RemoveAll(s.dir)
+}
+
+func (s *AuditSuite) TestQuery(c *C) {
+	entries, err := Query(nil, 10)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 0)
+
+	c.Assert(Log(Entry{User: "Alice", Action: "zone", Target: "delete Town"}), IsNil)
+	c.Assert(Log(Entry{User: "Bob", Action: "cash", Target: "100"}), IsNil)
+	c.Assert(Log(Entry{User: "Alice", Action: "destroyroom"}), IsNil)
+
+	entries, err = Query(nil, 10)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Assert(entries[0].Action, Equals, "zone")
+	c.Assert(entries[0].Time.IsZero(), Equals, false)
+
+	entries, _ = Query(nil, 2)
+	c.Assert(entries, HasLen, 2)
+	c.Assert(entries[1].Action, Equals, "destroyroom")
+
+	entries, _ = Query(func(e Entry) bool { return e.User == "Alice" }, 10)
+	c.Assert(entries, HasLen, 2)
+
+	entries, _ = Query(func(e Entry) bool { return e.Matches("town") }, 10)
+	c.Assert(entries, HasLen, 1)
+	c.Assert(entries[0].User, Equals, "Alice")
+}
@@ -11,6 +11,12 @@ import (
 
 var combatInterval = 3 * time.Second
 
+// Fights can't start or carry on in safe rooms
+var isSafe = func(roomId types.Id) bool {
+	room := model.GetRoom(roomId)
+	return room != nil && room.HasFlag(types.RoomSafe)
+}
+
 var combatMessages chan interface{}
 
 type combatInfo struct {
@@ -73,7 +79,7 @@ func init() {
 				for a, info := range fights {
 					d := info.Defender
 
-					if a.GetRoomId() == d.GetRoomId() {
+					if a.GetRoomId() == d.GetRoomId() && !isSafe(a.GetRoomId()) {
 						power := 0
 						skill := info.Skill
 
@@ -93,13 +99,17 @@ func init() {
 						events.Broadcast(events.CombatEvent{Attacker: a, Defender: d, Skill: skill, Power: power})
 
 						if d.GetHitPoints() <= 0 {
-							Kill(d)
+							kill(d, a)
 						}
 					} else {
 						doCombatStop(a)
 					}
 				}
 			case combatStart:
+				if isSafe(m.Attacker.GetRoomId()) {
+					break
+				}
+
 				oldInfo, found := fights[m.Attacker]
 
 				if m.Defender == oldInfo.Defender {
@@ -141,8 +151,12 @@ func init() {
 }
 
 func Kill(char types.Character) {
+	kill(char, nil)
+}
+
+func kill(char types.Character, killer types.Character) {
 	clearCombat(char)
-	events.Broadcast(events.DeathEvent{Character: char})
+	events.Broadcast(events.DeathEvent{Character: char, Killer: killer})
 }
 
 func clearCombat(char types.Character) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/Cristofori/kmud/events"
 	"github.com/Cristofori/kmud/testutils"
+	"github.com/Cristofori/kmud/types"
 	. "gopkg.in/check.v1"
 )
 
@@ -15,8 +16,13 @@ type CombatSuite struct{}
 
 var _ = Suite(&CombatSuite{})
 
+var safeRooms = map[types.Id]bool{}
+
 func init() {
 	combatInterval = 10 * time.Millisecond
+	isSafe = func(roomId types.Id) bool {
+		return safeRooms[roomId]
+	}
 }
 
 func (s *CombatSuite) TestCombatLoop(c *C) {
@@ -90,3 +96,14 @@ func (s *CombatSuite) TestCombatLoop(c *C) {
 	<-eventChannel1
 	<-eventChannel2
 }
+
+func (s *CombatSuite) TestSafeRoom(c *C) {
+	char1 := testutils.NewMockPC()
+	char2 := testutils.NewMockPC()
+	char1.RoomId = char2.RoomId
+	safeRooms[char1.RoomId] = true
+
+	StartFight(char1, nil, char2)
+	c.Assert(InCombat(char1), Equals, false)
+	c.Assert(InCombat(char2), Equals, false)
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/utils"
+)
+
+type Channel struct {
+	DbObject `bson:",inline"`
+
+	Name      string
+	Color     types.Color
+	OwnerId   types.Id `bson:",omitempty"`
+	AdminOnly bool
+
+	Members  utils.Set
+	Muted    utils.Set
+	Silenced utils.Set
+	Banned   utils.Set
+
+	History []types.ChannelMessage
+}
+
+// The number of messages kept in each channel's scrollback
+const channelHistorySize = 50
+
+func NewChannel(name string, ownerId types.Id) *Channel {
+	channel := &Channel{
+		Name:    strings.ToLower(name),
+		Color:   types.ColorCyan,
+		OwnerId: ownerId,
+	}
+
+	dbinit(channel)
+	return channel
+}
+
+func (self *Channel) GetName() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Name
+}
+
+func (self *Channel) SetName(name string) {
+	self.writeLock(func() {
+		self.Name = strings.ToLower(name)
+	})
+}
+
+func (self *Channel) GetColor() types.Color {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Color
+}
+
+func (self *Channel) SetColor(color types.Color) {
+	self.writeLock(func() {
+		self.Color = color
+	})
+}
+
+// GetOwnerId returns the user who created the channel, or nil for the
+// built-in channels
+func (self *Channel) GetOwnerId() types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.OwnerId
+}
+
+func (self *Channel) IsAdminOnly() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.AdminOnly
+}
+
+func (self *Channel) SetAdminOnly(adminOnly bool) {
+	self.writeLock(func() {
+		self.AdminOnly = adminOnly
+	})
+}
+
+// Join adds the character to the channel, unless they've been banned from it
+func (self *Channel) Join(id types.Id) {
+	self.writeLock(func() {
+		if self.Banned.Contains(id.Hex()) {
+			return
+		}
+
+		if self.Members == nil {
+			self.Members = utils.Set{}
+		}
+		self.Members.Insert(id.Hex())
+	})
+}
+
+func (self *Channel) Leave(id types.Id) {
+	self.writeLock(func() {
+		self.Members.Remove(id.Hex())
+		self.Muted.Remove(id.Hex())
+	})
+}
+
+func (self *Channel) IsMember(id types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Members.Contains(id.Hex())
+}
+
+func (self *Channel) GetMembers() []types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return idSetToList(self.Members)
+}
+
+// SetMuted controls whether or not a member receives messages from the channel
+// while staying subscribed to it
+func (self *Channel) SetMuted(id types.Id, muted bool) {
+	self.writeLock(func() {
+		if self.Muted == nil {
+			self.Muted = utils.Set{}
+		}
+
+		if muted {
+			self.Muted.Insert(id.Hex())
+		} else {
+			self.Muted.Remove(id.Hex())
+		}
+	})
+}
+
+func (self *Channel) IsMuted(id types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Muted.Contains(id.Hex())
+}
+
+// SetSilenced controls whether or not a character is allowed to talk on the
+// channel
+func (self *Channel) SetSilenced(id types.Id, silenced bool) {
+	self.writeLock(func() {
+		if self.Silenced == nil {
+			self.Silenced = utils.Set{}
+		}
+
+		if silenced {
+			self.Silenced.Insert(id.Hex())
+		} else {
+			self.Silenced.Remove(id.Hex())
+		}
+	})
+}
+
+func (self *Channel) IsSilenced(id types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Silenced.Contains(id.Hex())
+}
+
+// SetBanned controls whether or not a character is allowed on the channel.
+// Banning a member also removes them from it.
+func (self *Channel) SetBanned(id types.Id, banned bool) {
+	self.writeLock(func() {
+		if self.Banned == nil {
+			self.Banned = utils.Set{}
+		}
+
+		if banned {
+			self.Banned.Insert(id.Hex())
+			self.Members.Remove(id.Hex())
+			self.Muted.Remove(id.Hex())
+		} else {
+			self.Banned.Remove(id.Hex())
+		}
+	})
+}
+
+func (self *Channel) IsBanned(id types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Banned.Contains(id.Hex())
+}
+
+func (self *Channel) AddHistory(from string, message string) {
+	self.writeLock(func() {
+		self.History = append(self.History, types.ChannelMessage{
+			From:    from,
+			Message: message,
+			Time:    time.Now(),
+		})
+
+		if len(self.History) > channelHistorySize {
+			self.History = self.History[len(self.History)-channelHistorySize:]
+		}
+	})
+}
+
+func (self *Channel) GetHistory() []types.ChannelMessage {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	history := make([]types.ChannelMessage, len(self.History))
+	copy(history, self.History)
+	return history
+}
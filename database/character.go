@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
@@ -15,8 +16,9 @@ type Character struct {
 	HitPoints int
 	Skills    utils.Set
 
-	Strength int
-	Vitality int
+	Strength   int
+	Vitality   int
+	Experience int
 }
 
 type Pc struct {
@@ -24,8 +26,15 @@ type Pc struct {
 
 	UserId types.Id
 	online bool
+
+	TellHistory    []types.HistoryEntry
+	SayHistory     []types.HistoryEntry
+	ChannelHistory []types.HistoryEntry
 }
 
+// The number of messages of each kind kept in a character's history
+const historySize = 100
+
 type Npc struct {
 	Character `bson:",inline"`
 
@@ -33,6 +42,7 @@ type Npc struct {
 
 	Roaming      bool
 	Conversation string
+	Script       string `bson:",omitempty"`
 }
 
 type Spawner struct {
@@ -40,6 +50,20 @@ type Spawner struct {
 
 	AreaId types.Id
 	Count  int
+	Loot   types.LootTable
+
+	Roaming      bool
+	Conversation string
+	Script       string `bson:",omitempty"`
+	Equipment    []types.Id
+
+	RespawnDelay int
+	RoomIds      utils.Set
+	MaxPerRoom   int
+	Schedule     types.SpawnSchedule
+	Paused       bool
+
+	deaths []time.Time
 }
 
 func NewPc(name string, userId types.Id, roomId types.Id) *Pc {
@@ -65,8 +89,9 @@ func NewNpc(name string, roomId types.Id, spawnerId types.Id) *Npc {
 
 func NewSpawner(name string, areaId types.Id) *Spawner {
 	spawner := &Spawner{
-		AreaId: areaId,
-		Count:  1,
+		AreaId:   areaId,
+		Count:    1,
+		Schedule: types.SpawnAlways,
 	}
 
 	spawner.initCharacter(name, types.SpawnerType, nil)
@@ -107,6 +132,24 @@ func (self *Character) GetStrength() int {
 	return self.Strength
 }
 
+func (self *Character) SetStrength(strength int) {
+	self.writeLock(func() {
+		self.Strength = strength
+	})
+}
+
+func (self *Character) GetExperience() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Experience
+}
+
+func (self *Character) AddExperience(amount int) {
+	self.writeLock(func() {
+		self.Experience += amount
+	})
+}
+
 func (self *Pc) SetOnline(online bool) {
 	self.WriteLock()
 	defer self.WriteUnlock()
@@ -131,6 +174,53 @@ func (self *Character) GetRoomId() types.Id {
 	return self.RoomId
 }
 
+func (self *Pc) history(kind types.HistoryKind) *[]types.HistoryEntry {
+	switch kind {
+	case types.HistoryTell:
+		return &self.TellHistory
+	case types.HistorySay:
+		return &self.SayHistory
+	case types.HistoryChannel:
+		return &self.ChannelHistory
+	}
+	panic("Unhandled history kind: " + string(kind))
+}
+
+// AddHistory records a message, dropping the oldest one of that kind once the
+// history is full
+func (self *Pc) AddHistory(kind types.HistoryKind, entry types.HistoryEntry) {
+	self.writeLock(func() {
+		history := self.history(kind)
+		*history = append(*history, entry)
+
+		if len(*history) > historySize {
+			*history = (*history)[len(*history)-historySize:]
+		}
+	})
+}
+
+func (self *Pc) GetHistory(kind types.HistoryKind) []types.HistoryEntry {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	history := self.history(kind)
+	entries := make([]types.HistoryEntry, len(*history))
+	copy(entries, *history)
+	return entries
+}
+
+// IsIgnoring returns true if the user who owns this character is ignoring the
+// user who owns the other character
+func (self *Pc) IsIgnoring(char types.Character) bool {
+	other, ok := char.(*Pc)
+	if !ok || other == self {
+		return false
+	}
+
+	user, ok := Retrieve(self.GetUserId(), types.UserType).(*User)
+	return ok && user.IsIgnoring(other.GetUserId())
+}
+
 func (self *Pc) SetUserId(id types.Id) {
 	self.writeLock(func() {
 		self.UserId = id
@@ -182,6 +272,18 @@ func (self *Npc) GetConversation() string {
 	return self.Conversation
 }
 
+func (self *Npc) SetScript(script string) {
+	self.writeLock(func() {
+		self.Script = script
+	})
+}
+
+func (self *Npc) GetScript() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Script
+}
+
 func (self *Npc) PrettyConversation() string {
 	conv := self.GetConversation()
 
@@ -232,6 +334,12 @@ func (self *Character) Heal(hitpoints int) {
 	self.SetHitPoints(self.GetHitPoints() + hitpoints)
 }
 
+func (self *Npc) GetSpawnerId() types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.SpawnerId
+}
+
 func (self *Npc) GetRoaming() bool {
 	self.ReadLock()
 	defer self.ReadUnlock()
@@ -261,3 +369,189 @@ func (self *Spawner) GetAreaId() types.Id {
 	defer self.ReadUnlock()
 	return self.AreaId
 }
+
+func (self *Spawner) GetLoot() types.LootTable {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	loot := self.Loot
+	loot.Drops = make([]types.LootDrop, len(self.Loot.Drops))
+	copy(loot.Drops, self.Loot.Drops)
+	return loot
+}
+
+func (self *Spawner) SetLoot(loot types.LootTable) {
+	self.writeLock(func() {
+		self.Loot = loot
+	})
+}
+
+func (self *Spawner) GetRoaming() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Roaming
+}
+
+func (self *Spawner) SetRoaming(roaming bool) {
+	self.writeLock(func() {
+		self.Roaming = roaming
+	})
+}
+
+func (self *Spawner) GetConversation() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Conversation
+}
+
+func (self *Spawner) SetConversation(conversation string) {
+	self.writeLock(func() {
+		self.Conversation = conversation
+	})
+}
+
+func (self *Spawner) GetScript() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Script
+}
+
+func (self *Spawner) SetScript(script string) {
+	self.writeLock(func() {
+		self.Script = script
+	})
+}
+
+func (self *Spawner) GetEquipment() []types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	equipment := make([]types.Id, len(self.Equipment))
+	copy(equipment, self.Equipment)
+	return equipment
+}
+
+func (self *Spawner) AddEquipment(templateId types.Id) {
+	self.writeLock(func() {
+		self.Equipment = append(self.Equipment, templateId)
+	})
+}
+
+func (self *Spawner) RemoveEquipment(templateId types.Id) {
+	self.writeLock(func() {
+		for i, id := range self.Equipment {
+			if id == templateId {
+				self.Equipment = append(self.Equipment[:i], self.Equipment[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// GetRespawnDelay returns the number of seconds to wait after one of the
+// spawner's NPCs dies before replacing it
+func (self *Spawner) GetRespawnDelay() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.RespawnDelay
+}
+
+func (self *Spawner) SetRespawnDelay(seconds int) {
+	self.writeLock(func() {
+		self.RespawnDelay = seconds
+	})
+}
+
+func (self *Spawner) GetRoomIds() []types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return idSetToList(self.RoomIds)
+}
+
+func (self *Spawner) AddRoom(roomId types.Id) {
+	self.writeLock(func() {
+		if self.RoomIds == nil {
+			self.RoomIds = utils.Set{}
+		}
+		self.RoomIds.Insert(roomId.Hex())
+	})
+}
+
+func (self *Spawner) RemoveRoom(roomId types.Id) {
+	self.writeLock(func() {
+		self.RoomIds.Remove(roomId.Hex())
+	})
+}
+
+func (self *Spawner) HasRoom(roomId types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.RoomIds.Contains(roomId.Hex())
+}
+
+func (self *Spawner) GetMaxPerRoom() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.MaxPerRoom
+}
+
+func (self *Spawner) SetMaxPerRoom(max int) {
+	self.writeLock(func() {
+		self.MaxPerRoom = max
+	})
+}
+
+func (self *Spawner) GetSchedule() types.SpawnSchedule {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.Schedule == "" {
+		return types.SpawnAlways
+	}
+	return self.Schedule
+}
+
+func (self *Spawner) SetSchedule(schedule types.SpawnSchedule) {
+	self.writeLock(func() {
+		self.Schedule = schedule
+	})
+}
+
+func (self *Spawner) IsPaused() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Paused
+}
+
+func (self *Spawner) SetPaused(paused bool) {
+	self.writeLock(func() {
+		self.Paused = paused
+	})
+}
+
+// RecordDeath notes that one of the spawner's NPCs has died, delaying its
+// replacement by the respawn delay
+func (self *Spawner) RecordDeath() {
+	self.WriteLock()
+	defer self.WriteUnlock()
+	self.deaths = append(self.deaths, time.Now())
+}
+
+// PendingRespawns returns the number of dead NPCs that are still waiting out
+// the respawn delay
+func (self *Spawner) PendingRespawns() int {
+	self.WriteLock()
+	defer self.WriteUnlock()
+
+	delay := time.Duration(self.RespawnDelay) * time.Second
+
+	pending := self.deaths[:0]
+	for _, death := range self.deaths {
+		if time.Since(death) < delay {
+			pending = append(pending, death)
+		}
+	}
+	self.deaths = pending
+
+	return len(self.deaths)
+}
@@ -20,11 +20,34 @@ func (self *Container) GetCash() int {
 }
 
 func (self *Container) AddCash(amount int) {
-	self.SetCash(self.GetCash() + amount)
+	self.writeLock(func() {
+		self.Cash += amount
+	})
+}
+
+// RemoveCash takes the given amount out of the container, returning false
+// without changing anything if there isn't enough cash available
+func (self *Container) RemoveCash(amount int) bool {
+	removed := false
+
+	self.writeLock(func() {
+		if self.Cash >= amount {
+			self.Cash -= amount
+			removed = true
+		}
+	})
+
+	return removed
+}
+
+// cashBalance and adjustCash are used by transactions, which already hold the
+// container's write lock
+func (self *Container) cashBalance() int {
+	return self.Cash
 }
 
-func (self *Container) RemoveCash(amount int) {
-	self.SetCash(self.GetCash() - amount)
+func (self *Container) adjustCash(amount int) {
+	self.Cash += amount
 }
 
 func (self *Container) GetCapacity() int {
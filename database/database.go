@@ -42,12 +42,14 @@ type Iterator interface {
 
 var modifiedObjects = map[types.Id]bool{}
 var modifiedObjectChannel chan types.Id
+var flushChannel chan chan bool
 
 var _session Session
 var _dbName string
 
 func init() {
 	modifiedObjectChannel = make(chan types.Id, 1)
+	flushChannel = make(chan chan bool)
 	watchModifiedObjects()
 }
 
@@ -85,11 +87,34 @@ func watchModifiedObjects() {
 				}
 				modifiedObjects = map[types.Id]bool{}
 				startTimeout()
+			case done := <-flushChannel:
+				for pending := true; pending; {
+					select {
+					case id := <-modifiedObjectChannel:
+						modifiedObjects[id] = true
+					default:
+						pending = false
+					}
+				}
+				for id := range modifiedObjects {
+					commitObject(id)
+				}
+				modifiedObjects = map[types.Id]bool{}
+				done <- true
 			}
 		}
 	}()
 }
 
+// Flush writes every modified object to the database before returning.
+// Changes are normally committed in the background, so anything that exits
+// right after making changes needs to call this first.
+func Flush() {
+	done := make(chan bool)
+	flushChannel <- done
+	<-done
+}
+
 func getCollection(collection types.ObjectType) Collection {
 	return _session.DB(_dbName).C(string(collection))
 }
@@ -136,6 +161,12 @@ func Retrieve(id types.Id, typ types.ObjectType) types.Object {
 		object = &Store{}
 	case types.WorldType:
 		object = &World{}
+	case types.ChannelType:
+		object = &Channel{}
+	case types.MailType:
+		object = &Mail{}
+	case types.SanctionType:
+		object = &Sanction{}
 	default:
 		panic(fmt.Sprintf("unrecognized object type: %v", typ))
 	}
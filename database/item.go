@@ -11,6 +11,10 @@ type Template struct {
 	Value    int
 	Weight   int
 	Capacity int
+	Category string
+	Extras   map[string]string
+	Script   string `bson:",omitempty"`
+	Light    bool   `bson:",omitempty"`
 }
 
 type Item struct {
@@ -29,6 +33,34 @@ func NewTemplate(name string) *Template {
 	return template
 }
 
+// SetExtraDescription adds a description of some detail of the template's
+// items which players can see by looking at the keyword
+func (self *Template) SetExtraDescription(keyword string, description string) {
+	self.writeLock(func() {
+		if self.Extras == nil {
+			self.Extras = map[string]string{}
+		}
+		self.Extras[utils.Simplify(keyword)] = description
+	})
+}
+
+func (self *Template) RemoveExtraDescription(keyword string) {
+	self.writeLock(func() {
+		delete(self.Extras, utils.Simplify(keyword))
+	})
+}
+
+func (self *Template) GetExtraDescriptions() map[string]string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	extras := map[string]string{}
+	for keyword, description := range self.Extras {
+		extras[keyword] = description
+	}
+	return extras
+}
+
 func NewItem(templateId types.Id) *Item {
 	item := &Item{
 		TemplateId: templateId,
@@ -88,6 +120,43 @@ func (self *Template) SetCapacity(capacity int) {
 	})
 }
 
+func (self *Template) GetCategory() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Category
+}
+
+func (self *Template) GetScript() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Script
+}
+
+func (self *Template) SetScript(script string) {
+	self.writeLock(func() {
+		self.Script = script
+	})
+}
+
+func (self *Template) SetCategory(category string) {
+	self.writeLock(func() {
+		self.Category = utils.FormatName(category)
+	})
+}
+
+// IsLight returns true if the template's items light up dark rooms
+func (self *Template) IsLight() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Light
+}
+
+func (self *Template) SetLight(light bool) {
+	self.writeLock(func() {
+		self.Light = light
+	})
+}
+
 // Item
 
 func (self *Item) GetTemplateId() types.Id {
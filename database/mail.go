@@ -0,0 +1,81 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Cristofori/kmud/types"
+)
+
+type Mail struct {
+	DbObject `bson:",inline"`
+
+	FromName string
+	ToUserId types.Id
+	ToName   string
+	Subject  string
+	Body     string
+	Sent     time.Time
+	Read     bool
+}
+
+func NewMail(fromName string, toUserId types.Id, toName string, subject string, body string) *Mail {
+	mail := &Mail{
+		FromName: fromName,
+		ToUserId: toUserId,
+		ToName:   toName,
+		Subject:  subject,
+		Body:     body,
+		Sent:     time.Now(),
+	}
+
+	dbinit(mail)
+	return mail
+}
+
+func (self *Mail) GetFromName() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.FromName
+}
+
+func (self *Mail) GetToUserId() types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.ToUserId
+}
+
+func (self *Mail) GetToName() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.ToName
+}
+
+func (self *Mail) GetSubject() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Subject
+}
+
+func (self *Mail) GetBody() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Body
+}
+
+func (self *Mail) GetSent() time.Time {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Sent
+}
+
+func (self *Mail) IsRead() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Read
+}
+
+func (self *Mail) MarkRead() {
+	self.writeLock(func() {
+		self.Read = true
+	})
+}
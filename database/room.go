@@ -1,20 +1,36 @@
 package database
 
-import "github.com/Cristofori/kmud/types"
+import (
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/utils"
+)
 
 type Exit struct {
-	Locked bool
+	Locked     bool
+	Closed     bool     `bson:",omitempty"`
+	Door       string   `bson:",omitempty"`
+	KeyId      types.Id `bson:",omitempty"`
+	Difficulty int      `bson:",omitempty"`
+
+	Hidden      bool                  `bson:",omitempty"`
+	Restriction types.ExitRestriction `bson:",omitempty"`
 }
 
 type Room struct {
 	Container `bson:",inline"`
 
-	ZoneId      types.Id
-	AreaId      types.Id `bson:",omitempty"`
-	Title       string
-	Description string
-	Links       map[string]types.Id
-	Location    types.Coordinate
+	ZoneId           types.Id
+	AreaId           types.Id `bson:",omitempty"`
+	Title            string
+	Description      string
+	NightDescription string `bson:",omitempty"`
+	Extras           map[string]string
+	Actions          []types.RoomAction `bson:",omitempty"`
+	Script           string             `bson:",omitempty"`
+	Flags            []types.RoomFlag   `bson:",omitempty"`
+	Terrain          types.Terrain      `bson:",omitempty"`
+	Links            map[string]types.Id
+	Location         types.Coordinate
 
 	Exits map[types.Direction]*Exit
 }
@@ -86,6 +102,77 @@ func (self *Room) LinkNames() []string {
 	return names
 }
 
+// SetExtraDescription adds a description of some detail of the room which
+// players can see by looking at the keyword
+func (self *Room) SetExtraDescription(keyword string, description string) {
+	self.writeLock(func() {
+		if self.Extras == nil {
+			self.Extras = map[string]string{}
+		}
+		self.Extras[utils.Simplify(keyword)] = description
+	})
+}
+
+func (self *Room) RemoveExtraDescription(keyword string) {
+	self.writeLock(func() {
+		delete(self.Extras, utils.Simplify(keyword))
+	})
+}
+
+func (self *Room) GetExtraDescriptions() map[string]string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	extras := map[string]string{}
+	for keyword, description := range self.Extras {
+		extras[keyword] = description
+	}
+	return extras
+}
+
+func (self *Room) GetActions() []types.RoomAction {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	actions := make([]types.RoomAction, len(self.Actions))
+	copy(actions, self.Actions)
+	return actions
+}
+
+func (self *Room) AddAction(action types.RoomAction) {
+	self.writeLock(func() {
+		self.Actions = append(self.Actions, action)
+	})
+}
+
+func (self *Room) SetAction(index int, action types.RoomAction) {
+	self.writeLock(func() {
+		if index >= 0 && index < len(self.Actions) {
+			self.Actions[index] = action
+		}
+	})
+}
+
+func (self *Room) RemoveAction(index int) {
+	self.writeLock(func() {
+		if index >= 0 && index < len(self.Actions) {
+			self.Actions = append(self.Actions[:index], self.Actions[index+1:]...)
+		}
+	})
+}
+
+func (self *Room) GetScript() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Script
+}
+
+func (self *Room) SetScript(script string) {
+	self.writeLock(func() {
+		self.Script = script
+	})
+}
+
 func (self *Room) SetTitle(title string) {
 	self.writeLock(func() {
 		self.Title = title
@@ -110,6 +197,20 @@ func (self *Room) GetDescription() string {
 	return self.Description
 }
 
+// SetNightDescription sets what the room looks like at night. Rooms without
+// one look the same all day.
+func (self *Room) SetNightDescription(description string) {
+	self.writeLock(func() {
+		self.NightDescription = description
+	})
+}
+
+func (self *Room) GetNightDescription() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.NightDescription
+}
+
 func (self *Room) SetLocation(location types.Coordinate) {
 	self.writeLock(func() {
 		self.Location = location
@@ -166,10 +267,15 @@ func (self *Room) GetExits() []types.Direction {
 	return exits
 }
 
+// SetLocked locks or unlocks the exit's door, closing it first. Exits without
+// a door can't be locked.
 func (self *Room) SetLocked(dir types.Direction, locked bool) {
 	self.writeLock(func() {
-		if self.HasExit(dir) {
-			self.Exits[dir].Locked = locked
+		if exit, found := self.Exits[dir]; found {
+			exit.Locked = locked && exit.Door != ""
+			if exit.Locked {
+				exit.Closed = true
+			}
 		}
 	})
 }
@@ -178,9 +284,154 @@ func (self *Room) IsLocked(dir types.Direction) bool {
 	self.ReadLock()
 	defer self.ReadUnlock()
 
-	if self.HasExit(dir) {
-		return self.Exits[dir].Locked
+	if exit, found := self.Exits[dir]; found {
+		return exit.Locked
 	}
 
 	return false
 }
+
+// SetDoor puts a door in the exit, or changes the one that's there. The door
+// starts out open.
+func (self *Room) SetDoor(dir types.Direction, door types.Door) {
+	self.writeLock(func() {
+		if exit, found := self.Exits[dir]; found {
+			exit.Door = door.Name
+			exit.KeyId = door.KeyId
+			exit.Difficulty = door.Difficulty
+		}
+	})
+}
+
+// RemoveDoor takes the door out of the exit, leaving it open and unlocked
+func (self *Room) RemoveDoor(dir types.Direction) {
+	self.writeLock(func() {
+		if exit, found := self.Exits[dir]; found {
+			exit.Door = ""
+			exit.KeyId = nil
+			exit.Difficulty = 0
+			exit.Closed = false
+			exit.Locked = false
+		}
+	})
+}
+
+func (self *Room) GetDoor(dir types.Direction) (types.Door, bool) {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	exit, found := self.Exits[dir]
+	if !found || exit.Door == "" {
+		return types.Door{}, false
+	}
+
+	return types.Door{Name: exit.Door, KeyId: exit.KeyId, Difficulty: exit.Difficulty}, true
+}
+
+// SetClosed opens or closes the door in the exit. Exits without a door can't
+// be closed.
+func (self *Room) SetClosed(dir types.Direction, closed bool) {
+	self.writeLock(func() {
+		if exit, found := self.Exits[dir]; found && exit.Door != "" {
+			exit.Closed = closed
+		}
+	})
+}
+
+func (self *Room) IsClosed(dir types.Direction) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if exit, found := self.Exits[dir]; found {
+		return exit.Closed
+	}
+
+	return false
+}
+
+// SetHidden hides the exit from characters who haven't searched for it
+func (self *Room) SetHidden(dir types.Direction, hidden bool) {
+	self.writeLock(func() {
+		if exit, found := self.Exits[dir]; found {
+			exit.Hidden = hidden
+		}
+	})
+}
+
+func (self *Room) IsHidden(dir types.Direction) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if exit, found := self.Exits[dir]; found {
+		return exit.Hidden
+	}
+	return false
+}
+
+func (self *Room) SetRestriction(dir types.Direction, restriction types.ExitRestriction) {
+	self.writeLock(func() {
+		if exit, found := self.Exits[dir]; found {
+			exit.Restriction = restriction
+		}
+	})
+}
+
+func (self *Room) GetRestriction(dir types.Direction) types.ExitRestriction {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if exit, found := self.Exits[dir]; found {
+		return exit.Restriction
+	}
+	return types.ExitRestriction{}
+}
+
+func (self *Room) SetFlag(flag types.RoomFlag, on bool) {
+	self.writeLock(func() {
+		for i, f := range self.Flags {
+			if f == flag {
+				if !on {
+					self.Flags = append(self.Flags[:i], self.Flags[i+1:]...)
+				}
+				return
+			}
+		}
+
+		if on {
+			self.Flags = append(self.Flags, flag)
+		}
+	})
+}
+
+func (self *Room) HasFlag(flag types.RoomFlag) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	for _, f := range self.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Room) GetFlags() []types.RoomFlag {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	flags := make([]types.RoomFlag, len(self.Flags))
+	copy(flags, self.Flags)
+	return flags
+}
+
+func (self *Room) SetTerrain(terrain types.Terrain) {
+	self.writeLock(func() {
+		self.Terrain = terrain
+	})
+}
+
+func (self *Room) GetTerrain() types.Terrain {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Terrain
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"time"
+
+	"github.com/Cristofori/kmud/types"
+)
+
+type Sanction struct {
+	DbObject `bson:",inline"`
+
+	Kind    types.SanctionKind
+	UserId  types.Id `bson:",omitempty"`
+	Address string
+	Reason  string
+	Issuer  string
+	Issued  time.Time
+	Expires time.Time
+}
+
+// NewSanction creates a sanction against either a user or, for IP bans, an
+// address. A duration of zero never expires.
+func NewSanction(kind types.SanctionKind, userId types.Id, address string, reason string, issuer string, duration time.Duration) *Sanction {
+	sanction := &Sanction{
+		Kind:    kind,
+		UserId:  userId,
+		Address: address,
+		Reason:  reason,
+		Issuer:  issuer,
+		Issued:  time.Now(),
+	}
+
+	if duration > 0 {
+		sanction.Expires = sanction.Issued.Add(duration)
+	}
+
+	dbinit(sanction)
+	return sanction
+}
+
+func (self *Sanction) GetKind() types.SanctionKind {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Kind
+}
+
+func (self *Sanction) GetUserId() types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.UserId
+}
+
+func (self *Sanction) GetAddress() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Address
+}
+
+func (self *Sanction) GetReason() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Reason
+}
+
+func (self *Sanction) GetIssuer() string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Issuer
+}
+
+func (self *Sanction) GetIssued() time.Time {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Issued
+}
+
+func (self *Sanction) GetExpires() time.Time {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Expires
+}
+
+func (self *Sanction) IsExpired() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return !self.Expires.IsZero() && time.Now().After(self.Expires)
+}
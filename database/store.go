@@ -1,6 +1,9 @@
 package database
 
 import (
+	"sort"
+	"time"
+
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
 )
@@ -10,12 +13,32 @@ type Store struct {
 
 	Name   string
 	RoomId types.Id
+
+	Stock      []types.StockEntry
+	BuyRate    int
+	SellRate   int
+	SpendLimit int
+	Categories utils.Set
+
+	Spent      int
+	SpentSince time.Time
 }
 
+const (
+	DefaultBuyRate  = 50
+	DefaultSellRate = 100
+)
+
+// Spending is tracked over a rolling window so that a store which has hit
+// its spending limit will start buying again later on
+const spendingWindow = time.Hour
+
 func NewStore(name string, roomId types.Id) *Store {
 	store := &Store{
-		Name:   utils.FormatName(name),
-		RoomId: roomId,
+		Name:     utils.FormatName(name),
+		RoomId:   roomId,
+		BuyRate:  DefaultBuyRate,
+		SellRate: DefaultSellRate,
 	}
 
 	dbinit(store)
@@ -34,3 +57,180 @@ func (self *Store) SetName(name string) {
 		self.Name = utils.FormatName(name)
 	})
 }
+
+func (self *Store) GetStock() []types.StockEntry {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	stock := make([]types.StockEntry, len(self.Stock))
+	copy(stock, self.Stock)
+	return stock
+}
+
+// SetStock adds the given template to the store's stock list, or updates the
+// quantity and restock interval (in minutes) if it's already there
+func (self *Store) SetStock(templateId types.Id, quantity int, interval int) {
+	self.writeLock(func() {
+		for i, entry := range self.Stock {
+			if entry.TemplateId == templateId {
+				self.Stock[i].Quantity = quantity
+				self.Stock[i].Interval = interval
+				return
+			}
+		}
+
+		self.Stock = append(self.Stock, types.StockEntry{
+			TemplateId: templateId,
+			Quantity:   quantity,
+			Interval:   interval,
+		})
+	})
+}
+
+func (self *Store) RemoveStock(templateId types.Id) {
+	self.writeLock(func() {
+		for i, entry := range self.Stock {
+			if entry.TemplateId == templateId {
+				self.Stock = append(self.Stock[:i], self.Stock[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+func (self *Store) SetRestocked(templateId types.Id, when time.Time) {
+	self.writeLock(func() {
+		for i, entry := range self.Stock {
+			if entry.TemplateId == templateId {
+				self.Stock[i].LastRestock = when
+				return
+			}
+		}
+	})
+}
+
+// GetBuyRate returns the percentage of an item's value the store pays for it.
+// Stores saved before rates existed have none, and use the default.
+func (self *Store) GetBuyRate() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.BuyRate == 0 {
+		return DefaultBuyRate
+	}
+	return self.BuyRate
+}
+
+func (self *Store) SetBuyRate(rate int) {
+	self.writeLock(func() {
+		self.BuyRate = rate
+	})
+}
+
+// GetSellRate returns the percentage of an item's value the store charges
+// for it. Stores saved before rates existed have none, and use the default.
+func (self *Store) GetSellRate() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.SellRate == 0 {
+		return DefaultSellRate
+	}
+	return self.SellRate
+}
+
+func (self *Store) SetSellRate(rate int) {
+	self.writeLock(func() {
+		self.SellRate = rate
+	})
+}
+
+// BuyPrice is what the store will pay for an item of the given value
+func (self *Store) BuyPrice(value int) int {
+	return value * self.GetBuyRate() / 100
+}
+
+// SellPrice is what the store charges for an item of the given value
+func (self *Store) SellPrice(value int) int {
+	return value * self.GetSellRate() / 100
+}
+
+func (self *Store) GetSpendLimit() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.SpendLimit
+}
+
+// SetSpendLimit caps how much cash the store will spend buying items in an
+// hour, a limit of zero means there is no cap
+func (self *Store) SetSpendLimit(limit int) {
+	self.writeLock(func() {
+		self.SpendLimit = limit
+	})
+}
+
+func (self *Store) CanSpend(amount int) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.SpendLimit == 0 {
+		return true
+	}
+
+	spent := self.Spent
+	if time.Since(self.SpentSince) > spendingWindow {
+		spent = 0
+	}
+
+	return spent+amount <= self.SpendLimit
+}
+
+func (self *Store) RecordSpending(amount int) {
+	self.writeLock(func() {
+		if time.Since(self.SpentSince) > spendingWindow {
+			self.Spent = 0
+			self.SpentSince = time.Now()
+		}
+		self.Spent += amount
+	})
+}
+
+func (self *Store) AddCategory(category string) {
+	self.writeLock(func() {
+		if self.Categories == nil {
+			self.Categories = utils.Set{}
+		}
+		self.Categories.Insert(utils.FormatName(category))
+	})
+}
+
+func (self *Store) RemoveCategory(category string) {
+	self.writeLock(func() {
+		self.Categories.Remove(utils.FormatName(category))
+	})
+}
+
+func (self *Store) GetCategories() []string {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	categories := []string{}
+	for category := range self.Categories {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// AcceptsCategory returns whether the store will buy items of the given
+// category. Stores without any categories will buy anything.
+func (self *Store) AcceptsCategory(category string) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if len(self.Categories) == 0 {
+		return true
+	}
+
+	return self.Categories.Contains(utils.FormatName(category))
+}
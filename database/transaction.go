@@ -0,0 +1,177 @@
+package database
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/Cristofori/kmud/types"
+)
+
+type lockable interface {
+	types.Object
+	WriteLock()
+	WriteUnlock()
+}
+
+type cashHolder interface {
+	lockable
+	cashBalance() int
+	adjustCash(int)
+}
+
+type cashTransfer struct {
+	from   cashHolder
+	to     cashHolder
+	amount int
+}
+
+type itemMove struct {
+	item *Item
+	from types.Id
+	to   types.Id
+}
+
+// Transaction groups together cash and item transfers so that either all of
+// them are applied or none of them are. Every object involved is locked for
+// the duration of the commit, and the changes are written to the database
+// together once they've been applied.
+type Transaction struct {
+	transfers []cashTransfer
+	moves     []itemMove
+	err       error
+}
+
+func NewTransaction() *Transaction {
+	return &Transaction{}
+}
+
+func (self *Transaction) TransferCash(from types.Identifiable, to types.Identifiable, amount int) {
+	fromHolder, ok1 := from.(cashHolder)
+	toHolder, ok2 := to.(cashHolder)
+
+	if !ok1 || !ok2 {
+		self.err = errors.New("Cash can't be transferred to or from that")
+		return
+	}
+
+	if amount < 0 {
+		self.err = errors.New("Can't transfer a negative amount")
+		return
+	}
+
+	self.transfers = append(self.transfers, cashTransfer{from: fromHolder, to: toHolder, amount: amount})
+}
+
+func (self *Transaction) MoveItem(item types.Item, from types.Id, to types.Id) {
+	dbItem, ok := item.(*Item)
+
+	if !ok {
+		self.err = errors.New("That item can't be moved")
+		return
+	}
+
+	self.moves = append(self.moves, itemMove{item: dbItem, from: from, to: to})
+}
+
+func (self *Transaction) Commit() error {
+	if self.err != nil {
+		return self.err
+	}
+
+	objects := self.objects()
+
+	// Always lock in the same order to avoid deadlocking against other
+	// transactions that involve the same objects
+	for _, object := range objects {
+		object.WriteLock()
+	}
+
+	err := self.apply()
+
+	for i := len(objects) - 1; i >= 0; i-- {
+		objects[i].WriteUnlock()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, object := range objects {
+		commitObject(object.GetId())
+	}
+
+	return nil
+}
+
+func (self *Transaction) apply() error {
+	balances := map[cashHolder]int{}
+	for _, transfer := range self.transfers {
+		balances[transfer.from] -= transfer.amount
+		balances[transfer.to] += transfer.amount
+	}
+
+	for holder, change := range balances {
+		if holder.cashBalance()+change < 0 {
+			return errors.New("Not enough cash to complete the transaction")
+		}
+	}
+
+	locations := map[*Item]types.Id{}
+	for _, move := range self.moves {
+		current, found := locations[move.item]
+		if !found {
+			current = move.item.ContainerId
+		}
+
+		if current != move.from {
+			return errors.New("Item is no longer available")
+		}
+
+		locations[move.item] = move.to
+	}
+
+	for holder, change := range balances {
+		holder.adjustCash(change)
+	}
+
+	for item, location := range locations {
+		item.ContainerId = location
+	}
+
+	return nil
+}
+
+func (self *Transaction) objects() []lockable {
+	unique := map[types.Id]lockable{}
+
+	for _, transfer := range self.transfers {
+		unique[transfer.from.GetId()] = transfer.from
+		unique[transfer.to.GetId()] = transfer.to
+	}
+
+	for _, move := range self.moves {
+		unique[move.item.GetId()] = move.item
+	}
+
+	objects := make([]lockable, 0, len(unique))
+	for _, object := range unique {
+		objects = append(objects, object)
+	}
+
+	sort.Sort(byId(objects))
+	return objects
+}
+
+type byId []lockable
+
+func (self byId) Len() int {
+	return len(self)
+}
+
+func (self byId) Less(i, j int) bool {
+	return self[i].GetId().Hex() < self[j].GetId().Hex()
+}
+
+func (self byId) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
@@ -18,6 +18,11 @@ type User struct {
 	ColorMode types.ColorMode
 	Password  []byte
 	Admin     bool
+	Role      types.Role
+	Ignored   utils.Set
+
+	BuilderZones utils.Set
+	WrapWidth    int
 
 	online       bool
 	conn         net.Conn
@@ -32,11 +37,16 @@ func NewUser(name string, password string, admin bool) *User {
 		Password:     hash(password),
 		ColorMode:    types.ColorModeNone,
 		Admin:        admin,
+		Role:         types.RolePlayer,
 		online:       false,
 		windowWidth:  80,
 		windowHeight: 40,
 	}
 
+	if admin {
+		user.Role = types.RoleAdmin
+	}
+
 	dbinit(user)
 	return user
 }
@@ -78,6 +88,20 @@ func (self *User) GetColorMode() types.ColorMode {
 	return self.ColorMode
 }
 
+func (self *User) SetWrapWidth(width int) {
+	self.writeLock(func() {
+		self.WrapWidth = width
+	})
+}
+
+// GetWrapWidth returns the width that output is wrapped to, which can also be
+// types.WrapAuto or types.WrapOff
+func (self *User) GetWrapWidth() int {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.WrapWidth
+}
+
 func hash(data string) []byte {
 	h := sha1.New()
 	io.WriteString(h, data)
@@ -145,13 +169,106 @@ func (self *User) Write(text string) {
 }
 
 func (self *User) SetAdmin(admin bool) {
+	if admin {
+		self.SetRole(types.RoleAdmin)
+	} else {
+		self.SetRole(types.RolePlayer)
+	}
+}
+
+func (self *User) IsAdmin() bool {
+	return self.GetRole() == types.RoleAdmin
+}
+
+// GetRole returns the user's role. Users created before roles existed only
+// have the admin flag, which is mapped onto the admin or player role.
+func (self *User) GetRole() types.Role {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.Role == "" {
+		if self.Admin {
+			return types.RoleAdmin
+		}
+		return types.RolePlayer
+	}
+
+	return self.Role
+}
+
+func (self *User) SetRole(role types.Role) {
 	self.writeLock(func() {
-		self.Admin = admin
+		self.Role = role
+		self.Admin = role == types.RoleAdmin
 	})
 }
 
-func (self *User) IsAdmin() bool {
+func (self *User) HasPermission(permission types.Permission) bool {
+	return self.GetRole().HasPermission(permission)
+}
+
+// CanBuildIn returns true if the user is allowed to edit the given zone.
+// Admins can build anywhere, builders only in the zones assigned to them.
+func (self *User) CanBuildIn(zoneId types.Id) bool {
+	if self.HasPermission(types.PermissionAdmin) {
+		return true
+	}
+
+	if !self.HasPermission(types.PermissionBuild) {
+		return false
+	}
+
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.BuilderZones.Contains(zoneId.Hex())
+}
+
+func (self *User) AddBuilderZone(zoneId types.Id) {
+	self.writeLock(func() {
+		if self.BuilderZones == nil {
+			self.BuilderZones = utils.Set{}
+		}
+		self.BuilderZones.Insert(zoneId.Hex())
+	})
+}
+
+func (self *User) RemoveBuilderZone(zoneId types.Id) {
+	self.writeLock(func() {
+		self.BuilderZones.Remove(zoneId.Hex())
+	})
+}
+
+func (self *User) GetBuilderZones() []types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return idSetToList(self.BuilderZones)
+}
+
+// Ignore stops messages from any of the given user's characters from reaching
+// this user
+func (self *User) Ignore(userId types.Id) {
+	self.writeLock(func() {
+		if self.Ignored == nil {
+			self.Ignored = utils.Set{}
+		}
+		self.Ignored.Insert(userId.Hex())
+	})
+}
+
+func (self *User) Unignore(userId types.Id) {
+	self.writeLock(func() {
+		self.Ignored.Remove(userId.Hex())
+	})
+}
+
+func (self *User) IsIgnoring(userId types.Id) bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.Ignored.Contains(userId.Hex())
+}
+
+func (self *User) GetIgnored() []types.Id {
 	self.ReadLock()
 	defer self.ReadUnlock()
-	return self.Admin
+	return idSetToList(self.Ignored)
 }
@@ -8,8 +8,9 @@ import (
 )
 
 type World struct {
-	DbObject `bson:",inline"`
-	Time     time.Time
+	DbObject   `bson:",inline"`
+	Time       time.Time
+	JailRoomId types.Id `bson:",omitempty"`
 }
 
 func NewWorld() *World {
@@ -44,3 +45,41 @@ func (self *World) AdvanceTime() {
 		self.Time = self.Time.Add(3 * time.Second)
 	})
 }
+
+// IsDaytime returns true between 6am and 6pm world time
+func (self *World) IsDaytime() bool {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	hour := self.Time.Hour()
+	return hour >= 6 && hour < 18
+}
+
+// GetPhase returns the part of the day it is. Dawn and day make up the
+// daytime.
+func (self *World) GetPhase() types.DayPhase {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	switch hour := self.Time.Hour(); {
+	case hour == 6:
+		return types.PhaseDawn
+	case hour > 6 && hour < 18:
+		return types.PhaseDay
+	case hour == 18:
+		return types.PhaseDusk
+	}
+	return types.PhaseNight
+}
+
+func (self *World) GetJailRoomId() types.Id {
+	self.ReadLock()
+	defer self.ReadUnlock()
+	return self.JailRoomId
+}
+
+func (self *World) SetJailRoomId(id types.Id) {
+	self.writeLock(func() {
+		self.JailRoomId = id
+	})
+}
@@ -1,11 +1,15 @@
 package database
 
-import "github.com/Cristofori/kmud/utils"
+import (
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/utils"
+)
 
 type Zone struct {
 	DbObject `bson:",inline"`
 
-	Name string
+	Name    string
+	Weather types.Weather `bson:",omitempty"`
 }
 
 func NewZone(name string) *Zone {
@@ -28,3 +32,21 @@ func (self *Zone) SetName(name string) {
 		self.Name = utils.FormatName(name)
 	})
 }
+
+// GetWeather returns the weather outside in the zone, which is clear until
+// it's first changed
+func (self *Zone) GetWeather() types.Weather {
+	self.ReadLock()
+	defer self.ReadUnlock()
+
+	if self.Weather == "" {
+		return types.WeatherClear
+	}
+	return self.Weather
+}
+
+func (self *Zone) SetWeather(weather types.Weather) {
+	self.writeLock(func() {
+		self.Weather = weather
+	})
+}
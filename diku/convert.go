@@ -0,0 +1,533 @@
+package diku
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/worldfile"
+)
+
+// Diku exits are numbered north, east, south, west, up, down
+var directions = []types.Direction{
+	types.DirectionNorth,
+	types.DirectionEast,
+	types.DirectionSouth,
+	types.DirectionWest,
+	types.DirectionUp,
+	types.DirectionDown,
+}
+
+// Diku room flags which have a kmud equivalent
+var roomFlags = []struct {
+	bit  int
+	flag types.RoomFlag
+}{
+	{1, types.RoomDark},
+	{8, types.RoomIndoors},
+	{1024, types.RoomSafe},
+	{8192, types.RoomNoRecall},
+}
+
+// Diku sectors which have a kmud terrain. Inside rooms are given the indoors
+// flag instead.
+var sectors = map[int]types.Terrain{
+	1: types.TerrainCity,
+	2: types.TerrainField,
+	3: types.TerrainForest,
+	4: types.TerrainHills,
+	5: types.TerrainMountain,
+	6: types.TerrainWater,
+	7: types.TerrainWater,
+	8: types.TerrainWater,
+}
+
+// Diku shopkeepers have an unlimited supply of what they sell, which is
+// approximated by restocking regularly
+const (
+	shopQuantity = 10
+	shopInterval = 15
+)
+
+func roomId(vnum int) string {
+	return fmt.Sprintf("room%v", vnum)
+}
+
+func mobileId(vnum int) string {
+	return fmt.Sprintf("mobile%v", vnum)
+}
+
+func objectId(vnum int) string {
+	return fmt.Sprintf("object%v", vnum)
+}
+
+// Strips the leading article from short descriptions such as "a long sword"
+func name(short string) string {
+	short = strings.TrimSpace(short)
+	lower := strings.ToLower(short)
+
+	for _, article := range []string{"a ", "an ", "the ", "some "} {
+		if strings.HasPrefix(lower, article) && len(short) > len(article) {
+			return strings.TrimSpace(short[len(article):])
+		}
+	}
+
+	return short
+}
+
+// Areas often have several objects or mobiles with the same short
+// description. Imports match templates up by name, so every name after the
+// first gets its vnum added to keep them apart.
+func uniqueName(taken map[string]bool, short string, vnum int) string {
+	result := name(short)
+	if taken[strings.ToLower(result)] {
+		result = fmt.Sprintf("%s %v", result, vnum)
+	}
+	taken[strings.ToLower(result)] = true
+	return result
+}
+
+// Collapses the hard line breaks in Diku descriptions
+func description(desc string) string {
+	return strings.Join(strings.Fields(desc), " ")
+}
+
+// Extra descriptions are listed under each of their keywords
+func extras(list []Extra) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	extras := map[string]string{}
+	for _, extra := range list {
+		for _, keyword := range strings.Fields(strings.ToLower(extra.Keywords)) {
+			extras[keyword] = description(extra.Description)
+		}
+	}
+	return extras
+}
+
+// Diku door locks are 1 for an ordinary lock and 2 for one that can't be
+// picked
+const doorDifficulty = 50
+
+// Turns an exit with a door into a kmud door. The key is dropped if it's an
+// object from another area.
+func door(exit Exit, dir types.Direction, objects map[int]bool) worldfile.Door {
+	d := worldfile.Door{Direction: dir, Name: "door", Difficulty: doorDifficulty}
+
+	if keywords := strings.Fields(strings.ToLower(exit.Keywords)); len(keywords) > 0 {
+		d.Name = keywords[0]
+	}
+
+	if exit.Locks == 2 {
+		d.Difficulty = 0
+	}
+
+	if objects[exit.Key] {
+		d.Key = objectId(exit.Key)
+	}
+
+	return d
+}
+
+// Returns the average roll of dice written like "3d8+10"
+func averageRoll(dice string) int {
+	var count, sides, bonus int
+	plus := strings.Index(dice, "+")
+	if plus != -1 {
+		bonus, _ = strconv.Atoi(dice[plus+1:])
+		dice = dice[:plus]
+	}
+
+	d := strings.Index(dice, "d")
+	if d == -1 {
+		return bonus
+	}
+
+	count, _ = strconv.Atoi(dice[:d])
+	sides, _ = strconv.Atoi(dice[d+1:])
+	return count*(sides+1)/2 + bonus
+}
+
+// Layout assigns a coordinate to every room. Rooms are placed next to their
+// neighbours wherever possible, starting from the lowest vnum. Groups of rooms
+// that aren't connected to each other are placed side by side.
+func Layout(rooms []Room) map[int]types.Coordinate {
+	byVnum := map[int]Room{}
+	var vnums []int
+	for _, room := range rooms {
+		byVnum[room.Vnum] = room
+		vnums = append(vnums, room.Vnum)
+	}
+	sort.Ints(vnums)
+
+	locations := map[int]types.Coordinate{}
+	occupied := map[types.Coordinate]bool{}
+	maxX := -2
+
+	place := func(vnum int, location types.Coordinate) {
+		locations[vnum] = location
+		occupied[location] = true
+		if location.X > maxX {
+			maxX = location.X
+		}
+	}
+
+	for _, start := range vnums {
+		if _, placed := locations[start]; placed {
+			continue
+		}
+
+		place(start, types.Coordinate{X: maxX + 2, Y: 0, Z: 0})
+		queue := []int{start}
+
+		for len(queue) > 0 {
+			vnum := queue[0]
+			queue = queue[1:]
+
+			for _, exit := range byVnum[vnum].Exits {
+				if _, found := byVnum[exit.To]; !found || exit.Direction < 0 || exit.Direction >= len(directions) {
+					continue
+				}
+
+				if _, placed := locations[exit.To]; placed {
+					continue
+				}
+
+				from := locations[vnum]
+				next := from.Next(directions[exit.Direction])
+				if !occupied[next] {
+					place(exit.To, next)
+					queue = append(queue, exit.To)
+				}
+			}
+		}
+	}
+
+	// Anything left over had no room next to its neighbours, so give it a
+	// spot of its own
+	for _, vnum := range vnums {
+		if _, placed := locations[vnum]; !placed {
+			place(vnum, types.Coordinate{X: maxX + 2, Y: 0, Z: 0})
+		}
+	}
+
+	return locations
+}
+
+// Convert builds a world file from the area. Exits which can't be represented
+// by neighbouring coordinates become named links. Anything that has no
+// equivalent in kmud is left out and a warning is returned for it.
+func Convert(area *Area) (*worldfile.File, []string) {
+	var warnings []string
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+
+	file := &worldfile.File{
+		Version: worldfile.Version,
+		Zone:    area.Name,
+		Areas:   []worldfile.Area{{Id: "area", Name: area.Name}},
+	}
+
+	objects := map[int]bool{}
+	objectNames := map[string]bool{}
+	for _, obj := range area.Objects {
+		objects[obj.Vnum] = true
+
+		template := worldfile.Template{
+			Id:       objectId(obj.Vnum),
+			Name:     uniqueName(objectNames, obj.Short, obj.Vnum),
+			Value:    obj.Cost,
+			Weight:   obj.Weight,
+			Category: obj.Type,
+			Light:    obj.Type == "light",
+		}
+
+		template.Extras = extras(obj.Extras)
+
+		if obj.Type == "container" && len(obj.Values) > 0 {
+			template.Capacity, _ = strconv.Atoi(obj.Values[0])
+		}
+
+		file.Templates = append(file.Templates, template)
+	}
+
+	locations := Layout(area.Rooms)
+	rooms := map[int]*worldfile.Room{}
+
+	for _, room := range area.Rooms {
+		r := worldfile.Room{
+			Id:          roomId(room.Vnum),
+			Location:    locations[room.Vnum],
+			Area:        "area",
+			Title:       strings.TrimSpace(room.Name),
+			Description: description(room.Description),
+			Extras:      extras(room.Extras),
+			Terrain:     sectors[room.Sector],
+		}
+
+		if room.Sector == 0 {
+			room.Flags |= 8
+		}
+
+		for _, f := range roomFlags {
+			if room.Flags&f.bit != 0 {
+				r.Flags = append(r.Flags, f.flag)
+			}
+		}
+
+		file.Rooms = append(file.Rooms, r)
+	}
+
+	for i := range file.Rooms {
+		rooms[area.Rooms[i].Vnum] = &file.Rooms[i]
+	}
+
+	// The Diku directions of each room which became regular exits, and so can
+	// have doors that are closed and locked by door resets
+	exits := map[int]map[int]bool{}
+
+	for _, room := range area.Rooms {
+		r := rooms[room.Vnum]
+		exits[room.Vnum] = map[int]bool{}
+
+		for _, exit := range room.Exits {
+			if exit.Direction < 0 || exit.Direction >= len(directions) {
+				warn("Room %v has an invalid exit direction %v", room.Vnum, exit.Direction)
+				continue
+			}
+
+			if _, found := rooms[exit.To]; !found {
+				warn("Room %v has an exit to room %v, which is in another area", room.Vnum, exit.To)
+				continue
+			}
+
+			dir := directions[exit.Direction]
+			if r.Location.Next(dir) == locations[exit.To] {
+				r.Exits = append(r.Exits, dir)
+				exits[room.Vnum][exit.Direction] = true
+
+				if exit.Locks != 0 {
+					r.Doors = append(r.Doors, door(exit, dir, objects))
+				}
+				continue
+			}
+
+			link := strings.ToLower(string(dir))
+			if keywords := strings.Fields(exit.Keywords); len(keywords) > 0 {
+				if _, taken := r.Links[keywords[0]]; !taken {
+					link = keywords[0]
+				}
+			}
+
+			if r.Links == nil {
+				r.Links = map[string]string{}
+			}
+			r.Links[link] = roomId(exit.To)
+		}
+	}
+
+	mobiles := map[int]*worldfile.Spawner{}
+	mobileNames := map[string]bool{}
+	for _, mob := range area.Mobiles {
+		spawner := &worldfile.Spawner{
+			Id:       mobileId(mob.Vnum),
+			Name:     uniqueName(mobileNames, mob.Short, mob.Vnum),
+			Area:     "area",
+			Health:   100,
+			Strength: 10 + mob.Level,
+			Cash:     mob.Gold,
+			Roaming:  !mob.Sentinel,
+			Schedule: types.SpawnAlways,
+		}
+
+		if mob.HitDice != "" {
+			spawner.Health = averageRoll(mob.HitDice)
+		}
+
+		mobiles[mob.Vnum] = spawner
+	}
+
+	keepers := map[int]*worldfile.Store{}
+	for _, shop := range area.Shops {
+		if mobiles[shop.Keeper] == nil {
+			warn("Shop keeper %v doesn't exist", shop.Keeper)
+			continue
+		}
+
+		store := &worldfile.Store{
+			Id:       fmt.Sprintf("shop%v", shop.Keeper),
+			Name:     mobiles[shop.Keeper].Name,
+			SellRate: shop.ProfitBuy,
+			BuyRate:  shop.ProfitSell,
+		}
+
+		for _, t := range shop.BuyTypes {
+			if category, found := itemTypes[t]; found {
+				store.Categories = append(store.Categories, category)
+			}
+		}
+
+		keepers[shop.Keeper] = store
+	}
+
+	// Where the last copy of each object was put on the floor, for the resets
+	// that fill containers
+	type placement struct {
+		room  int
+		index int
+	}
+	placed := map[int]placement{}
+
+	lastMob := 0
+	for _, reset := range area.Resets {
+		arg := func(i int) int {
+			if i < len(reset.Args) {
+				return reset.Args[i]
+			}
+			return 0
+		}
+
+		switch reset.Command {
+		case 'M':
+			mob, room := arg(1), arg(3)
+			spawner := mobiles[mob]
+			if spawner == nil || rooms[room] == nil {
+				warn("Mobile reset %v in room %v refers to something missing", mob, room)
+				lastMob = 0
+				continue
+			}
+
+			lastMob = mob
+			spawner.Count++
+			if !contains(spawner.Rooms, roomId(room)) {
+				spawner.Rooms = append(spawner.Rooms, roomId(room))
+			}
+
+			if store := keepers[mob]; store != nil && store.Room == "" {
+				store.Room = roomId(room)
+			}
+		case 'G', 'E':
+			obj := arg(1)
+			if !objects[obj] || lastMob == 0 {
+				warn("Equipment reset %v refers to something missing", obj)
+				continue
+			}
+
+			if store := keepers[lastMob]; store != nil && reset.Command == 'G' {
+				store.Stock = append(store.Stock, worldfile.StockEntry{
+					Template: objectId(obj),
+					Quantity: shopQuantity,
+					Interval: shopInterval,
+				})
+			} else {
+				spawner := mobiles[lastMob]
+				spawner.Equipment = append(spawner.Equipment, objectId(obj))
+			}
+		case 'D':
+			room, dir, state := arg(1), arg(2), arg(3)
+			if !exits[room][dir] {
+				continue
+			}
+
+			for i := range rooms[room].Doors {
+				if rooms[room].Doors[i].Direction == directions[dir] {
+					rooms[room].Doors[i].Closed = state != 0
+				}
+			}
+
+			if state == 2 {
+				rooms[room].Locked = append(rooms[room].Locked, directions[dir])
+			}
+		case 'O':
+			obj, room := arg(1), arg(3)
+			if !objects[obj] || rooms[room] == nil {
+				warn("Object reset %v in room %v refers to something missing", obj, room)
+				continue
+			}
+
+			rooms[room].Items = append(rooms[room].Items, worldfile.Item{Template: objectId(obj)})
+			placed[obj] = placement{room: room, index: len(rooms[room].Items) - 1}
+		case 'P':
+			obj, container := arg(1), arg(3)
+			where, found := placed[container]
+			if !objects[obj] || !found {
+				warn("Object reset %v into %v refers to something missing", obj, container)
+				continue
+			}
+
+			item := &rooms[where.room].Items[where.index]
+			item.Contents = append(item.Contents, objectId(obj))
+		case 'R':
+			// Exits are never shuffled
+		default:
+			warn("Unknown reset command %q", reset.Command)
+		}
+	}
+
+	for _, mob := range area.Mobiles {
+		spawner := mobiles[mob.Vnum]
+
+		// Keep mobiles that are never reset around as templates for builders
+		if spawner.Count == 0 {
+			spawner.Count = 1
+			spawner.Paused = true
+		}
+
+		file.Spawners = append(file.Spawners, *spawner)
+	}
+
+	for _, shop := range area.Shops {
+		store := keepers[shop.Keeper]
+		if store == nil {
+			continue
+		}
+
+		if store.Room == "" {
+			warn("Shop keeper %v is never placed in a room", shop.Keeper)
+			continue
+		}
+
+		file.Stores = append(file.Stores, *store)
+	}
+
+	return file, warnings
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Load parses and converts the area file at the given path. Areas without a
+// name are named after the file.
+func Load(path string) (*worldfile.File, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	area, err := Parse(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if area.Name == "" {
+		base := filepath.Base(path)
+		area.Name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	file, warnings := Convert(area)
+	return file, warnings, nil
+}
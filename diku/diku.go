@@ -0,0 +1,565 @@
+// Package diku reads area files from Diku derived MUDs (Merc, ROM and the
+// like) and converts them to world files which can be imported as zones.
+package diku
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+type Mobile struct {
+	Vnum     int
+	Keywords string
+	Short    string
+	Long     string
+	Sentinel bool
+	Level    int
+	HitDice  string
+	Gold     int
+}
+
+type Object struct {
+	Vnum     int
+	Keywords string
+	Short    string
+	Type     string
+	Values   []string
+	Weight   int
+	Cost     int
+	Extras   []Extra
+}
+
+// Extra is an extra description, which can be seen by looking at any of its
+// keywords
+type Extra struct {
+	Keywords    string
+	Description string
+}
+
+type Exit struct {
+	Direction int
+	Keywords  string
+	Locks     int
+	Key       int
+	To        int
+}
+
+type Room struct {
+	Vnum        int
+	Name        string
+	Description string
+	Flags       int
+	Sector      int
+	Exits       []Exit
+	Extras      []Extra
+}
+
+type Reset struct {
+	Command byte
+	Args    []int
+}
+
+type Shop struct {
+	Keeper     int
+	BuyTypes   []int
+	ProfitBuy  int
+	ProfitSell int
+}
+
+type Area struct {
+	Name    string
+	Mobiles []Mobile
+	Objects []Object
+	Rooms   []Room
+	Resets  []Reset
+	Shops   []Shop
+}
+
+// Parse reads a single area file. Sections which aren't needed to build a
+// zone, such as helps and specials, are skipped.
+func Parse(r io.Reader) (*Area, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{data: string(data), line: 1}
+	area := &Area{}
+
+	for p.err == nil {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+
+		section := p.word()
+
+		switch section {
+		case "#AREA":
+			area.Name = p.areaName()
+		case "#AREADATA":
+			area.Name = p.areaData()
+		case "#MOBILES":
+			p.entries(func(vnum int) { area.Mobiles = append(area.Mobiles, p.mobile(vnum)) })
+		case "#OBJECTS":
+			p.entries(func(vnum int) { area.Objects = append(area.Objects, p.object(vnum)) })
+		case "#ROOMS":
+			p.entries(func(vnum int) { area.Rooms = append(area.Rooms, p.room(vnum)) })
+		case "#RESETS":
+			area.Resets = p.resets()
+		case "#SHOPS":
+			area.Shops = p.shops()
+		case "#$":
+			return area, nil
+		default:
+			if !strings.HasPrefix(section, "#") {
+				p.fail("Expected a section, found %q", section)
+			} else {
+				p.skipSection()
+			}
+		}
+	}
+
+	return area, p.err
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+	err  error
+}
+
+func (self *parser) fail(format string, a ...interface{}) {
+	if self.err == nil {
+		self.err = fmt.Errorf("line %v: %s", self.line, fmt.Sprintf(format, a...))
+	}
+}
+
+func (self *parser) eof() bool {
+	return self.err != nil || self.pos >= len(self.data)
+}
+
+func (self *parser) peek() byte {
+	if self.eof() {
+		return 0
+	}
+	return self.data[self.pos]
+}
+
+func (self *parser) next() byte {
+	c := self.peek()
+	self.pos++
+	if c == '\n' {
+		self.line++
+	}
+	return c
+}
+
+func (self *parser) skipSpace() {
+	for !self.eof() && strings.IndexByte(" \t\r\n", self.peek()) != -1 {
+		self.next()
+	}
+}
+
+func (self *parser) word() string {
+	self.skipSpace()
+	start := self.pos
+	for !self.eof() && strings.IndexByte(" \t\r\n", self.peek()) == -1 {
+		self.next()
+	}
+	return self.data[start:self.pos]
+}
+
+func (self *parser) number() int {
+	word := self.word()
+	n, err := strconv.Atoi(word)
+	if err != nil {
+		self.fail("Expected a number, found %q", word)
+	}
+	return n
+}
+
+// Reads a tilde terminated string
+func (self *parser) string() string {
+	self.skipSpace()
+	start := self.pos
+	for !self.eof() && self.peek() != '~' {
+		self.next()
+	}
+
+	if self.eof() {
+		self.fail("Unterminated string")
+		return ""
+	}
+
+	str := self.data[start:self.pos]
+	self.next()
+	return strings.Replace(str, "\r", "", -1)
+}
+
+// Reads the rest of the current line, or the next line if there's nothing
+// left on the current one
+func (self *parser) fields() []string {
+	self.skipSpace()
+	start := self.pos
+	for !self.eof() && self.peek() != '\n' {
+		self.next()
+	}
+	return strings.Fields(self.data[start:self.pos])
+}
+
+// Skips lines until the start of the next entry or section
+func (self *parser) skipToHash() {
+	for {
+		self.skipSpace()
+		if self.eof() || self.peek() == '#' {
+			return
+		}
+		self.fields()
+	}
+}
+
+func (self *parser) skipSection() {
+	for {
+		self.skipToHash()
+		if self.eof() {
+			return
+		}
+
+		if self.pos+1 < len(self.data) {
+			c := self.data[self.pos+1]
+			if c >= 'A' && c <= 'Z' || c == '$' {
+				return
+			}
+		}
+
+		self.next()
+	}
+}
+
+// Reads the "#<vnum>" entries of a section up to the terminating "#0"
+func (self *parser) entries(read func(int)) {
+	for self.err == nil {
+		word := self.word()
+		if !strings.HasPrefix(word, "#") {
+			self.fail("Expected a vnum, found %q", word)
+			return
+		}
+
+		vnum, err := strconv.Atoi(word[1:])
+		if err != nil {
+			self.fail("Invalid vnum %q", word)
+			return
+		}
+
+		if vnum == 0 {
+			return
+		}
+
+		read(vnum)
+	}
+}
+
+// Merc puts everything on one line, "#AREA {levels} Author Name~", while ROM
+// has the file name, area name and credits as separate strings followed by
+// the vnum range
+func (self *parser) areaName() string {
+	first := strings.TrimSpace(self.string())
+
+	if strings.HasSuffix(first, ".are") {
+		name := strings.TrimSpace(self.string())
+		self.string()
+		self.fields()
+		return name
+	}
+
+	if end := strings.Index(first, "}"); end != -1 {
+		fields := strings.Fields(first[end+1:])
+		if len(fields) > 1 {
+			fields = fields[1:]
+		}
+		return strings.Join(fields, " ")
+	}
+
+	return first
+}
+
+func (self *parser) areaData() string {
+	name := ""
+	for self.err == nil && !self.eof() {
+		fields := self.fields()
+		if len(fields) == 0 {
+			continue
+		}
+
+		if fields[0] == "End" {
+			break
+		}
+
+		if fields[0] == "Name" {
+			name = strings.TrimSuffix(strings.Join(fields[1:], " "), "~")
+		}
+	}
+	return name
+}
+
+func (self *parser) mobile(vnum int) Mobile {
+	mob := Mobile{Vnum: vnum}
+	mob.Keywords = self.string()
+	mob.Short = self.string()
+	mob.Long = self.string()
+	self.string() // Description
+
+	// ROM has a race string before the flags
+	fields := self.fields()
+	if len(fields) > 0 && strings.HasSuffix(fields[len(fields)-1], "~") {
+		fields = self.fields()
+	}
+
+	if len(fields) > 0 {
+		mob.Sentinel = flags(fields[0])&2 != 0
+	}
+
+	// Merc has "level hitroll ac hitdice damdice" and ROM has "level hitroll
+	// hitdice manadice damdice damtype", so the hit dice are the first dice
+	fields = self.fields()
+	for i, field := range fields {
+		if i == 0 {
+			mob.Level, _ = strconv.Atoi(field)
+		} else if strings.Contains(field, "d") {
+			mob.HitDice = field
+			break
+		}
+	}
+
+	// Merc follows with "gold exp", while ROM has armor class and immunity
+	// lines before "start default sex wealth"
+	fields = self.fields()
+	if len(fields) == 2 {
+		mob.Gold, _ = strconv.Atoi(fields[0])
+	} else {
+		self.fields()
+		fields = self.fields()
+		if len(fields) == 4 {
+			mob.Gold, _ = strconv.Atoi(fields[3])
+		}
+	}
+
+	self.skipToHash()
+	return mob
+}
+
+var itemTypes = map[int]string{
+	1:  "light",
+	2:  "scroll",
+	3:  "wand",
+	4:  "staff",
+	5:  "weapon",
+	8:  "treasure",
+	9:  "armor",
+	10: "potion",
+	12: "furniture",
+	13: "trash",
+	15: "container",
+	17: "drink",
+	18: "key",
+	19: "food",
+	20: "money",
+	22: "boat",
+	23: "corpse",
+	24: "corpse",
+	25: "fountain",
+	26: "pill",
+}
+
+// Item types are numbers in Merc files and words in ROM files
+func itemType(word string) string {
+	if n, err := strconv.Atoi(word); err == nil {
+		return itemTypes[n]
+	}
+	return strings.ToLower(word)
+}
+
+func (self *parser) object(vnum int) Object {
+	obj := Object{Vnum: vnum}
+	obj.Keywords = self.string()
+	obj.Short = self.string()
+	self.string() // Long description
+	self.string() // Action description (Merc) or material (ROM)
+
+	fields := self.fields()
+	if len(fields) > 0 {
+		obj.Type = itemType(fields[0])
+	}
+
+	obj.Values = self.fields()
+
+	// Merc has "weight cost rent" and ROM has "level weight cost condition"
+	fields = self.fields()
+	if len(fields) == 4 {
+		fields = fields[1:]
+	}
+
+	if len(fields) >= 2 {
+		obj.Weight, _ = strconv.Atoi(fields[0])
+		obj.Cost, _ = strconv.Atoi(fields[1])
+	}
+
+	for self.err == nil {
+		self.skipSpace()
+		if self.eof() || self.peek() == '#' {
+			break
+		}
+
+		if self.word() == "E" {
+			obj.Extras = append(obj.Extras, self.extra())
+		} else {
+			self.fields()
+		}
+	}
+
+	return obj
+}
+
+func (self *parser) room(vnum int) Room {
+	room := Room{Vnum: vnum}
+	room.Name = self.string()
+	room.Description = self.string()
+	fields := self.fields() // Area, flags and sector
+	if len(fields) == 3 {
+		room.Flags = flags(fields[1])
+		room.Sector, _ = strconv.Atoi(fields[2])
+	}
+
+	for self.err == nil {
+		word := self.word()
+
+		switch {
+		case word == "S":
+			return room
+		case word == "E":
+			room.Extras = append(room.Extras, self.extra())
+		case strings.HasPrefix(word, "D") && len(word) == 2:
+			exit := Exit{Direction: int(word[1] - '0')}
+			self.string() // Description
+			exit.Keywords = self.string()
+			exit.Locks = self.number()
+			exit.Key = self.number()
+			exit.To = self.number()
+			room.Exits = append(room.Exits, exit)
+		case self.eof():
+			self.fail("Unterminated room %v", vnum)
+		default:
+			self.fields()
+		}
+	}
+
+	return room
+}
+
+func (self *parser) extra() Extra {
+	keywords := self.string()
+	return Extra{Keywords: keywords, Description: self.string()}
+}
+
+func (self *parser) resets() []Reset {
+	var resets []Reset
+
+	for self.err == nil {
+		fields := self.fields()
+		if len(fields) == 0 {
+			self.fail("Unterminated resets")
+			break
+		}
+
+		command := fields[0][0]
+		if command == 'S' {
+			break
+		}
+
+		if command == '*' {
+			continue
+		}
+
+		reset := Reset{Command: command}
+		for _, field := range fields[1:] {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				break // The rest is a comment
+			}
+			reset.Args = append(reset.Args, n)
+		}
+
+		resets = append(resets, reset)
+	}
+
+	return resets
+}
+
+func (self *parser) shops() []Shop {
+	var shops []Shop
+
+	for self.err == nil {
+		fields := self.fields()
+		if len(fields) == 0 {
+			self.fail("Unterminated shops")
+			break
+		}
+
+		var numbers []int
+		for _, field := range fields {
+			n, err := strconv.Atoi(field)
+			if err != nil {
+				break
+			}
+			numbers = append(numbers, n)
+		}
+
+		if len(numbers) == 0 || numbers[0] == 0 {
+			break
+		}
+
+		if len(numbers) < 8 {
+			self.fail("Invalid shop")
+			break
+		}
+
+		shops = append(shops, Shop{
+			Keeper:     numbers[0],
+			BuyTypes:   numbers[1:6],
+			ProfitBuy:  numbers[6],
+			ProfitSell: numbers[7],
+		})
+	}
+
+	return shops
+}
+
+// Parses a flag field, which is either a decimal number, letters (A is 1,
+// B is 2 and so on, with lower case letters continuing after Z) or several
+// of those joined with |
+func flags(field string) int {
+	value := 0
+
+	for _, part := range strings.Split(field, "|") {
+		if n, err := strconv.Atoi(part); err == nil {
+			value |= n
+			continue
+		}
+
+		for _, c := range part {
+			switch {
+			case c >= 'A' && c <= 'Z':
+				value |= 1 << uint(c-'A')
+			case c >= 'a' && c <= 'z':
+				value |= 1 << uint(26+c-'a')
+			}
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,365 @@
+package diku
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Cristofori/kmud/types"
+)
+
+const mercArea = `#AREA	{ 5 35} Merc    Test Town~
+
+#MOBILES
+#3000
+wizard~
+the wizard~
+A wizard walks around behind the counter.
+~
+The wizard looks old and senile.
+~
+3 0 900 S
+33 2 2 6d10+200 1d8+32
+5000 60000
+8 8 1
+#3001
+guard cityguard~
+a cityguard~
+A cityguard stands here.
+~
+A big, strong, helpful cityguard.
+~
+1|2|64 0 1000 S
+10 0 0 3d8+50 1d10+5
+100 9000
+8 8 1
+#0
+
+#OBJECTS
+#3010
+sword long~
+a long sword~
+A long sword has been left here.~
+~
+5 0 8193
+0 2 6 3
+12 600 0
+E
+sword long~
+It is a long sword.
+~
+#3011
+bag~
+a bag~
+A bag lies here.~
+~
+15 0 1
+40 0 0 0
+5 20 0
+#0
+
+#ROOMS
+#3001
+The Temple~
+You are in the temple.
+It is very quiet.
+~
+0 1032 0
+D0
+~
+~
+0 -1 3002
+D1
+door~
+door~
+1 3011 3003
+S
+#3002
+The Altar~
+The altar is here.
+~
+0 1 5
+D2
+~
+~
+0 -1 3001
+D5
+~
+~
+0 -1 9999
+S
+#3003
+The Shop~
+A small shop.
+~
+0 8 0
+D3
+door~
+door~
+1 -1 3001
+E
+counter~
+A wooden counter.
+~
+S
+#0
+
+#RESETS
+* Comment line
+M 0 3001 2 3001	guard in the temple
+E 1 3010 100 16	sword
+M 0 3001 2 3002
+M 0 3000 1 3003	wizard
+G 1 3011 0		bag
+D 0 3001 1 2	locked door
+O 0 3010 1 3002
+O 0 3011 1 3003
+P 1 3010 1 3011
+P 1 3010 1 3001
+S
+
+#SHOPS
+3000 15 0 0 0 0 120 50 0 23	wizard
+0
+
+#SPECIALS
+M 3001 spec_guard
+S
+
+#$
+`
+
+const romMobile = `#AREA
+test.are~
+Rom Test~
+{ All } Someone Rom Test~
+100 199
+
+#MOBILES
+#100
+rat~
+a large rat~
+A large rat scurries about.
+~
+It's a rat.
+~
+rodent~
+AB 0 0 0
+2 0 2d6+10 1d1+0 1d3+0 bite
+9 9 9 9
+0 0 0 0
+stand stand neutral 25
+0 0 small unknown
+#0
+
+#OBJECTS
+#100
+cheese~
+a piece of cheese~
+Some cheese is here.~
+food~
+food 0 A
+5 5 0 0 0
+1 1 10 P
+#0
+
+#$
+`
+
+func Test_ParseMerc(t *testing.T) {
+	area, err := Parse(strings.NewReader(mercArea))
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if area.Name != "Test Town" {
+		t.Errorf("Name == %q, want %q", area.Name, "Test Town")
+	}
+
+	if len(area.Mobiles) != 2 || len(area.Objects) != 2 || len(area.Rooms) != 3 {
+		t.Fatalf("Parsed %v mobiles, %v objects and %v rooms, want 2, 2 and 3",
+			len(area.Mobiles), len(area.Objects), len(area.Rooms))
+	}
+
+	guard := area.Mobiles[1]
+	if guard.Short != "a cityguard" || guard.HitDice != "3d8+50" || guard.Gold != 100 || !guard.Sentinel {
+		t.Errorf("Guard parsed as %+v", guard)
+	}
+
+	sword := area.Objects[0]
+	if sword.Type != "weapon" || sword.Weight != 12 || sword.Cost != 600 {
+		t.Errorf("Sword parsed as %+v", sword)
+	}
+
+	temple := area.Rooms[0]
+	if len(temple.Exits) != 2 || temple.Exits[1].Keywords != "door" || temple.Exits[1].To != 3003 ||
+		temple.Exits[1].Key != 3011 || temple.Flags != 1032 {
+		t.Errorf("Temple parsed as %+v", temple)
+	}
+
+	if len(area.Resets) != 10 || area.Resets[0].Command != 'M' || area.Resets[0].Args[3] != 3001 {
+		t.Errorf("Resets parsed as %+v", area.Resets)
+	}
+
+	if len(area.Shops) != 1 || area.Shops[0].Keeper != 3000 || area.Shops[0].ProfitBuy != 120 {
+		t.Errorf("Shops parsed as %+v", area.Shops)
+	}
+}
+
+func Test_ParseRom(t *testing.T) {
+	area, err := Parse(strings.NewReader(romMobile))
+	if err != nil {
+		t.Fatalf("Parse() failed: %s", err)
+	}
+
+	if area.Name != "Rom Test" {
+		t.Errorf("Name == %q, want %q", area.Name, "Rom Test")
+	}
+
+	rat := area.Mobiles[0]
+	if rat.Level != 2 || rat.HitDice != "2d6+10" || rat.Gold != 25 || !rat.Sentinel {
+		t.Errorf("Rat parsed as %+v", rat)
+	}
+
+	cheese := area.Objects[0]
+	if cheese.Type != "food" || cheese.Weight != 1 || cheese.Cost != 10 {
+		t.Errorf("Cheese parsed as %+v", cheese)
+	}
+}
+
+func Test_ParseError(t *testing.T) {
+	_, err := Parse(strings.NewReader("#ROOMS\n#100\nUnterminated"))
+	if err == nil {
+		t.Errorf("Parse() of a broken file succeeded")
+	}
+}
+
+func Test_Convert(t *testing.T) {
+	area, _ := Parse(strings.NewReader(mercArea))
+	file, warnings := Convert(area)
+
+	if err := file.Validate(); err != nil {
+		t.Fatalf("Convert() produced an invalid file: %s", err)
+	}
+
+	// The exit to room 9999 and the object put into a container that was
+	// never placed
+	if len(warnings) != 2 {
+		t.Errorf("Convert() gave warnings %q, want 2", warnings)
+	}
+
+	temple, altar, shop := file.Rooms[0], file.Rooms[1], file.Rooms[2]
+
+	if temple.Location != (types.Coordinate{X: 0, Y: 0, Z: 0}) ||
+		altar.Location != (types.Coordinate{X: 0, Y: -1, Z: 0}) ||
+		shop.Location != (types.Coordinate{X: 1, Y: 0, Z: 0}) {
+		t.Errorf("Rooms laid out at %v, %v and %v", temple.Location, altar.Location, shop.Location)
+	}
+
+	if len(temple.Locked) != 1 || temple.Locked[0] != types.DirectionEast {
+		t.Errorf("Temple locked exits == %v, want East", temple.Locked)
+	}
+
+	if len(temple.Doors) != 1 || temple.Doors[0].Name != "door" || temple.Doors[0].Key != "object3011" ||
+		!temple.Doors[0].Closed || temple.Doors[0].Difficulty == 0 {
+		t.Errorf("Temple doors == %+v", temple.Doors)
+	}
+
+	if len(shop.Doors) != 1 || shop.Doors[0].Key != "" || shop.Doors[0].Closed {
+		t.Errorf("Shop doors == %+v", shop.Doors)
+	}
+
+	if len(temple.Flags) != 2 || temple.Flags[0] != types.RoomIndoors || temple.Flags[1] != types.RoomSafe {
+		t.Errorf("Temple flags == %v, want Indoors and Safe", temple.Flags)
+	}
+
+	if altar.Terrain != types.TerrainMountain || len(altar.Flags) != 1 || altar.Flags[0] != types.RoomDark {
+		t.Errorf("Altar terrain == %v and flags == %v, want Mountain and Dark", altar.Terrain, altar.Flags)
+	}
+
+	if len(altar.Items) != 1 || altar.Items[0].Template != "object3010" {
+		t.Errorf("Altar items == %+v", altar.Items)
+	}
+
+	if len(shop.Items) != 1 || shop.Items[0].Template != "object3011" ||
+		len(shop.Items[0].Contents) != 1 || shop.Items[0].Contents[0] != "object3010" {
+		t.Errorf("Shop items == %+v", shop.Items)
+	}
+
+	if temple.Description != "You are in the temple. It is very quiet." {
+		t.Errorf("Temple description == %q", temple.Description)
+	}
+
+	guard := file.Spawners[1]
+	if guard.Name != "cityguard" || guard.Count != 2 || len(guard.Rooms) != 2 || guard.Health != 63 ||
+		len(guard.Equipment) != 1 || guard.Roaming {
+		t.Errorf("Guard spawner == %+v", guard)
+	}
+
+	if shop.Extras["counter"] != "A wooden counter." {
+		t.Errorf("Shop extra descriptions == %q", shop.Extras)
+	}
+
+	if sword := file.Templates[0]; sword.Extras["sword"] != "It is a long sword." || sword.Extras["long"] == "" {
+		t.Errorf("Sword extra descriptions == %q", sword.Extras)
+	}
+
+	if len(file.Stores) != 1 {
+		t.Fatalf("Convert() made %v stores, want 1", len(file.Stores))
+	}
+
+	store := file.Stores[0]
+	if store.Room != shop.Id || len(store.Stock) != 1 || store.SellRate != 120 || store.BuyRate != 50 ||
+		len(store.Categories) != 1 || store.Categories[0] != "container" {
+		t.Errorf("Store == %+v", store)
+	}
+}
+
+func Test_UniqueName(t *testing.T) {
+	taken := map[string]bool{}
+	tests := []struct {
+		short  string
+		vnum   int
+		output string
+	}{
+		{"a long sword", 3010, "long sword"},
+		{"the long sword", 3012, "long sword 3012"},
+		{"A Long Sword", 3013, "Long Sword 3013"},
+		{"a bag", 3011, "bag"},
+	}
+
+	for _, test := range tests {
+		result := uniqueName(taken, test.short, test.vnum)
+		if result != test.output {
+			t.Errorf("uniqueName(%q, %v) == %q, want %q", test.short, test.vnum, result, test.output)
+		}
+	}
+}
+
+func Test_Layout(t *testing.T) {
+	// Two rooms that both claim to be north of the first one, and a room
+	// that isn't connected to anything
+	rooms := []Room{
+		{Vnum: 1, Exits: []Exit{{Direction: 0, To: 2}, {Direction: 0, To: 3}}},
+		{Vnum: 2},
+		{Vnum: 3},
+		{Vnum: 4},
+	}
+
+	locations := Layout(rooms)
+
+	seen := map[types.Coordinate]bool{}
+	for vnum, location := range locations {
+		if seen[location] {
+			t.Errorf("Room %v shares location %v", vnum, location)
+		}
+		seen[location] = true
+	}
+
+	if len(locations) != 4 {
+		t.Errorf("Layout() placed %v rooms, want 4", len(locations))
+	}
+
+	if locations[2] != (types.Coordinate{X: 0, Y: -1, Z: 0}) {
+		t.Errorf("Room 2 placed at %v", locations[2])
+	}
+}
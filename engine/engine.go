@@ -5,7 +5,9 @@ import (
 
 	"github.com/Cristofori/kmud/combat"
 	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/group"
 	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/script"
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
 )
@@ -22,8 +24,16 @@ func Start() {
 	}
 
 	for _, spawner := range model.GetSpawners() {
-		manageSpawner(spawner)
+		StartSpawner(spawner)
 	}
+
+	for _, room := range model.GetRooms() {
+		WatchRoom(room)
+	}
+
+	manageStores()
+	manageSanctions()
+	script.Start()
 }
 
 func manageWorld() {
@@ -35,11 +45,26 @@ func manageWorld() {
 
 	go func() {
 		defer events.Unregister(wer)
+
+		phase := world.GetPhase()
+		ticks := 0
+
 		for {
 			event := <-eventChannel
 			switch event.(type) {
 			case events.TickEvent:
 				world.AdvanceTime()
+
+				if next := world.GetPhase(); next != phase {
+					phase = next
+					events.Broadcast(events.TimeEvent{Phase: phase})
+					tellOutdoors(nil, phaseMessages[phase])
+				}
+
+				ticks++
+				if ticks%weatherInterval == 0 {
+					changeWeather()
+				}
 			}
 		}
 	}()
@@ -57,13 +82,15 @@ func manageNpc(npc types.NPC) {
 			case events.TickEvent:
 				if npc.GetRoaming() {
 					room := model.GetRoom(npc.GetRoomId())
-					exits := room.GetExits()
-					exitToTake := utils.Random(0, len(exits)-1)
-					model.MoveCharacter(npc, exits[exitToTake])
+					exits := model.VisibleExits(npc, room)
+					if len(exits) > 0 {
+						exitToTake := utils.Random(0, len(exits)-1)
+						model.MoveCharacter(npc, exits[exitToTake])
+					}
 				}
 			case events.CombatStartEvent:
 				if npc == e.Defender {
-					combat.StartFight(npc, nil, e.Attacker)
+					combat.StartFight(npc, pickSkill(npc), e.Attacker)
 				}
 			case events.CombatStopEvent:
 				if npc == e.Defender {
@@ -71,6 +98,21 @@ func manageNpc(npc types.NPC) {
 				}
 			case events.DeathEvent:
 				if npc == e.Character {
+					if npc.GetSpawnerId() != nil {
+						spawner := model.GetSpawner(npc.GetSpawnerId())
+						if spawner != nil {
+							spawner.RecordDeath()
+							if spawner.GetLoot().OnDeath {
+								model.RollLoot(spawner.GetLoot(), npc)
+							}
+						}
+					}
+
+					if killer, ok := e.Killer.(types.PC); ok {
+						group.ShareExperience(killer, npc.GetHealth())
+					}
+
+					model.DropInventory(npc)
 					model.DeleteCharacter(npc.GetId())
 					return
 				}
@@ -79,26 +121,91 @@ func manageNpc(npc types.NPC) {
 	}()
 }
 
-func manageSpawner(spawner types.Spawner) {
+// Picks one of the NPC's skills at random, or nil if it doesn't have any
+func pickSkill(npc types.NPC) types.Skill {
+	skills := npc.GetSkills()
+	if len(skills) == 0 {
+		return nil
+	}
+	return model.GetSkill(skills[utils.Random(0, len(skills)-1)])
+}
+
+// StartSpawner begins populating the spawner's rooms. The spawner keeps
+// running until it is deleted.
+func StartSpawner(spawner types.Spawner) {
 	throttler := utils.NewThrottler(5 * time.Second)
+	go func() {
+		for !spawner.IsDestroyed() {
+			if !spawner.IsPaused() && isScheduled(spawner) {
+				spawn(spawner)
+			}
+
+			throttler.Sync()
+		}
+	}()
+}
+
+func isScheduled(spawner types.Spawner) bool {
+	switch spawner.GetSchedule() {
+	case types.SpawnDay:
+		return model.GetWorld().IsDaytime()
+	case types.SpawnNight:
+		return !model.GetWorld().IsDaytime()
+	}
+	return true
+}
+
+func spawn(spawner types.Spawner) {
+	npcs := model.GetSpawnerNpcs(spawner.GetId())
+	diff := spawner.GetCount() - len(npcs) - spawner.PendingRespawns()
+
+	population := map[types.Id]int{}
+	for _, npc := range npcs {
+		population[npc.GetRoomId()]++
+	}
+
+	max := spawner.GetMaxPerRoom()
+
+	for diff > 0 {
+		var rooms types.RoomList
+		for _, room := range model.GetSpawnerRooms(spawner) {
+			if max == 0 || population[room.GetId()] < max {
+				rooms = append(rooms, room)
+			}
+		}
+
+		if len(rooms) == 0 {
+			return
+		}
+
+		room := rooms[utils.Random(0, len(rooms)-1)]
+		npc := model.SpawnNpc(spawner, room.GetId())
+		manageNpc(npc)
+
+		population[room.GetId()]++
+		diff--
+	}
+}
+
+func manageStores() {
+	throttler := utils.NewThrottler(10 * time.Second)
 	go func() {
 		for {
-			rooms := model.GetAreaRooms(spawner.GetAreaId())
-
-			if len(rooms) > 0 {
-				npcs := model.GetSpawnerNpcs(spawner.GetId())
-				diff := spawner.GetCount() - len(npcs)
-
-				for diff > 0 && len(rooms) > 0 {
-					room := rooms[utils.Random(0, len(rooms)-1)]
-					npc := model.CreateNpc(spawner.GetName(), room.GetId(), spawner.GetId())
-					npc.SetHealth(spawner.GetHealth())
-					manageNpc(npc)
-					diff--
-				}
+			for _, store := range model.GetStores() {
+				model.RestockStore(store, false)
 			}
 
 			throttler.Sync()
 		}
 	}()
 }
+
+func manageSanctions() {
+	throttler := utils.NewThrottler(10 * time.Second)
+	go func() {
+		for {
+			model.ExpireSanctions()
+			throttler.Sync()
+		}
+	}()
+}
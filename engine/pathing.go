@@ -49,9 +49,10 @@ func reconstruct(cameFrom map[types.Room]types.Room, current types.Room) []types
 
 }
 
-// A* pathfinding algorithm, adapted from wikipedia's pseudocode
+// A* pathfinding algorithm, adapted from wikipedia's pseudocode. Only exits
+// that the character can see and is allowed through are followed.
 // TODO - Find out what happens if the rooms aren't in the same zone
-func FindPath(start, goal types.Room) []types.Room {
+func FindPath(character types.Character, start, goal types.Room) []types.Room {
 	/*
 		t1 := time.Now()
 		defer func() {
@@ -85,7 +86,7 @@ func FindPath(start, goal types.Room) []types.Room {
 		delete(unevaluated, current)
 		evaluated[current] = true
 
-		neighbors := model.GetNeighbors(current)
+		neighbors := model.GetNeighbors(character, current)
 
 		for _, neighbor := range neighbors {
 			_, found := evaluated[neighbor]
@@ -98,7 +99,7 @@ func FindPath(start, goal types.Room) []types.Room {
 				g_score[neighbor] = math.MaxInt32
 			}
 
-			tentative_g_score := g_score[current] + 1 // TODO - Update this when there is a travel penalty between rooms
+			tentative_g_score := g_score[current] + neighbor.GetTerrain().MovementCost()
 
 			_, found = unevaluated[neighbor]
 
@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/Cristofori/kmud/combat"
+	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/types"
+)
+
+// roomReceiver listens to what happens in a room on behalf of its triggers
+type roomReceiver struct {
+	room types.Room
+}
+
+func (self *roomReceiver) GetId() types.Id {
+	return self.room.GetId()
+}
+
+func (self *roomReceiver) GetRoomId() types.Id {
+	return self.room.GetId()
+}
+
+var watchedRooms = map[types.Id]bool{}
+var watchedRoomsMutex sync.Mutex
+
+func hasTriggers(room types.Room) bool {
+	for _, action := range room.GetActions() {
+		if action.Trigger == types.TriggerEnter || action.Trigger == types.TriggerLeave {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchRoom starts running the room's enter and leave triggers. It needs to
+// be called whenever a trigger is added to a room, and stops by itself once
+// the room no longer has any.
+func WatchRoom(room types.Room) {
+	if !hasTriggers(room) {
+		return
+	}
+
+	watchedRoomsMutex.Lock()
+	defer watchedRoomsMutex.Unlock()
+
+	if watchedRooms[room.GetId()] {
+		return
+	}
+	watchedRooms[room.GetId()] = true
+
+	receiver := &roomReceiver{room: room}
+	eventChannel := events.Register(receiver)
+
+	go func() {
+		defer func() {
+			watchedRoomsMutex.Lock()
+			delete(watchedRooms, room.GetId())
+			watchedRoomsMutex.Unlock()
+		}()
+		defer events.Unregister(receiver)
+
+		for {
+			event := <-eventChannel
+
+			if room.IsDestroyed() || !hasTriggers(room) {
+				return
+			}
+
+			switch e := event.(type) {
+			case events.EnterEvent:
+				if !e.Triggered {
+					runTriggers(room, types.TriggerEnter, e.Character)
+				}
+			case events.LeaveEvent:
+				if !e.Triggered {
+					runTriggers(room, types.TriggerLeave, e.Character)
+				}
+			}
+		}
+	}()
+}
+
+func runTriggers(room types.Room, trigger types.ActionTrigger, character types.Character) {
+	for _, action := range room.GetActions() {
+		if action.Trigger == trigger {
+			runRoomAction(action, character, room, true)
+		}
+	}
+}
+
+// FindRoomAction returns the room's command action matching the input, or
+// false if there isn't one
+func FindRoomAction(room types.Room, input string) (types.RoomAction, bool) {
+	for _, action := range room.GetActions() {
+		if action.Matches(input) {
+			return action, true
+		}
+	}
+	return types.RoomAction{}, false
+}
+
+// RunRoomAction carries out each of the action's responses in turn. Responses
+// that refer to exits, rooms, templates or effects that no longer exist are
+// skipped.
+func RunRoomAction(action types.RoomAction, character types.Character, room types.Room) {
+	runRoomAction(action, character, room, false)
+}
+
+// Teleports done for enter and leave triggers don't set off any more of them,
+// so that rooms can't keep sending a character back and forth
+func runRoomAction(action types.RoomAction, character types.Character, room types.Room, triggered bool) {
+	for _, response := range action.Responses {
+		switch response.Kind {
+		case types.ResponseMessage:
+			events.Broadcast(events.ActionEvent{
+				Character:   character,
+				RoomId:      room.GetId(),
+				Message:     response.Message,
+				RoomMessage: response.RoomMessage,
+			})
+		case types.ResponseLock, types.ResponseUnlock, types.ResponseToggle:
+			if _, found := room.GetDoor(response.Direction); found {
+				locked := response.Kind == types.ResponseLock ||
+					response.Kind == types.ResponseToggle && !room.IsLocked(response.Direction)
+				model.SetExitLocked(room, response.Direction, locked)
+			}
+		case types.ResponseOpen, types.ResponseClose:
+			if _, found := room.GetDoor(response.Direction); found {
+				model.SetExitClosed(room, response.Direction, response.Kind == types.ResponseClose)
+			}
+		case types.ResponseTeleport:
+			// Teleporting a character to where they already are would only set
+			// off the room's enter triggers again
+			if response.Target == nil || response.Target == character.GetRoomId() {
+				continue
+			}
+
+			destination := model.GetRoom(response.Target)
+			if destination != nil && model.CanTeleport(model.GetRoom(character.GetRoomId()), destination) {
+				if triggered {
+					model.MoveCharacterByTrigger(character, destination)
+				} else {
+					model.MoveCharacterToRoom(character, destination)
+				}
+			}
+		case types.ResponseItem:
+			if response.Target != nil && model.GetTemplate(response.Target) != nil {
+				item := model.CreateItem(response.Target)
+				item.SetContainerId(character.GetId(), nil)
+			}
+		case types.ResponseEffect:
+			if response.Target != nil {
+				if effect := model.GetEffect(response.Target); effect != nil {
+					applyEffect(effect, character)
+				}
+			}
+		}
+	}
+}
+
+// Only hitpoint effects mean anything outside of combat. A positive power
+// hurts, while a negative one heals.
+func applyEffect(effect types.Effect, character types.Character) {
+	if effect.GetType() != types.HitpointEffect {
+		return
+	}
+
+	power := effect.GetPower()
+	if power < 0 {
+		character.Heal(-power)
+		return
+	}
+
+	character.Hit(power)
+	if character.GetHitPoints() <= 0 {
+		combat.Kill(character)
+	}
+}
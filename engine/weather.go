@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/utils"
+)
+
+// How often each zone's weather gets a chance to change, in ticks
+const weatherInterval = 300
+
+var phaseMessages = map[types.DayPhase]string{
+	types.PhaseDawn:  "The sun rises in the east.",
+	types.PhaseDay:   "The day has begun.",
+	types.PhaseDusk:  "The sun slowly disappears in the west.",
+	types.PhaseNight: "The night has begun.",
+}
+
+func weatherMessage(from, to types.Weather) string {
+	switch to {
+	case types.WeatherClear:
+		return "The clouds disappear."
+	case types.WeatherCloudy:
+		if from == types.WeatherClear {
+			return "The sky is getting cloudy."
+		}
+		return "The rain stops."
+	case types.WeatherRain:
+		if from == types.WeatherCloudy {
+			return "It starts to rain."
+		}
+		return "The storm dies down, leaving only the rain."
+	case types.WeatherStorm:
+		return "Lightning flashes across the sky as a storm breaks."
+	}
+	return ""
+}
+
+// Moves the weather a step clearer or stormier, or leaves it as it is
+func nextWeather(weather types.Weather) types.Weather {
+	i := 0
+	for j, w := range types.Weathers {
+		if w == weather {
+			i = j
+		}
+	}
+
+	switch roll := utils.Random(1, 100); {
+	case roll <= 15 && i < len(types.Weathers)-1:
+		i++
+	case roll > 85 && i > 0:
+		i--
+	}
+
+	return types.Weathers[i]
+}
+
+func changeWeather() {
+	for _, zone := range model.GetZones() {
+		weather := zone.GetWeather()
+		next := nextWeather(weather)
+
+		if next != weather {
+			zone.SetWeather(next)
+			events.Broadcast(events.WeatherEvent{ZoneId: zone.GetId(), Weather: next})
+			tellOutdoors(zone.GetId(), weatherMessage(weather, next))
+		}
+	}
+}
+
+// Shows the message to every player outside in the zone, or in every zone if
+// zoneId is nil
+func tellOutdoors(zoneId types.Id, message string) {
+	rooms := map[types.Id]bool{}
+	for _, pc := range model.GetOnlinePlayerCharacters() {
+		rooms[pc.GetRoomId()] = true
+	}
+
+	for id := range rooms {
+		room := model.GetRoom(id)
+		if room == nil || room.HasFlag(types.RoomIndoors) {
+			continue
+		}
+
+		if zoneId != nil && room.GetZoneId() != zoneId {
+			continue
+		}
+
+		events.Broadcast(events.SkyEvent{RoomId: id, Message: message})
+	}
+}
@@ -2,6 +2,7 @@ package events
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/Cristofori/kmud/database"
@@ -25,6 +26,16 @@ func (*SimpleReceiver) GetRoomId() types.Id {
 	return nil
 }
 
+// ObserverReceiver gets every event, whoever it was meant for
+type ObserverReceiver struct {
+	SimpleReceiver
+}
+
+func isObserver(receiver EventReceiver) bool {
+	_, ok := receiver.(*ObserverReceiver)
+	return ok
+}
+
 type eventListener struct {
 	Channel  chan Event
 	Receiver EventReceiver
@@ -71,7 +82,7 @@ func init() {
 				delete(_listeners, msg.Receiver)
 			case broadcast:
 				for char, channel := range _listeners {
-					if msg.Event.IsFor(char) {
+					if (msg.Event.IsFor(char) || isObserver(char)) && !isIgnored(char, msg.Event) {
 						go func(c chan Event) {
 							c <- msg.Event
 						}(channel)
@@ -100,6 +111,25 @@ type Event interface {
 	IsFor(receiver EventReceiver) bool
 }
 
+// sourcedEvent is implemented by events that were caused by something a
+// character said or did to someone else
+type sourcedEvent interface {
+	Source() types.Character
+}
+
+// ignorer is implemented by receivers that can choose not to hear from
+// certain characters
+type ignorer interface {
+	IsIgnoring(types.Character) bool
+}
+
+func isIgnored(receiver EventReceiver, event Event) bool {
+	sourced, ok1 := event.(sourcedEvent)
+	ig, ok2 := receiver.(ignorer)
+
+	return ok1 && ok2 && ig.IsIgnoring(sourced.Source())
+}
+
 type TickEvent struct{}
 
 type CreateEvent struct {
@@ -112,6 +142,7 @@ type DestroyEvent struct {
 
 type DeathEvent struct {
 	Character types.Character
+	Killer    types.Character
 }
 
 type BroadcastEvent struct {
@@ -135,16 +166,20 @@ type TellEvent struct {
 	Message string
 }
 
+// Triggered is set on enter and leave events when a room trigger moved the
+// character, so that room triggers can't set each other off forever
 type EnterEvent struct {
 	Character types.Character
 	RoomId    types.Id
 	Direction types.Direction
+	Triggered bool
 }
 
 type LeaveEvent struct {
 	Character types.Character
 	RoomId    types.Id
 	Direction types.Direction
+	Triggered bool
 }
 
 type RoomUpdateEvent struct {
@@ -176,10 +211,111 @@ type CombatEvent struct {
 	Power    int
 }
 
-type LockEvent struct {
+type DoorChange string
+
+const (
+	DoorOpened   DoorChange = "opened"
+	DoorClosed   DoorChange = "closed"
+	DoorLocked   DoorChange = "locked"
+	DoorUnlocked DoorChange = "unlocked"
+)
+
+// DoorEvent is sent to each side of an exit when it's opened, closed, locked
+// or unlocked. Door is empty for exits without a door.
+type DoorEvent struct {
 	RoomId types.Id
 	Exit   types.Direction
-	Locked bool
+	Door   string
+	Change DoorChange
+}
+
+// ActionEvent is the output of a room action. Message is shown to the
+// character who set the action off and RoomMessage to everyone else in the
+// room, with $n replaced by the character's name.
+type ActionEvent struct {
+	Character   types.Character
+	RoomId      types.Id
+	Message     string
+	RoomMessage string
+}
+
+// EchoEvent is a message from a script, shown to one character if To is set
+// or to everyone in the room otherwise
+type EchoEvent struct {
+	RoomId  types.Id
+	To      types.Character
+	Message string
+}
+
+// SkyEvent tells everyone in an outdoor room about the sun or the weather
+type SkyEvent struct {
+	RoomId  types.Id
+	Message string
+}
+
+// TimeEvent is sent to observers when the world moves into a new part of the
+// day
+type TimeEvent struct {
+	Phase types.DayPhase
+}
+
+// WeatherEvent is sent to observers when a zone's weather changes
+type WeatherEvent struct {
+	ZoneId  types.Id
+	Weather types.Weather
+}
+
+type TradeRequestEvent struct {
+	From types.Character
+	To   types.Character
+}
+
+type ChannelEvent struct {
+	Channel types.Channel
+	From    types.Character
+	Message string
+}
+
+type SanctionEvent struct {
+	Sanction  types.Sanction
+	Character types.Character
+}
+
+type MailEvent struct {
+	Mail types.Mail
+}
+
+type GroupInviteEvent struct {
+	From types.Character
+	To   types.Character
+}
+
+type GroupEvent struct {
+	Members types.CharacterList
+	Message string
+}
+
+type GroupChatEvent struct {
+	From    types.Character
+	Members types.CharacterList
+	Message string
+}
+
+type ExperienceEvent struct {
+	Character types.Character
+	Amount    int
+}
+
+type TradeOffer struct {
+	Character types.Character
+	Items     []string
+	Cash      int
+	Confirmed bool
+}
+
+type TradeEvent struct {
+	Offers  [2]TradeOffer
+	Message string
 }
 
 func (self BroadcastEvent) ToString(receiver EventReceiver) string {
@@ -377,18 +513,257 @@ func (self DeathEvent) ToString(receiver EventReceiver) string {
 	return types.Colorize(types.ColorRed, fmt.Sprintf(">> %s has died", self.Character.GetName()))
 }
 
-// Lock
-func (self LockEvent) IsFor(receiver EventReceiver) bool {
+// Door
+func (self DoorEvent) IsFor(receiver EventReceiver) bool {
 	return receiver.GetRoomId() == self.RoomId
 }
 
-func (self LockEvent) ToString(receiver EventReceiver) string {
-	status := "unlocked"
-	if self.Locked {
-		status = "locked"
+func (self DoorEvent) ToString(receiver EventReceiver) string {
+	name := "exit"
+	if self.Door != "" {
+		name = self.Door
 	}
 
 	return types.Colorize(types.ColorBlue,
-		fmt.Sprintf("The exit to the %s has been %s", self.Exit.ToString(),
-			types.Colorize(types.ColorWhite, status)))
+		fmt.Sprintf("The %s to the %s has been %s", name, self.Exit.ToString(),
+			types.Colorize(types.ColorWhite, string(self.Change))))
+}
+
+// Action
+func (self ActionEvent) IsFor(receiver EventReceiver) bool {
+	if receiver == self.Character {
+		return self.Message != ""
+	}
+	return self.RoomMessage != "" && receiver.GetRoomId() == self.RoomId
+}
+
+func (self ActionEvent) ToString(receiver EventReceiver) string {
+	if receiver == self.Character {
+		return types.Colorize(types.ColorWhite, self.Message)
+	}
+	return types.Colorize(types.ColorWhite, strings.Replace(self.RoomMessage, "$n", self.Character.GetName(), -1))
+}
+
+// Echo
+func (self EchoEvent) IsFor(receiver EventReceiver) bool {
+	if self.To != nil {
+		return receiver == self.To
+	}
+	return receiver.GetRoomId() == self.RoomId
+}
+
+func (self EchoEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorWhite, self.Message)
+}
+
+// Sky
+func (self SkyEvent) IsFor(receiver EventReceiver) bool {
+	return receiver.GetRoomId() == self.RoomId
+}
+
+func (self SkyEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorYellow, self.Message)
+}
+
+// Time
+func (self TimeEvent) IsFor(receiver EventReceiver) bool {
+	return false
+}
+
+func (self TimeEvent) ToString(receiver EventReceiver) string {
+	return ""
+}
+
+// Weather
+func (self WeatherEvent) IsFor(receiver EventReceiver) bool {
+	return false
+}
+
+func (self WeatherEvent) ToString(receiver EventReceiver) string {
+	return ""
+}
+
+// TradeRequest
+func (self TradeRequestEvent) IsFor(receiver EventReceiver) bool {
+	return receiver == self.From || receiver == self.To
+}
+
+func (self TradeRequestEvent) ToString(receiver EventReceiver) string {
+	if receiver == self.From {
+		return types.Colorize(types.ColorGreen, fmt.Sprintf("You asked %s to trade", self.To.GetName()))
+	}
+
+	return types.Colorize(types.ColorGreen,
+		fmt.Sprintf("%s wants to trade with you, type 'trade %s' to accept", self.From.GetName(), self.From.GetName()))
+}
+
+// Trade
+func (self TradeEvent) IsFor(receiver EventReceiver) bool {
+	return receiver == self.Offers[0].Character || receiver == self.Offers[1].Character
+}
+
+func (self TradeEvent) ToString(receiver EventReceiver) string {
+	message := types.Colorize(types.ColorGreen, self.Message)
+
+	for _, offer := range self.Offers {
+		who := offer.Character.GetName() + " offers"
+		if receiver == offer.Character {
+			who = "You offer"
+		}
+
+		things := append([]string{}, offer.Items...)
+		if offer.Cash > 0 {
+			things = append(things, fmt.Sprintf("%v cash", offer.Cash))
+		}
+
+		if len(things) == 0 {
+			things = append(things, "nothing")
+		}
+
+		status := ""
+		if offer.Confirmed {
+			status = types.Colorize(types.ColorGreen, " (confirmed)")
+		}
+
+		message = fmt.Sprintf("%s\r\n  %s %s%s", message,
+			types.Colorize(types.ColorBlue, who+":"),
+			types.Colorize(types.ColorWhite, strings.Join(things, ", ")),
+			status)
+	}
+
+	return message
+}
+
+// Group invite
+func (self GroupInviteEvent) IsFor(receiver EventReceiver) bool {
+	return receiver == self.From || receiver == self.To
+}
+
+func (self GroupInviteEvent) ToString(receiver EventReceiver) string {
+	if receiver == self.From {
+		return types.Colorize(types.ColorGreen, fmt.Sprintf("You invited %s to join your group", self.To.GetName()))
+	}
+
+	return types.Colorize(types.ColorGreen,
+		fmt.Sprintf("%s has invited you to join their group, type 'group accept' to join", self.From.GetName()))
+}
+
+// Group
+func isMember(receiver EventReceiver, members types.CharacterList) bool {
+	for _, member := range members {
+		if receiver == member {
+			return true
+		}
+	}
+	return false
+}
+
+func (self GroupEvent) IsFor(receiver EventReceiver) bool {
+	return isMember(receiver, self.Members)
+}
+
+func (self GroupEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorGreen, "[Group] "+self.Message)
+}
+
+// Group chat
+func (self GroupChatEvent) IsFor(receiver EventReceiver) bool {
+	return isMember(receiver, self.Members)
+}
+
+func (self GroupChatEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorGreen, "[Group] "+self.From.GetName()+": ") +
+		types.Colorize(types.ColorWhite, self.Message)
+}
+
+// Experience
+func (self ExperienceEvent) IsFor(receiver EventReceiver) bool {
+	return receiver == self.Character
+}
+
+func (self ExperienceEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorYellow, fmt.Sprintf("You gain %v experience", self.Amount))
+}
+
+// Channel
+func (self ChannelEvent) IsFor(receiver EventReceiver) bool {
+	return receiver.GetId() != nil &&
+		self.Channel.IsMember(receiver.GetId()) &&
+		!self.Channel.IsMuted(receiver.GetId())
+}
+
+func (self ChannelEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(self.Channel.GetColor(), fmt.Sprintf("[%s] %s: ", self.Channel.GetName(), self.From.GetName())) +
+		types.Colorize(types.ColorWhite, self.Message)
+}
+
+// Mail
+func (self MailEvent) IsFor(receiver EventReceiver) bool {
+	pc, ok := receiver.(types.PC)
+	return ok && pc.GetUserId() == self.Mail.GetToUserId()
+}
+
+func (self MailEvent) ToString(receiver EventReceiver) string {
+	return types.Colorize(types.ColorYellow, fmt.Sprintf("You have new mail from %s", self.Mail.GetFromName()))
+}
+
+// Sanction
+func (self SanctionEvent) IsFor(receiver EventReceiver) bool {
+	return receiver == self.Character
+}
+
+func (self SanctionEvent) ToString(receiver EventReceiver) string {
+	message := fmt.Sprintf("You have been sanctioned (%s)", self.Sanction.GetKind())
+
+	switch self.Sanction.GetKind() {
+	case types.SanctionMute:
+		message = "You have been muted"
+	case types.SanctionJail:
+		message = "You have been jailed"
+	case types.SanctionBan:
+		message = "You have been banned"
+	}
+
+	if expires := self.Sanction.GetExpires(); !expires.IsZero() {
+		message = fmt.Sprintf("%s until %s", message, expires.Format("2006-01-02 15:04"))
+	}
+
+	if reason := self.Sanction.GetReason(); reason != "" {
+		message = message + ": " + reason
+	}
+
+	return types.Colorize(types.ColorRed, message)
+}
+
+// Sources of the events that can be ignored
+func (self TellEvent) Source() types.Character {
+	return self.From
+}
+
+func (self SayEvent) Source() types.Character {
+	return self.Character
+}
+
+func (self EmoteEvent) Source() types.Character {
+	return self.Character
+}
+
+func (self BroadcastEvent) Source() types.Character {
+	return self.Character
+}
+
+func (self ChannelEvent) Source() types.Character {
+	return self.From
+}
+
+func (self GroupChatEvent) Source() types.Character {
+	return self.From
+}
+
+func (self GroupInviteEvent) Source() types.Character {
+	return self.From
+}
+
+func (self TradeRequestEvent) Source() types.Character {
+	return self.From
 }
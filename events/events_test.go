@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/Cristofori/kmud/testutils"
+	"github.com/Cristofori/kmud/types"
 	. "gopkg.in/check.v1"
 )
 
@@ -41,3 +42,45 @@ func (s *EventSuite) TestEventLoop(c *C) {
 		c.Fatalf("Timed out waiting for tell event")
 	}
 }
+
+type ignoringPC struct {
+	*testutils.MockPC
+	ignored types.Character
+}
+
+func (self *ignoringPC) IsIgnoring(char types.Character) bool {
+	return char == self.ignored
+}
+
+func (s *EventSuite) TestIgnore(c *C) {
+	friend := testutils.NewMockPC()
+	griefer := testutils.NewMockPC()
+	char := &ignoringPC{MockPC: testutils.NewMockPC(), ignored: griefer}
+
+	c.Assert(isIgnored(char, TellEvent{From: griefer, To: char}), Equals, true)
+	c.Assert(isIgnored(char, SayEvent{Character: griefer}), Equals, true)
+	c.Assert(isIgnored(char, TellEvent{From: friend, To: char}), Equals, false)
+	c.Assert(isIgnored(char, DeathEvent{Character: griefer}), Equals, false)
+	c.Assert(isIgnored(friend, TellEvent{From: griefer, To: friend}), Equals, false)
+}
+
+func (s *EventSuite) TestObserver(c *C) {
+	observer := &ObserverReceiver{}
+	eventChannel := Register(observer)
+	defer Unregister(observer)
+
+	char := testutils.NewMockPC()
+	Broadcast(TellEvent{char, char, "private"})
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case event := <-eventChannel:
+			if _, ok := event.(TellEvent); ok {
+				return
+			}
+		case <-timeout:
+			c.Fatalf("Observer didn't get the tell event")
+		}
+	}
+}
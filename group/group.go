@@ -0,0 +1,274 @@
+package group
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/types"
+)
+
+type group struct {
+	leader    types.PC
+	members   types.PCList
+	following map[types.PC]bool
+}
+
+var invites map[types.PC]types.PC
+var groups map[types.PC]*group
+var mutex sync.Mutex
+
+func init() {
+	invites = map[types.PC]types.PC{}
+	groups = map[types.PC]*group{}
+}
+
+// Invite asks the target to join the inviter's group. If the inviter isn't in
+// a group yet then they will become the leader of a new one once the target
+// accepts.
+func Invite(from types.PC, to types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if from == to {
+		return errors.New("You can't invite yourself")
+	}
+
+	if g, found := groups[from]; found && g.leader != from {
+		return errors.New("Only the group leader can invite new members")
+	}
+
+	if _, found := groups[to]; found {
+		return errors.New("They are already in a group")
+	}
+
+	invites[to] = from
+	events.Broadcast(events.GroupInviteEvent{From: from, To: to})
+	return nil
+}
+
+// Accept joins the group of whoever most recently invited the character
+func Accept(pc types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	leader, found := invites[pc]
+	if !found {
+		return errors.New("You haven't been invited to a group")
+	}
+
+	delete(invites, pc)
+
+	if _, found := groups[pc]; found {
+		return errors.New("You are already in a group")
+	}
+
+	g, found := groups[leader]
+	if !found {
+		g = &group{leader: leader, members: types.PCList{leader}, following: map[types.PC]bool{}}
+		groups[leader] = g
+	} else if g.leader != leader {
+		return errors.New("That invitation is no longer valid")
+	}
+
+	g.members = append(g.members, pc)
+	groups[pc] = g
+
+	g.broadcast(pc.GetName() + " has joined the group")
+	return nil
+}
+
+// Decline turns down an outstanding group invitation
+func Decline(pc types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if _, found := invites[pc]; !found {
+		return errors.New("You haven't been invited to a group")
+	}
+
+	delete(invites, pc)
+	return nil
+}
+
+// Leave removes the character from their group. If the leader leaves then
+// leadership passes to the next member, and a group that is down to a single
+// member is disbanded.
+func Leave(pc types.PC) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	delete(invites, pc)
+
+	g, found := groups[pc]
+	if !found {
+		return
+	}
+
+	g.broadcast(pc.GetName() + " has left the group")
+	g.remove(pc)
+}
+
+// Disband breaks up the leader's group
+func Disband(pc types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	g, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	if g.leader != pc {
+		return errors.New("Only the group leader can disband the group")
+	}
+
+	g.broadcast("The group has been disbanded")
+	g.disband()
+	return nil
+}
+
+// Say sends a message to every member of the character's group
+func Say(pc types.PC, message string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	g, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	events.Broadcast(events.GroupChatEvent{From: pc, Members: g.members.Characters(), Message: message})
+	return nil
+}
+
+// Follow toggles whether or not the character automatically follows the group
+// leader when they move. Returns the new state.
+func Follow(pc types.PC) (bool, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	g, err := lookup(pc)
+	if err != nil {
+		return false, err
+	}
+
+	if g.leader == pc {
+		return false, errors.New("You are leading the group")
+	}
+
+	g.following[pc] = !g.following[pc]
+	return g.following[pc], nil
+}
+
+// IsFollowing returns true if the character is following the given leader
+func IsFollowing(pc types.PC, leader types.Character) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	g, found := groups[pc]
+	return found && g.leader == leader && g.following[pc]
+}
+
+// Leader returns the leader of the character's group, or nil if they aren't in one
+func Leader(pc types.PC) types.PC {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if g, found := groups[pc]; found {
+		return g.leader
+	}
+	return nil
+}
+
+// Members returns everybody in the character's group, including the character
+func Members(pc types.PC) types.PCList {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	g, found := groups[pc]
+	if !found {
+		return nil
+	}
+
+	members := make(types.PCList, len(g.members))
+	copy(members, g.members)
+	return members
+}
+
+// ShareExperience awards experience for a kill. If the killer is in a group
+// then it is split evenly between the members who are in the same room.
+func ShareExperience(killer types.PC, amount int) {
+	mutex.Lock()
+
+	recipients := types.PCList{killer}
+	if g, found := groups[killer]; found {
+		recipients = types.PCList{}
+		for _, member := range g.members {
+			if member.GetRoomId() == killer.GetRoomId() {
+				recipients = append(recipients, member)
+			}
+		}
+	}
+
+	mutex.Unlock()
+
+	share := amount / len(recipients)
+	if share < 1 {
+		share = 1
+	}
+
+	for _, pc := range recipients {
+		pc.AddExperience(share)
+		events.Broadcast(events.ExperienceEvent{Character: pc, Amount: share})
+	}
+}
+
+func lookup(pc types.PC) (*group, error) {
+	g, found := groups[pc]
+	if !found {
+		return nil, errors.New("You aren't in a group")
+	}
+	return g, nil
+}
+
+func (self *group) remove(pc types.PC) {
+	delete(groups, pc)
+	delete(self.following, pc)
+
+	for i, member := range self.members {
+		if member == pc {
+			self.members = append(self.members[:i], self.members[i+1:]...)
+			break
+		}
+	}
+
+	if len(self.members) < 2 {
+		self.disband()
+		return
+	}
+
+	if self.leader == pc {
+		self.leader = self.members[0]
+		delete(self.following, self.leader)
+		self.broadcast(self.leader.GetName() + " is now the group leader")
+	}
+}
+
+func (self *group) disband() {
+	for _, member := range self.members {
+		delete(groups, member)
+	}
+
+	for invitee, inviter := range invites {
+		if inviter == self.leader {
+			delete(invites, invitee)
+		}
+	}
+
+	self.members = nil
+}
+
+func (self *group) broadcast(message string) {
+	events.Broadcast(events.GroupEvent{Members: self.members.Characters(), Message: message})
+}
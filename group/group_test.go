@@ -0,0 +1,75 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/Cristofori/kmud/testutils"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type GroupSuite struct{}
+
+var _ = Suite(&GroupSuite{})
+
+func (s *GroupSuite) TestInvite(c *C) {
+	leader := testutils.NewMockPC()
+	member1 := testutils.NewMockPC()
+	member2 := testutils.NewMockPC()
+
+	c.Assert(Invite(leader, leader), Not(IsNil))
+	c.Assert(Accept(member1), Not(IsNil))
+
+	c.Assert(Invite(leader, member1), IsNil)
+	c.Assert(Members(leader), IsNil)
+
+	c.Assert(Accept(member1), IsNil)
+	c.Assert(Leader(member1), Equals, leader)
+	c.Assert(Members(leader), HasLen, 2)
+
+	c.Assert(Invite(member1, member2), Not(IsNil))
+	c.Assert(Invite(leader, member2), IsNil)
+	c.Assert(Decline(member2), IsNil)
+	c.Assert(Accept(member2), Not(IsNil))
+
+	Invite(leader, member2)
+	Accept(member2)
+	c.Assert(Members(member2), HasLen, 3)
+
+	Leave(leader)
+	c.Assert(Leader(member2), Equals, member1)
+	c.Assert(Members(member1), HasLen, 2)
+
+	c.Assert(Disband(member2), Not(IsNil))
+	c.Assert(Disband(member1), IsNil)
+	c.Assert(Members(member1), IsNil)
+	c.Assert(Members(member2), IsNil)
+}
+
+func (s *GroupSuite) TestFollow(c *C) {
+	leader := testutils.NewMockPC()
+	member := testutils.NewMockPC()
+
+	_, err := Follow(member)
+	c.Assert(err, Not(IsNil))
+
+	Invite(leader, member)
+	Accept(member)
+
+	_, err = Follow(leader)
+	c.Assert(err, Not(IsNil))
+
+	following, err := Follow(member)
+	c.Assert(err, IsNil)
+	c.Assert(following, Equals, true)
+	c.Assert(IsFollowing(member, leader), Equals, true)
+	c.Assert(IsFollowing(leader, member), Equals, false)
+
+	following, _ = Follow(member)
+	c.Assert(following, Equals, false)
+	c.Assert(IsFollowing(member, leader), Equals, false)
+
+	Leave(member)
+	c.Assert(Members(leader), IsNil)
+}
@@ -1,19 +1,40 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"math/rand"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"time"
 
+	"github.com/Cristofori/kmud/database"
+	"github.com/Cristofori/kmud/diku"
+	"github.com/Cristofori/kmud/model"
 	"github.com/Cristofori/kmud/server"
+	"github.com/Cristofori/kmud/worldfile"
 )
 
 func main() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
 	rand.Seed(time.Now().UnixNano())
 
+	exportZone := flag.String("export", "", "write the named zone to a world file and exit")
+	importFile := flag.String("import", "", "create a zone from the given world file or Diku area file (.are) and exit")
+	path := flag.String("file", "", "world file to export to (defaults to world/<zone>.json)")
+	zoneName := flag.String("zone", "", "name to give the imported zone (defaults to the name in the file)")
+	flag.Parse()
+
+	if *exportZone != "" {
+		os.Exit(export(*exportZone, *path))
+	}
+
+	if *importFile != "" {
+		os.Exit(importWorld(*importFile, *zoneName))
+	}
+
 	go signalHandler()
 
 	var s server.Server
@@ -32,3 +53,67 @@ func signalHandler() {
 		os.Exit(0)
 	}
 }
+
+func export(zoneName string, path string) int {
+	server.Connect()
+
+	zone := model.GetZoneByName(zoneName)
+	if zone == nil {
+		fmt.Println("Zone not found:", zoneName)
+		return 1
+	}
+
+	file, warnings := worldfile.Export(zone)
+	for _, warning := range warnings {
+		fmt.Println("Warning:", warning)
+	}
+
+	if path == "" {
+		path = worldfile.Path(zone.GetName())
+		os.MkdirAll(worldfile.Dir, 0755)
+	}
+
+	if err := worldfile.Save(path, file); err != nil {
+		fmt.Println("Export failed:", err)
+		return 1
+	}
+
+	fmt.Printf("Exported %v rooms to %s\n", len(file.Rooms), path)
+	return 0
+}
+
+func importWorld(path string, zoneName string) int {
+	server.Connect()
+
+	var file *worldfile.File
+	var err error
+
+	if filepath.Ext(path) == ".are" {
+		var warnings []string
+		file, warnings, err = diku.Load(path)
+		for _, warning := range warnings {
+			fmt.Println("Warning:", warning)
+		}
+	} else {
+		file, err = worldfile.Load(path)
+	}
+
+	if err != nil {
+		fmt.Println("Import failed:", err)
+		return 1
+	}
+
+	for _, conflict := range worldfile.Conflicts(file, zoneName) {
+		fmt.Println("Conflict:", conflict)
+	}
+
+	zone, err := worldfile.Import(file, zoneName)
+	if err != nil {
+		fmt.Println("Import failed:", err)
+		return 1
+	}
+
+	database.Flush()
+	fmt.Printf("Imported %s with %v rooms\n", zone.GetName(), len(file.Rooms))
+	return 0
+}
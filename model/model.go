@@ -4,6 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	db "github.com/Cristofori/kmud/database"
 	"github.com/Cristofori/kmud/events"
@@ -52,7 +55,8 @@ func DeleteUser(userId types.Id) {
 }
 
 func GetPlayerCharacter(id types.Id) types.PC {
-	return db.Retrieve(id, types.PcType).(types.PC)
+	pc, _ := db.Retrieve(id, types.PcType).(types.PC)
+	return pc
 }
 
 func GetNpc(id types.Id) types.NPC {
@@ -203,7 +207,8 @@ func CreateRoom(zone types.Zone, location types.Coordinate) (types.Room, error)
 }
 
 func GetRoom(id types.Id) types.Room {
-	return db.Retrieve(id, types.RoomType).(types.Room)
+	room, _ := db.Retrieve(id, types.RoomType).(types.Room)
+	return room
 }
 
 func GetRooms() types.RoomList {
@@ -240,10 +245,16 @@ func GetRoomByLocation(coordinate types.Coordinate, zoneId types.Id) types.Room
 	return nil
 }
 
-func GetNeighbors(room types.Room) []types.Room {
+// GetNeighbors returns the rooms that the character can get to from the room
+// through exits they can see and are allowed through, or through links
+func GetNeighbors(character types.Character, room types.Room) []types.Room {
 	neighbors := []types.Room{}
 
-	for _, dir := range room.GetExits() {
+	for _, dir := range VisibleExits(character, room) {
+		if CheckRestriction(character, room, dir) != nil {
+			continue
+		}
+
 		coords := room.NextLocation(dir)
 		neighbor := GetRoomByLocation(coords, room.GetZoneId())
 		if neighbor != nil {
@@ -303,7 +314,7 @@ func GetZoneByName(name string) types.Zone {
 }
 
 func GetAreas(zone types.Zone) types.AreaList {
-	ids := db.FindAll(types.AreaType)
+	ids := db.Find(types.AreaType, bson.M{"zoneid": zone.GetId()})
 	areas := make(types.AreaList, len(ids))
 	for i, id := range ids {
 		areas[i] = GetArea(id)
@@ -384,7 +395,8 @@ func DeleteRoom(room types.Room) {
 }
 
 func GetUser(id types.Id) types.User {
-	return db.Retrieve(id, types.UserType).(types.User)
+	user, _ := db.Retrieve(id, types.UserType).(types.User)
+	return user
 }
 
 func CreateTemplate(name string) types.Template {
@@ -401,8 +413,17 @@ func GetAllTemplates() types.TemplateList {
 	return templates
 }
 
+func GetTemplateByName(name string) types.Template {
+	id := FindObjectByName(name, types.TemplateType)
+	if id != nil {
+		return GetTemplate(id)
+	}
+	return nil
+}
+
 func GetTemplate(id types.Id) types.Template {
-	return db.Retrieve(id, types.TemplateType).(types.Template)
+	template, _ := db.Retrieve(id, types.TemplateType).(types.Template)
+	return template
 }
 
 func DeleteTemplate(id types.Id) {
@@ -473,6 +494,16 @@ func CharacterWeight(character types.Character) int {
 }
 
 func MoveCharacterToRoom(character types.Character, newRoom types.Room) {
+	moveCharacterToRoom(character, newRoom, false)
+}
+
+// MoveCharacterByTrigger moves the character for a room's enter or leave
+// trigger. The move doesn't set off the triggers of either room.
+func MoveCharacterByTrigger(character types.Character, newRoom types.Room) {
+	moveCharacterToRoom(character, newRoom, true)
+}
+
+func moveCharacterToRoom(character types.Character, newRoom types.Room, triggered bool) {
 	oldRoomId := character.GetRoomId()
 	character.SetRoomId(newRoom.GetId())
 
@@ -480,11 +511,246 @@ func MoveCharacterToRoom(character types.Character, newRoom types.Room) {
 
 	// Leave
 	dir := DirectionBetween(oldRoom, newRoom)
-	events.Broadcast(events.LeaveEvent{Character: character, RoomId: oldRoomId, Direction: dir})
+	events.Broadcast(events.LeaveEvent{Character: character, RoomId: oldRoomId, Direction: dir, Triggered: triggered})
 
 	// Enter
 	dir = DirectionBetween(newRoom, oldRoom)
-	events.Broadcast(events.EnterEvent{Character: character, RoomId: newRoom.GetId(), Direction: dir})
+	events.Broadcast(events.EnterEvent{Character: character, RoomId: newRoom.GetId(), Direction: dir, Triggered: triggered})
+}
+
+// Returns the room on the other side of the exit, or nil if there isn't one
+func otherSide(room types.Room, dir types.Direction) types.Room {
+	otherRoom := GetRoomByLocation(room.NextLocation(dir), room.GetZoneId())
+	if otherRoom != nil && otherRoom.HasExit(dir.Opposite()) {
+		return otherRoom
+	}
+	return nil
+}
+
+// Makes the change to both sides of the exit, and lets everyone in the two
+// rooms know about it
+func changeExit(room types.Room, dir types.Direction, change events.DoorChange, apply func(types.Room, types.Direction)) {
+	apply(room, dir)
+	broadcastDoorChange(room, dir, change)
+
+	if otherRoom := otherSide(room, dir); otherRoom != nil {
+		apply(otherRoom, dir.Opposite())
+		broadcastDoorChange(otherRoom, dir.Opposite(), change)
+	}
+}
+
+func broadcastDoorChange(room types.Room, dir types.Direction, change events.DoorChange) {
+	door, _ := room.GetDoor(dir)
+	events.Broadcast(events.DoorEvent{RoomId: room.GetId(), Exit: dir, Door: door.Name, Change: change})
+}
+
+// SetExitLocked locks or unlocks the exit on both sides
+func SetExitLocked(room types.Room, dir types.Direction, locked bool) {
+	change := events.DoorUnlocked
+	if locked {
+		change = events.DoorLocked
+	}
+
+	changeExit(room, dir, change, func(r types.Room, d types.Direction) {
+		r.SetLocked(d, locked)
+	})
+}
+
+// SetExitClosed opens or closes the door on both sides of the exit
+func SetExitClosed(room types.Room, dir types.Direction, closed bool) {
+	change := events.DoorOpened
+	if closed {
+		change = events.DoorClosed
+	}
+
+	changeExit(room, dir, change, func(r types.Room, d types.Direction) {
+		r.SetClosed(d, closed)
+	})
+}
+
+// SetDoor puts the same door on both sides of the exit
+func SetDoor(room types.Room, dir types.Direction, door types.Door) {
+	room.SetDoor(dir, door)
+	if otherRoom := otherSide(room, dir); otherRoom != nil {
+		otherRoom.SetDoor(dir.Opposite(), door)
+	}
+}
+
+// AddDefaultDoors puts the default door in each exit that was locked before
+// exits had doors, keeping it locked on both sides
+func AddDefaultDoors() {
+	for _, room := range GetRooms() {
+		for _, dir := range room.GetExits() {
+			if _, found := room.GetDoor(dir); found || !room.IsLocked(dir) {
+				continue
+			}
+
+			door := types.DefaultDoor
+			otherRoom := otherSide(room, dir)
+			if otherRoom != nil {
+				if otherDoor, found := otherRoom.GetDoor(dir.Opposite()); found {
+					door = otherDoor
+				}
+			}
+
+			SetDoor(room, dir, door)
+			room.SetLocked(dir, true)
+			if otherRoom != nil {
+				otherRoom.SetLocked(dir.Opposite(), true)
+			}
+		}
+	}
+}
+
+// RemoveDoor takes the door out of both sides of the exit
+func RemoveDoor(room types.Room, dir types.Direction) {
+	room.RemoveDoor(dir)
+	if otherRoom := otherSide(room, dir); otherRoom != nil {
+		otherRoom.RemoveDoor(dir.Opposite())
+	}
+}
+
+type revealedExit struct {
+	roomId types.Id
+	dir    types.Direction
+}
+
+// The hidden exits that each character has found by searching. They're
+// forgotten when the server restarts.
+var revealed = map[types.Id]map[revealedExit]bool{}
+var revealedMutex sync.Mutex
+
+// IsLit returns false for dark rooms without a light in them, either lying
+// around or carried by someone
+func IsLit(room types.Room) bool {
+	if !room.HasFlag(types.RoomDark) {
+		return true
+	}
+
+	items := ItemsIn(room.GetId())
+	for _, character := range CharactersIn(room.GetId()) {
+		items = append(items, ItemsIn(character.GetId())...)
+	}
+
+	for _, item := range items {
+		if template := GetTemplate(item.GetTemplateId()); template != nil && template.IsLight() {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTeleport returns false if either room keeps out teleporting
+func CanTeleport(from, to types.Room) bool {
+	return !from.HasFlag(types.RoomNoTeleport) && !to.HasFlag(types.RoomNoTeleport)
+}
+
+// Recall takes the character back to the starting room
+func Recall(character types.Character) error {
+	room := GetRoom(character.GetRoomId())
+	start := GetStartingRoom()
+
+	if room.HasFlag(types.RoomNoRecall) || !CanTeleport(room, start) {
+		return errors.New("You can't recall from here")
+	}
+
+	if pc, ok := character.(types.PC); ok && GetSanction(pc.GetUserId(), types.SanctionJail) != nil {
+		return errors.New("You can't leave while you are in jail")
+	}
+
+	if room.GetId() != start.GetId() {
+		MoveCharacterToRoom(character, start)
+	}
+	return nil
+}
+
+// RevealExit lets the character see and use a hidden exit
+func RevealExit(character types.Character, room types.Room, dir types.Direction) {
+	revealedMutex.Lock()
+	defer revealedMutex.Unlock()
+
+	if revealed[character.GetId()] == nil {
+		revealed[character.GetId()] = map[revealedExit]bool{}
+	}
+	revealed[character.GetId()][revealedExit{room.GetId(), dir}] = true
+}
+
+// CanSeeExit returns false if the room has no exit in the direction, or if
+// the exit is hidden and the character hasn't found it yet
+func CanSeeExit(character types.Character, room types.Room, dir types.Direction) bool {
+	if !room.HasExit(dir) {
+		return false
+	}
+
+	if !room.IsHidden(dir) {
+		return true
+	}
+
+	revealedMutex.Lock()
+	defer revealedMutex.Unlock()
+	return revealed[character.GetId()][revealedExit{room.GetId(), dir}]
+}
+
+// VisibleExits lists the room's exits which the character can see
+func VisibleExits(character types.Character, room types.Room) []types.Direction {
+	var exits []types.Direction
+	for _, dir := range room.GetExits() {
+		if CanSeeExit(character, room, dir) {
+			exits = append(exits, dir)
+		}
+	}
+	return exits
+}
+
+// Carries returns true if the character is holding an item made from the
+// template
+func Carries(character types.Character, templateId types.Id) bool {
+	for _, item := range ItemsIn(character.GetId()) {
+		if item.GetTemplateId() == templateId {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRestriction returns an error saying why the character isn't allowed
+// through the exit, or nil if they are
+func CheckRestriction(character types.Character, room types.Room, dir types.Direction) error {
+	restriction := room.GetRestriction(dir)
+
+	if character.GetExperience() < restriction.MinExperience {
+		return errors.New("You aren't experienced enough to go that way")
+	}
+
+	if restriction.SkillId != nil && !character.HasSkill(restriction.SkillId) {
+		if skill := GetSkill(restriction.SkillId); skill != nil {
+			return fmt.Errorf("You need to know %s to go that way", skill.GetName())
+		}
+	}
+
+	if restriction.TemplateId != nil && !Carries(character, restriction.TemplateId) {
+		if template := GetTemplate(restriction.TemplateId); template != nil {
+			return fmt.Errorf("You need %s to go that way", template.GetName())
+		}
+	}
+
+	return nil
+}
+
+// SetOneWay removes or restores the exit leading back from the room on the
+// other side
+func SetOneWay(room types.Room, dir types.Direction, oneWay bool) {
+	otherRoom := GetRoomByLocation(room.NextLocation(dir), room.GetZoneId())
+	if otherRoom != nil {
+		otherRoom.SetExitEnabled(dir.Opposite(), !oneWay)
+	}
+}
+
+// IsOneWay returns true if there's a room on the other side of the exit
+// with no way back
+func IsOneWay(room types.Room, dir types.Direction) bool {
+	otherRoom := GetRoomByLocation(room.NextLocation(dir), room.GetZoneId())
+	return otherRoom != nil && !otherRoom.HasExit(dir.Opposite())
 }
 
 func MoveCharacter(character types.Character, direction types.Direction) error {
@@ -494,14 +760,26 @@ func MoveCharacter(character types.Character, direction types.Direction) error {
 		return errors.New("Character doesn't appear to be in any room")
 	}
 
-	if !room.HasExit(direction) {
+	if !CanSeeExit(character, room, direction) {
 		return errors.New("Attempted to move through an exit that the room does not contain")
 	}
 
+	if door, found := room.GetDoor(direction); found && room.IsClosed(direction) {
+		return fmt.Errorf("The %s is closed", door.Name)
+	}
+
 	if room.IsLocked(direction) {
 		return errors.New("That way is locked")
 	}
 
+	if err := CheckRestriction(character, room, direction); err != nil {
+		return err
+	}
+
+	if pc, ok := character.(types.PC); ok && GetSanction(pc.GetUserId(), types.SanctionJail) != nil {
+		return errors.New("You can't leave while you are in jail")
+	}
+
 	newLocation := room.NextLocation(direction)
 	newRoom := GetRoomByLocation(newLocation, room.GetZoneId())
 
@@ -553,13 +831,29 @@ func BroadcastMessage(from types.Character, message string) {
 }
 
 func Tell(from types.Character, to types.Character, message string) {
+	entry := types.HistoryEntry{Time: time.Now(), From: from.GetName(), To: to.GetName(), Message: message}
+	recordHistory(types.HistoryTell, entry, from, from, to)
+
 	events.Broadcast(events.TellEvent{From: from, To: to, Message: message})
 }
 
 func Say(from types.Character, message string) {
+	entry := types.HistoryEntry{Time: time.Now(), From: from.GetName(), Message: message}
+	recordHistory(types.HistorySay, entry, from, PlayerCharactersIn(from.GetRoomId(), nil).Characters()...)
+
 	events.Broadcast(events.SayEvent{Character: from, Message: message})
 }
 
+// Adds the entry to the history of each of the given characters that are
+// players, unless they're ignoring whoever it's from
+func recordHistory(kind types.HistoryKind, entry types.HistoryEntry, from types.Character, chars ...types.Character) {
+	for _, char := range chars {
+		if pc, ok := char.(types.PC); ok && !pc.IsIgnoring(from) {
+			pc.AddHistory(kind, entry)
+		}
+	}
+}
+
 func Emote(from types.Character, message string) {
 	events.Broadcast(events.EmoteEvent{Character: from, Emote: message})
 }
@@ -653,7 +947,8 @@ func GetSpawners() types.SpawnerList {
 }
 
 func GetSpawner(id types.Id) types.Spawner {
-	return db.Retrieve(id, types.SpawnerType).(types.Spawner)
+	spawner, _ := db.Retrieve(id, types.SpawnerType).(types.Spawner)
+	return spawner
 }
 
 func GetAreaSpawners(areaId types.Id) types.SpawnerList {
@@ -665,6 +960,28 @@ func GetAreaSpawners(areaId types.Id) types.SpawnerList {
 	return spawners
 }
 
+// DeleteSpawner removes the spawner. NPCs it has already spawned are left alone.
+func DeleteSpawner(id types.Id) {
+	db.DeleteObject(id)
+}
+
+// GetSpawnerRooms returns the rooms the spawner places NPCs in, which is
+// either its specific set of rooms or, if it has none, every room in its area
+func GetSpawnerRooms(spawner types.Spawner) types.RoomList {
+	roomIds := spawner.GetRoomIds()
+	if len(roomIds) == 0 {
+		return GetAreaRooms(spawner.GetAreaId())
+	}
+
+	rooms := types.RoomList{}
+	for _, id := range roomIds {
+		if room := GetRoom(id); room != nil {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms
+}
+
 func GetSpawnerNpcs(spawnerId types.Id) types.NPCList {
 	ids := db.Find(types.NpcType, bson.M{"spawnerid": spawnerId})
 	npcs := make(types.NPCList, len(ids))
@@ -674,8 +991,84 @@ func GetSpawnerNpcs(spawnerId types.Id) types.NPCList {
 	return npcs
 }
 
+// SpawnNpc creates a new NPC in the given room using the spawner as a
+// template. Everything the spawner defines is copied to the new NPC, including
+// its skills, stats, behaviour, cash and equipment.
+func SpawnNpc(spawner types.Spawner, roomId types.Id) types.NPC {
+	npc := CreateNpc(spawner.GetName(), roomId, spawner.GetId())
+
+	npc.SetHealth(spawner.GetHealth())
+	npc.SetHitPoints(spawner.GetHealth())
+	npc.SetStrength(spawner.GetStrength())
+	npc.SetRoaming(spawner.GetRoaming())
+	npc.SetConversation(spawner.GetConversation())
+	npc.SetScript(spawner.GetScript())
+	npc.AddCash(spawner.GetCash())
+
+	for _, skillId := range spawner.GetSkills() {
+		npc.AddSkill(skillId)
+	}
+
+	for _, templateId := range spawner.GetEquipment() {
+		giveItem(templateId, npc)
+	}
+
+	if loot := spawner.GetLoot(); !loot.OnDeath {
+		RollLoot(loot, npc)
+	}
+
+	return npc
+}
+
+// RollLoot gives the character every guaranteed drop from the loot table, a
+// number of weighted random drops, and an amount of cash within the table's
+// range
+func RollLoot(loot types.LootTable, character types.Character) {
+	weights := make([]int, len(loot.Drops))
+
+	for i, drop := range loot.Drops {
+		if drop.Guaranteed {
+			giveItem(drop.TemplateId, character)
+		} else {
+			weights[i] = drop.Weight
+		}
+	}
+
+	for i := 0; i < loot.Rolls; i++ {
+		index := utils.WeightedRandom(weights)
+		if index == -1 {
+			break
+		}
+		giveItem(loot.Drops[index].TemplateId, character)
+	}
+
+	if loot.MaxCash > 0 {
+		character.AddCash(utils.Random(loot.MinCash, loot.MaxCash))
+	}
+}
+
+func giveItem(templateId types.Id, character types.Character) {
+	item := CreateItem(templateId)
+	item.SetContainerId(character.GetId(), nil)
+}
+
+// DropInventory moves all of the items and cash a character is carrying into
+// the room they are standing in
+func DropInventory(character types.Character) error {
+	room := GetRoom(character.GetRoomId())
+
+	tx := NewTransaction()
+	for _, item := range ItemsIn(character.GetId()) {
+		tx.MoveItem(item, character.GetId(), room.GetId())
+	}
+	tx.TransferCash(character, room, character.GetCash())
+
+	return tx.Commit()
+}
+
 func GetSkill(id types.Id) types.Skill {
-	return db.Retrieve(id, types.SkillType).(types.Skill)
+	skill, _ := db.Retrieve(id, types.SkillType).(types.Skill)
+	return skill
 }
 
 func GetSkillByName(name string) types.Skill {
@@ -737,7 +1130,8 @@ func GetEffects(EffectIds []types.Id) types.EffectList {
 }
 
 func GetEffect(id types.Id) types.Effect {
-	return db.Retrieve(id, types.EffectType).(types.Effect)
+	effect, _ := db.Retrieve(id, types.EffectType).(types.Effect)
+	return effect
 }
 
 func GetEffectByName(name string) types.Effect {
@@ -748,6 +1142,45 @@ func GetEffectByName(name string) types.Effect {
 	return nil
 }
 
+func GetStores() types.StoreList {
+	ids := db.FindAll(types.StoreType)
+	stores := make(types.StoreList, len(ids))
+
+	for i, id := range ids {
+		stores[i] = GetStore(id)
+	}
+
+	return stores
+}
+
+// RestockStore creates new items for each of the store's stock entries whose
+// restock interval has elapsed, up to the entry's quantity. When force is
+// true the intervals are ignored.
+func RestockStore(store types.Store, force bool) {
+	items := ItemsIn(store.GetId())
+
+	for _, entry := range store.GetStock() {
+		interval := time.Duration(entry.Interval) * time.Minute
+		if !force && time.Since(entry.LastRestock) < interval {
+			continue
+		}
+
+		count := 0
+		for _, item := range items {
+			if item.GetTemplateId() == entry.TemplateId {
+				count++
+			}
+		}
+
+		for ; count < entry.Quantity; count++ {
+			item := CreateItem(entry.TemplateId)
+			item.SetContainerId(store.GetId(), nil)
+		}
+
+		store.SetRestocked(entry.TemplateId, time.Now())
+	}
+}
+
 func StoreIn(roomId types.Id) types.Store {
 	id := db.FindOne(types.StoreType, bson.M{"roomid": roomId})
 
@@ -769,6 +1202,21 @@ func DeleteStore(id types.Id) {
 	deleteContainer(id)
 }
 
+// NewTransaction returns a transaction which can be used to move cash and
+// items between containers such that either every change is applied or none
+// of them are
+func NewTransaction() types.Transaction {
+	return db.NewTransaction()
+}
+
+// TransferCash moves cash from one container to another, failing if the
+// source doesn't have enough
+func TransferCash(from types.Identifiable, to types.Identifiable, amount int) error {
+	tx := NewTransaction()
+	tx.TransferCash(from, to, amount)
+	return tx.Commit()
+}
+
 func GetWorld() types.World {
 	id := db.FindOne(types.WorldType, bson.M{})
 	if id == nil {
@@ -784,3 +1232,256 @@ func deleteContainer(id types.Id) {
 	}
 	db.DeleteObject(id)
 }
+
+// The channels that always exist, and whether or not they are restricted to
+// admins
+var defaultChannels = []struct {
+	name      string
+	color     types.Color
+	adminOnly bool
+}{
+	{"ooc", types.ColorCyan, false},
+	{"newbie", types.ColorGreen, false},
+	{"trade", types.ColorYellow, false},
+	{"admin", types.ColorMagenta, true},
+}
+
+// CreateDefaultChannels creates any of the built-in channels that don't exist yet
+func CreateDefaultChannels() {
+	for _, info := range defaultChannels {
+		if GetChannelByName(info.name) == nil {
+			channel := db.NewChannel(info.name, nil)
+			channel.SetColor(info.color)
+			channel.SetAdminOnly(info.adminOnly)
+		}
+	}
+}
+
+func CreateChannel(name string, ownerId types.Id) (types.Channel, error) {
+	if GetChannelByName(name) != nil {
+		return nil, errors.New("A channel with that name already exists")
+	}
+
+	return db.NewChannel(name, ownerId), nil
+}
+
+func GetChannel(id types.Id) types.Channel {
+	channel, _ := db.Retrieve(id, types.ChannelType).(types.Channel)
+	return channel
+}
+
+func GetChannelByName(name string) types.Channel {
+	id := db.FindOne(types.ChannelType, bson.M{"name": strings.ToLower(name)})
+	if id != nil {
+		return GetChannel(id)
+	}
+	return nil
+}
+
+func GetChannels() types.ChannelList {
+	ids := db.FindAll(types.ChannelType)
+	channels := make(types.ChannelList, len(ids))
+
+	for i, id := range ids {
+		channels[i] = GetChannel(id)
+	}
+
+	return channels
+}
+
+func DeleteChannel(id types.Id) {
+	db.DeleteObject(id)
+}
+
+// ChannelMessage sends a message to everyone subscribed to the channel and
+// records it in the channel's history
+func ChannelMessage(channel types.Channel, from types.Character, message string) error {
+	if !channel.IsMember(from.GetId()) {
+		return errors.New("You aren't on that channel")
+	}
+
+	if channel.IsSilenced(from.GetId()) {
+		return errors.New("You have been silenced on that channel")
+	}
+
+	channel.AddHistory(from.GetName(), message)
+
+	entry := types.HistoryEntry{Time: time.Now(), From: from.GetName(), To: channel.GetName(), Message: message}
+	var listeners []types.Character
+	for _, id := range channel.GetMembers() {
+		if pc := GetPlayerCharacter(id); pc != nil && pc.IsOnline() && !channel.IsMuted(id) {
+			listeners = append(listeners, pc)
+		}
+	}
+	recordHistory(types.HistoryChannel, entry, from, listeners...)
+	events.Broadcast(events.ChannelEvent{Channel: channel, From: from, Message: message})
+	return nil
+}
+
+// SendMail delivers a message to the given user, which they'll be able to read
+// the next time any of their characters log in
+func SendMail(from types.Character, toUserId types.Id, toName string, subject string, body string) types.Mail {
+	mail := db.NewMail(from.GetName(), toUserId, toName, subject, body)
+	events.Broadcast(events.MailEvent{Mail: mail})
+	return mail
+}
+
+func GetMail(id types.Id) types.Mail {
+	mail, _ := db.Retrieve(id, types.MailType).(types.Mail)
+	return mail
+}
+
+// GetUserMail returns all of the mail sent to the user, oldest first
+func GetUserMail(userId types.Id) types.MailList {
+	ids := db.Find(types.MailType, bson.M{"touserid": userId})
+	mail := make(types.MailList, len(ids))
+
+	for i, id := range ids {
+		mail[i] = GetMail(id)
+	}
+
+	sort.Sort(mail)
+	return mail
+}
+
+func CountUnreadMail(userId types.Id) int {
+	count := 0
+	for _, mail := range GetUserMail(userId) {
+		if !mail.IsRead() {
+			count++
+		}
+	}
+	return count
+}
+
+func DeleteMail(id types.Id) {
+	db.DeleteObject(id)
+}
+
+// GetStartingRoom returns the room that new characters start in
+func GetStartingRoom() types.Room {
+	return GetRooms()[0]
+}
+
+// Sanctions are checked on every move and chat message, so the ones that
+// haven't been lifted are kept in memory. The cache is refreshed whenever a
+// sanction is created or lifted, and each time ExpireSanctions runs. A nil
+// cache hasn't been loaded yet.
+var sanctionCache types.SanctionList
+var sanctionMutex sync.RWMutex
+
+func refreshSanctions() {
+	sanctions := GetSanctions()
+
+	sanctionMutex.Lock()
+	defer sanctionMutex.Unlock()
+	sanctionCache = sanctions
+}
+
+func cachedSanctions() types.SanctionList {
+	sanctionMutex.RLock()
+	sanctions := sanctionCache
+	sanctionMutex.RUnlock()
+
+	if sanctions == nil {
+		refreshSanctions()
+		return cachedSanctions()
+	}
+
+	return sanctions
+}
+
+func CreateSanction(kind types.SanctionKind, userId types.Id, address string, reason string, issuer string, duration time.Duration) types.Sanction {
+	sanction := db.NewSanction(kind, userId, address, reason, issuer, duration)
+	refreshSanctions()
+	return sanction
+}
+
+func GetSanctionById(id types.Id) types.Sanction {
+	sanction, _ := db.Retrieve(id, types.SanctionType).(types.Sanction)
+	return sanction
+}
+
+// GetSanctions returns every sanction that hasn't been lifted yet, oldest first
+func GetSanctions() types.SanctionList {
+	ids := db.FindAll(types.SanctionType)
+	sanctions := make(types.SanctionList, len(ids))
+
+	for i, id := range ids {
+		sanctions[i] = GetSanctionById(id)
+	}
+
+	sort.Sort(sanctions)
+	return sanctions
+}
+
+// GetSanction returns the user's active sanction of the given kind, if any
+func GetSanction(userId types.Id, kind types.SanctionKind) types.Sanction {
+	for _, sanction := range cachedSanctions() {
+		if sanction.GetKind() == kind && sanction.GetUserId() == userId && !sanction.IsExpired() {
+			return sanction
+		}
+	}
+	return nil
+}
+
+// GetAddressBan returns the active IP ban covering the address, if any
+func GetAddressBan(address string) types.Sanction {
+	for _, sanction := range cachedSanctions() {
+		if sanction.GetKind() == types.SanctionIpBan && !sanction.IsExpired() &&
+			utils.AddressMatches(sanction.GetAddress(), address) {
+			return sanction
+		}
+	}
+	return nil
+}
+
+// JailCharacter moves the character into the world's jail room
+func JailCharacter(pc types.PC) error {
+	jailId := GetWorld().GetJailRoomId()
+	if jailId == nil {
+		return errors.New("No jail room has been set")
+	}
+
+	jail := GetRoom(jailId)
+	if jail == nil {
+		return errors.New("The jail room no longer exists")
+	}
+
+	if pc.GetRoomId() != jail.GetId() {
+		MoveCharacterToRoom(pc, jail)
+	}
+	return nil
+}
+
+// DeleteSanction lifts the sanction. Characters released from jail are
+// returned to the starting room.
+func DeleteSanction(id types.Id) {
+	sanction := GetSanctionById(id)
+	if sanction == nil {
+		return
+	}
+
+	db.DeleteObject(id)
+	refreshSanctions()
+
+	if sanction.GetKind() == types.SanctionJail {
+		jailId := GetWorld().GetJailRoomId()
+		for _, pc := range GetUserCharacters(sanction.GetUserId()) {
+			if pc.GetRoomId() == jailId {
+				MoveCharacterToRoom(pc, GetStartingRoom())
+			}
+		}
+	}
+}
+
+// ExpireSanctions lifts every sanction whose time is up
+func ExpireSanctions() {
+	for _, sanction := range GetSanctions() {
+		if sanction.IsExpired() {
+			DeleteSanction(sanction.GetId())
+		}
+	}
+
+	refreshSanctions()
+}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Cristofori/kmud/database"
 	"github.com/Cristofori/kmud/datastore"
@@ -125,3 +126,464 @@ func (s *ModelSuite) TestCharFunctions(c *C) {
 	//playerName1 := "player1"
 	//player1 := CreatePlayer(name1, user
 }
+
+func (s *ModelSuite) TestTransactions(c *C) {
+	zone, _ := CreateZone("transactionZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	user := CreateUser("transactionUser", "", false)
+
+	buyer := CreatePlayerCharacter("buyer", user.GetId(), room)
+	seller := CreatePlayerCharacter("seller", user.GetId(), room)
+
+	template := CreateTemplate("transactionItem")
+	item := CreateItem(template.GetId())
+	item.SetContainerId(seller.GetId(), nil)
+
+	buyer.AddCash(50)
+
+	tx := NewTransaction()
+	tx.MoveItem(item, seller.GetId(), buyer.GetId())
+	tx.TransferCash(buyer, seller, 100)
+
+	c.Assert(tx.Commit(), Not(Equals), nil)
+	c.Assert(item.GetContainerId(), Equals, seller.GetId())
+	c.Assert(buyer.GetCash(), Equals, 50)
+	c.Assert(seller.GetCash(), Equals, 0)
+
+	tx = NewTransaction()
+	tx.MoveItem(item, seller.GetId(), buyer.GetId())
+	tx.TransferCash(buyer, seller, 50)
+
+	c.Assert(tx.Commit(), Equals, nil)
+	c.Assert(item.GetContainerId(), Equals, buyer.GetId())
+	c.Assert(buyer.GetCash(), Equals, 0)
+	c.Assert(seller.GetCash(), Equals, 50)
+
+	tx = NewTransaction()
+	tx.MoveItem(item, seller.GetId(), buyer.GetId())
+
+	c.Assert(tx.Commit(), Not(Equals), nil)
+
+	c.Assert(TransferCash(seller, buyer, 51), Not(Equals), nil)
+	c.Assert(TransferCash(seller, buyer, 50), Equals, nil)
+	c.Assert(buyer.GetCash(), Equals, 50)
+	c.Assert(buyer.RemoveCash(51), Equals, false)
+	c.Assert(buyer.RemoveCash(50), Equals, true)
+}
+
+func (s *ModelSuite) TestStores(c *C) {
+	zone, _ := CreateZone("storeZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	store := CreateStore("testStore", room.GetId())
+
+	c.Assert(StoreIn(room.GetId()), Equals, store)
+	c.Assert(store.SellPrice(100), Equals, 100)
+	c.Assert(store.BuyPrice(100), Equals, 50)
+
+	store.SetSellRate(0)
+	c.Assert(store.GetSellRate(), Equals, database.DefaultSellRate)
+
+	template := CreateTemplate("storeItem")
+	store.SetStock(template.GetId(), 3, 60)
+
+	RestockStore(store, false)
+	c.Assert(CountItemsIn(store.GetId()), Equals, 3)
+
+	DeleteItem(ItemsIn(store.GetId())[0].GetId())
+	RestockStore(store, false)
+	c.Assert(CountItemsIn(store.GetId()), Equals, 2)
+
+	RestockStore(store, true)
+	c.Assert(CountItemsIn(store.GetId()), Equals, 3)
+
+	store.SetSpendLimit(10)
+	c.Assert(store.CanSpend(10), Equals, true)
+	store.RecordSpending(6)
+	c.Assert(store.CanSpend(5), Equals, false)
+
+	c.Assert(store.AcceptsCategory("Weapon"), Equals, true)
+	store.AddCategory("weapon")
+	c.Assert(store.AcceptsCategory("Weapon"), Equals, true)
+	c.Assert(store.AcceptsCategory("Armor"), Equals, false)
+}
+
+func (s *ModelSuite) TestLoot(c *C) {
+	zone, _ := CreateZone("lootZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	spawner := CreateSpawner("lootSpawner", zone.GetId())
+	npc := CreateNpc("lootNpc", room.GetId(), spawner.GetId())
+
+	guaranteed := CreateTemplate("guaranteedLoot")
+	random := CreateTemplate("randomLoot")
+
+	spawner.SetLoot(types.LootTable{
+		Drops: []types.LootDrop{
+			{TemplateId: guaranteed.GetId(), Guaranteed: true},
+			{TemplateId: random.GetId(), Weight: 1},
+		},
+		Rolls:   2,
+		MinCash: 5,
+		MaxCash: 5,
+	})
+
+	c.Assert(GetSpawner(npc.GetSpawnerId()), Equals, spawner)
+
+	RollLoot(spawner.GetLoot(), npc)
+	c.Assert(CountItemsIn(npc.GetId()), Equals, 3)
+	c.Assert(npc.GetCash(), Equals, 5)
+
+	c.Assert(DropInventory(npc), Equals, nil)
+	c.Assert(CountItemsIn(npc.GetId()), Equals, 0)
+	c.Assert(CountItemsIn(room.GetId()), Equals, 3)
+	c.Assert(npc.GetCash(), Equals, 0)
+	c.Assert(room.GetCash(), Equals, 5)
+}
+
+func (s *ModelSuite) TestSpawnNpc(c *C) {
+	zone, _ := CreateZone("spawnZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	spawner := CreateSpawner("spawnTemplate", zone.GetId())
+
+	skill := CreateSkill("spawnSkill")
+	sword := CreateTemplate("spawnSword")
+
+	spawner.SetHealth(50)
+	spawner.SetStrength(20)
+	spawner.SetRoaming(true)
+	spawner.SetConversation("Hello")
+	spawner.AddCash(10)
+	spawner.AddSkill(skill.GetId())
+	spawner.AddEquipment(sword.GetId())
+
+	npc := SpawnNpc(spawner, room.GetId())
+
+	c.Assert(npc.GetSpawnerId(), Equals, spawner.GetId())
+	c.Assert(npc.GetHealth(), Equals, 50)
+	c.Assert(npc.GetHitPoints(), Equals, 50)
+	c.Assert(npc.GetStrength(), Equals, 20)
+	c.Assert(npc.GetRoaming(), Equals, true)
+	c.Assert(npc.GetConversation(), Equals, "Hello")
+	c.Assert(npc.GetCash(), Equals, 10)
+	c.Assert(npc.HasSkill(skill.GetId()), Equals, true)
+	c.Assert(ItemsIn(npc.GetId()).Names(), DeepEquals, []string{"Spawnsword"})
+}
+
+func (s *ModelSuite) TestSpawnerRooms(c *C) {
+	zone, _ := CreateZone("spawnerRoomZone")
+	area, _ := CreateArea("spawnerRoomArea", zone)
+
+	room1, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	room2, _ := CreateRoom(zone, types.Coordinate{X: 1, Y: 0, Z: 0})
+	room1.SetAreaId(area.GetId())
+	room2.SetAreaId(area.GetId())
+
+	spawner := CreateSpawner("roomSpawner", area.GetId())
+	c.Assert(GetSpawnerRooms(spawner), HasLen, 2)
+
+	spawner.AddRoom(room2.GetId())
+	c.Assert(GetSpawnerRooms(spawner), DeepEquals, types.RoomList{room2})
+
+	spawner.SetRespawnDelay(60)
+	c.Assert(spawner.PendingRespawns(), Equals, 0)
+	spawner.RecordDeath()
+	c.Assert(spawner.PendingRespawns(), Equals, 1)
+
+	DeleteSpawner(spawner.GetId())
+	c.Assert(spawner.IsDestroyed(), Equals, true)
+	c.Assert(GetSpawner(spawner.GetId()), IsNil)
+}
+
+func (s *ModelSuite) TestChannels(c *C) {
+	CreateDefaultChannels()
+	CreateDefaultChannels()
+
+	c.Assert(GetChannelByName("OOC"), Not(IsNil))
+	c.Assert(GetChannelByName("admin").IsAdminOnly(), Equals, true)
+
+	user := CreateUser("channelUser", "", false)
+	channel, err := CreateChannel("guild", user.GetId())
+	c.Assert(err, IsNil)
+
+	_, err = CreateChannel("Guild", user.GetId())
+	c.Assert(err, Not(IsNil))
+
+	zone, _ := CreateZone("channelZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	pc := CreatePlayerCharacter("channelPc", user.GetId(), room)
+
+	c.Assert(ChannelMessage(channel, pc, "hello"), Not(IsNil))
+
+	channel.Join(pc.GetId())
+	c.Assert(ChannelMessage(channel, pc, "hello"), IsNil)
+	c.Assert(channel.GetHistory(), HasLen, 1)
+	c.Assert(channel.GetHistory()[0].From, Equals, pc.GetName())
+
+	channel.SetSilenced(pc.GetId(), true)
+	c.Assert(ChannelMessage(channel, pc, "hello"), Not(IsNil))
+
+	channel.SetBanned(pc.GetId(), true)
+	c.Assert(channel.IsMember(pc.GetId()), Equals, false)
+	channel.Join(pc.GetId())
+	c.Assert(channel.IsMember(pc.GetId()), Equals, false)
+
+	channel.SetBanned(pc.GetId(), false)
+	channel.Join(pc.GetId())
+	c.Assert(channel.IsMember(pc.GetId()), Equals, true)
+
+	DeleteChannel(channel.GetId())
+	c.Assert(GetChannelByName("guild"), IsNil)
+}
+
+func (s *ModelSuite) TestMail(c *C) {
+	zone, _ := CreateZone("mailZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	sender := CreateUser("mailSender", "", false)
+	recipient := CreateUser("mailRecipient", "", false)
+	pc := CreatePlayerCharacter("mailPc", sender.GetId(), room)
+
+	c.Assert(GetUserMail(recipient.GetId()), HasLen, 0)
+
+	first := SendMail(pc, recipient.GetId(), recipient.GetName(), "First", "Hello")
+	SendMail(pc, recipient.GetId(), recipient.GetName(), "Second", "Hello again")
+
+	mail := GetUserMail(recipient.GetId())
+	c.Assert(mail, HasLen, 2)
+	c.Assert(mail[0], Equals, first)
+	c.Assert(CountUnreadMail(recipient.GetId()), Equals, 2)
+
+	first.MarkRead()
+	c.Assert(CountUnreadMail(recipient.GetId()), Equals, 1)
+
+	DeleteMail(first.GetId())
+	c.Assert(GetUserMail(recipient.GetId()), HasLen, 1)
+}
+
+func (s *ModelSuite) TestHistory(c *C) {
+	zone, _ := CreateZone("historyZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	user := CreateUser("historyUser", "", false)
+
+	pc1 := CreatePlayerCharacter("historyPc1", user.GetId(), room)
+	pc2 := CreatePlayerCharacter("historyPc2", user.GetId(), room)
+
+	Tell(pc1, pc2, "psst")
+	c.Assert(pc1.GetHistory(types.HistoryTell), HasLen, 1)
+	c.Assert(pc2.GetHistory(types.HistoryTell), HasLen, 1)
+	c.Assert(pc2.GetHistory(types.HistoryTell)[0].From, Equals, pc1.GetName())
+	c.Assert(pc2.GetHistory(types.HistoryTell)[0].To, Equals, pc2.GetName())
+
+	other := CreateUser("historyIgnorer", "", false)
+	pc3 := CreatePlayerCharacter("historyPc3", other.GetId(), room)
+	other.Ignore(user.GetId())
+	Tell(pc1, pc3, "psst")
+	c.Assert(pc3.GetHistory(types.HistoryTell), HasLen, 0)
+
+	for i := 0; i < 150; i++ {
+		pc1.AddHistory(types.HistorySay, types.HistoryEntry{Message: "hello"})
+	}
+	c.Assert(pc1.GetHistory(types.HistorySay), HasLen, 100)
+	c.Assert(pc1.GetHistory(types.HistoryChannel), HasLen, 0)
+}
+
+func (s *ModelSuite) TestSanctions(c *C) {
+	zone, _ := CreateZone("sanctionZone")
+	start, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	jail, _ := CreateRoom(zone, types.Coordinate{X: 1, Y: 0, Z: 0})
+	start.SetExitEnabled(types.DirectionEast, true)
+	jail.SetExitEnabled(types.DirectionWest, true)
+
+	user := CreateUser("sanctionUser", "", false)
+	pc := CreatePlayerCharacter("sanctionPc", user.GetId(), GetStartingRoom())
+
+	c.Assert(GetSanction(user.GetId(), types.SanctionMute), IsNil)
+
+	mute := CreateSanction(types.SanctionMute, user.GetId(), "", "spam", "admin", time.Hour)
+	c.Assert(GetSanction(user.GetId(), types.SanctionMute), Equals, mute)
+	c.Assert(GetSanction(user.GetId(), types.SanctionBan), IsNil)
+
+	expired := CreateSanction(types.SanctionBan, user.GetId(), "", "", "admin", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	c.Assert(expired.IsExpired(), Equals, true)
+	c.Assert(GetSanction(user.GetId(), types.SanctionBan), IsNil)
+
+	ExpireSanctions()
+	c.Assert(GetSanctionById(expired.GetId()), IsNil)
+	c.Assert(GetSanctionById(mute.GetId()), Equals, mute)
+
+	CreateSanction(types.SanctionIpBan, nil, "192.168.0.0/16", "", "admin", 0)
+	c.Assert(GetAddressBan("192.168.4.4"), Not(IsNil))
+	c.Assert(GetAddressBan("10.0.0.1"), IsNil)
+
+	GetWorld().SetJailRoomId(jail.GetId())
+	jailing := CreateSanction(types.SanctionJail, user.GetId(), "", "", "admin", 0)
+	c.Assert(JailCharacter(pc), IsNil)
+	c.Assert(pc.GetRoomId(), Equals, jail.GetId())
+	c.Assert(MoveCharacter(pc, types.DirectionWest), Not(IsNil))
+
+	DeleteSanction(jailing.GetId())
+	c.Assert(pc.GetRoomId(), Equals, GetStartingRoom().GetId())
+}
+
+func (s *ModelSuite) TestRoles(c *C) {
+	zone, _ := CreateZone("builderZone")
+	other, _ := CreateZone("otherZone")
+
+	user := CreateUser("roleUser", "", false)
+	c.Assert(user.GetRole(), Equals, types.RolePlayer)
+	c.Assert(user.CanBuildIn(zone.GetId()), Equals, false)
+
+	user.SetRole(types.RoleBuilder)
+	user.AddBuilderZone(zone.GetId())
+	c.Assert(user.HasPermission(types.PermissionBuild), Equals, true)
+	c.Assert(user.HasPermission(types.PermissionAdmin), Equals, false)
+	c.Assert(user.CanBuildIn(zone.GetId()), Equals, true)
+	c.Assert(user.CanBuildIn(other.GetId()), Equals, false)
+
+	user.RemoveBuilderZone(zone.GetId())
+	c.Assert(user.CanBuildIn(zone.GetId()), Equals, false)
+
+	user.SetRole(types.RoleAdmin)
+	c.Assert(user.IsAdmin(), Equals, true)
+	c.Assert(user.CanBuildIn(other.GetId()), Equals, true)
+}
+
+func (s *ModelSuite) TestExtraDescriptions(c *C) {
+	zone, _ := CreateZone("extraZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+
+	room.SetExtraDescription(" Fountain ", "A stone fountain")
+	c.Assert(room.GetExtraDescriptions(), DeepEquals, map[string]string{"fountain": "A stone fountain"})
+
+	room.RemoveExtraDescription("FOUNTAIN")
+	c.Assert(room.GetExtraDescriptions(), HasLen, 0)
+
+	template := CreateTemplate("extraTemplate")
+	template.SetExtraDescription("hilt", "A worn leather grip")
+	c.Assert(GetTemplate(template.GetId()).GetExtraDescriptions()["hilt"], Equals, "A worn leather grip")
+}
+
+func (s *ModelSuite) TestRoomActions(c *C) {
+	zone, _ := CreateZone("actionZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	room.SetExitEnabled(types.DirectionNorth, true)
+
+	lever := types.RoomAction{Trigger: types.TriggerCommand, Command: "pull lever"}
+	room.AddAction(lever)
+	room.AddAction(types.RoomAction{Trigger: types.TriggerEnter})
+	c.Assert(room.GetActions(), HasLen, 2)
+
+	lever.Responses = []types.RoomResponse{{Kind: types.ResponseLock, Direction: types.DirectionNorth}}
+	room.SetAction(0, lever)
+	c.Assert(room.GetActions()[0].Responses, HasLen, 1)
+
+	room.RemoveAction(1)
+	room.RemoveAction(5)
+	c.Assert(room.GetActions(), DeepEquals, []types.RoomAction{lever})
+
+	SetExitLocked(room, types.DirectionNorth, true)
+	c.Assert(room.IsLocked(types.DirectionNorth), Equals, false)
+
+	SetDoor(room, types.DirectionNorth, types.Door{Name: "grate"})
+	SetExitLocked(room, types.DirectionNorth, true)
+	c.Assert(room.IsLocked(types.DirectionNorth), Equals, true)
+}
+
+func (s *ModelSuite) TestDoors(c *C) {
+	zone, _ := CreateZone("doorZone")
+	inside, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	outside, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: -1, Z: 0})
+	inside.SetExitEnabled(types.DirectionNorth, true)
+	outside.SetExitEnabled(types.DirectionSouth, true)
+
+	SetDoor(inside, types.DirectionNorth, types.Door{Name: "gate", Difficulty: 30})
+	door, found := outside.GetDoor(types.DirectionSouth)
+	c.Assert(found, Equals, true)
+	c.Assert(door.Name, Equals, "gate")
+
+	SetExitClosed(outside, types.DirectionSouth, true)
+	c.Assert(inside.IsClosed(types.DirectionNorth), Equals, true)
+
+	SetExitClosed(inside, types.DirectionNorth, false)
+	SetExitLocked(inside, types.DirectionNorth, true)
+	c.Assert(outside.IsLocked(types.DirectionSouth), Equals, true)
+	c.Assert(outside.IsClosed(types.DirectionSouth), Equals, true)
+
+	RemoveDoor(outside, types.DirectionSouth)
+	_, found = inside.GetDoor(types.DirectionNorth)
+	c.Assert(found, Equals, false)
+	c.Assert(inside.IsClosed(types.DirectionNorth), Equals, false)
+}
+
+func (s *ModelSuite) TestHiddenExits(c *C) {
+	zone, _ := CreateZone("hiddenZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	other, _ := CreateRoom(zone, types.Coordinate{X: 1, Y: 0, Z: 0})
+	room.SetExitEnabled(types.DirectionEast, true)
+	room.SetExitEnabled(types.DirectionNorth, true)
+	other.SetExitEnabled(types.DirectionWest, true)
+
+	user := CreateUser("hiddenUser", "", false)
+	pc := CreatePlayerCharacter("hiddenPlayer", user.GetId(), room)
+
+	room.SetHidden(types.DirectionEast, true)
+	c.Assert(VisibleExits(pc, room), DeepEquals, []types.Direction{types.DirectionNorth})
+	c.Assert(GetNeighbors(pc, room), HasLen, 0)
+	c.Assert(MoveCharacter(pc, types.DirectionEast), NotNil)
+
+	RevealExit(pc, room, types.DirectionEast)
+	c.Assert(CanSeeExit(pc, room, types.DirectionEast), Equals, true)
+
+	room.SetRestriction(types.DirectionEast, types.ExitRestriction{MinExperience: 100})
+	c.Assert(CheckRestriction(pc, room, types.DirectionEast), NotNil)
+	c.Assert(GetNeighbors(pc, room), HasLen, 0)
+
+	pc.AddExperience(100)
+	c.Assert(CheckRestriction(pc, room, types.DirectionEast), IsNil)
+	c.Assert(GetNeighbors(pc, room), HasLen, 1)
+
+	SetDoor(room, types.DirectionEast, types.Door{Name: "panel"})
+	RemoveDoor(room, types.DirectionEast)
+	c.Assert(room.IsHidden(types.DirectionEast), Equals, true)
+	c.Assert(room.GetRestriction(types.DirectionEast).MinExperience, Equals, 100)
+
+	SetOneWay(room, types.DirectionEast, true)
+	c.Assert(IsOneWay(room, types.DirectionEast), Equals, true)
+	c.Assert(other.HasExit(types.DirectionWest), Equals, false)
+}
+
+func (s *ModelSuite) TestRoomFlags(c *C) {
+	zone, _ := CreateZone("flagZone")
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	other, _ := CreateRoom(zone, types.Coordinate{X: 1, Y: 0, Z: 0})
+
+	room.SetFlag(types.RoomDark, true)
+	room.SetFlag(types.RoomDark, true)
+	c.Assert(room.GetFlags(), DeepEquals, []types.RoomFlag{types.RoomDark})
+	c.Assert(IsLit(room), Equals, false)
+
+	torch := CreateTemplate("flagTorch")
+	torch.SetLight(true)
+	item := CreateItem(torch.GetId())
+	item.SetContainerId(room.GetId(), nil)
+	c.Assert(IsLit(room), Equals, true)
+
+	c.Assert(CanTeleport(room, other), Equals, true)
+	other.SetFlag(types.RoomNoTeleport, true)
+	c.Assert(CanTeleport(room, other), Equals, false)
+
+	other.SetFlag(types.RoomNoTeleport, false)
+	c.Assert(other.GetFlags(), HasLen, 0)
+}
+
+func (s *ModelSuite) TestWeather(c *C) {
+	zone, _ := CreateZone("weatherZone")
+	c.Assert(zone.GetWeather(), Equals, types.WeatherClear)
+
+	zone.SetWeather(types.WeatherStorm)
+	c.Assert(GetZone(zone.GetId()).GetWeather(), Equals, types.WeatherStorm)
+
+	room, _ := CreateRoom(zone, types.Coordinate{X: 0, Y: 0, Z: 0})
+	c.Assert(room.GetNightDescription(), Equals, "")
+	room.SetNightDescription("Owls hoot in the dark.")
+	c.Assert(room.GetNightDescription(), Equals, "Owls hoot in the dark.")
+}
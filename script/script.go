@@ -0,0 +1,303 @@
+// Package script runs the Lua scripts that builders attach to rooms, NPCs
+// and item templates. Each script defines handler functions which are called
+// when something happens in the room the scripted object is in:
+//
+//	on_enter(character)          someone entered the room
+//	on_leave(character)          someone left the room
+//	on_say(character, message)   someone said something
+//	on_emote(character, emote)   someone emoted
+//	on_death(character, killer)  someone died, killer may be nil
+//	on_time(phase)               it's now "Dawn", "Day", "Dusk" or "Night"
+//
+// Unlike the others, on_time is called for every room and NPC script,
+// whether or not anyone is around, and never for item scripts.
+//
+// Scripts only get the base, string, table and math libraries, and talk to
+// the world through the functions in the "mud" table. Every call is limited
+// to Timeout and MaxAllocation, a script can't keep more than MaxSize of data
+// between calls, and a script that is called more than CallsPerSecond times
+// in a second is skipped until the next one.
+package script
+
+import (
+	"context"
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+const (
+	Timeout        = 100 * time.Millisecond
+	CallsPerSecond = 20
+
+	// Bytes that the server may allocate during a single call before it's
+	// stopped
+	MaxAllocation = 32 * 1024 * 1024
+	// Bytes of strings and tables that a script may keep between calls
+	MaxSize = 1024 * 1024
+
+	// Longest string that string.rep will build
+	maxStringLength = 64 * 1024
+
+	// How often a running call's allocations are checked
+	memoryInterval = time.Millisecond
+	// Rough cost of a table entry, on top of its key and value
+	entrySize = 16
+)
+
+// Check compiles the script without running it, so that builders can be told
+// about syntax errors when they save
+func Check(source string) error {
+	L := newState()
+	defer L.Close()
+
+	_, err := L.LoadString(source)
+	return err
+}
+
+func newState() *lua.LState {
+	L := lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   128,
+		RegistrySize:    1024,
+		RegistryMaxSize: 64 * 1024,
+	})
+
+	libs := []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.LoadLibName, lua.OpenPackage},
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	}
+
+	for _, lib := range libs {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+
+	// Nothing that reaches outside of the server
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module", "package",
+		"getfenv", "setfenv", "collectgarbage", "print"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	if str, ok := L.GetGlobal("string").(*lua.LTable); ok {
+		rep := str.RawGetString("rep")
+		str.RawSetString("rep", L.NewFunction(func(L *lua.LState) int {
+			if len(L.CheckString(1))*L.CheckInt(2) > maxStringLength {
+				L.RaiseError("string.rep result is too long")
+			}
+			L.Push(rep)
+			L.Push(L.Get(1))
+			L.Push(L.Get(2))
+			L.Call(2, 1)
+			return 1
+		}))
+	}
+
+	return L
+}
+
+// host keeps the state of one object's script between calls, so that global
+// variables set by one handler can be seen by the next
+type host struct {
+	source string
+	state  *lua.LState
+	mud    *lua.LTable
+
+	window time.Time
+	calls  int
+}
+
+func newHost(source string) (*host, error) {
+	self := &host{source: source, state: newState()}
+	self.mud = self.state.NewTable()
+	self.state.SetGlobal("mud", self.mud)
+
+	err := self.run("script", func() error {
+		return self.state.DoString(source)
+	})
+
+	if err != nil {
+		self.state.Close()
+		return nil, err
+	}
+
+	return self, nil
+}
+
+func (self *host) close() {
+	self.state.Close()
+}
+
+func (self *host) has(handler string) bool {
+	_, ok := self.state.GetGlobal(handler).(*lua.LFunction)
+	return ok
+}
+
+// Returns false if the script has used up its calls for this second
+func (self *host) allowed(now time.Time) bool {
+	if now.Sub(self.window) >= time.Second {
+		self.window = now
+		self.calls = 0
+	}
+
+	self.calls++
+	return self.calls <= CallsPerSecond
+}
+
+// call runs the handler if the script defines it. An error means the state
+// can't be trusted anymore, and the host should be thrown away.
+func (self *host) call(handler string, args ...lua.LValue) error {
+	fn, ok := self.state.GetGlobal(handler).(*lua.LFunction)
+	if !ok {
+		return nil
+	}
+
+	if !self.allowed(time.Now()) {
+		return nil
+	}
+
+	return self.run(handler, func() error {
+		return self.state.CallByParam(lua.P{Fn: fn, NRet: 0, Protect: true}, args...)
+	})
+}
+
+// run calls fn with the state limited to Timeout and MaxAllocation, then
+// makes sure that the script isn't keeping more than MaxSize of data
+func (self *host) run(name string, fn func() error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	var exceeded int32
+	go watchMemory(ctx, cancel, allocated(), allocationLimit, &exceeded)
+
+	self.state.SetContext(ctx)
+	err := fn()
+	self.state.RemoveContext()
+
+	if atomic.LoadInt32(&exceeded) != 0 {
+		return overloadError{name}
+	}
+
+	if err != nil && ctx.Err() != nil {
+		return fmt.Errorf("%s took longer than %v", name, Timeout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if size := stateSize(self.state); size > MaxSize {
+		return fmt.Errorf("%s left %d bytes of data behind, the limit is %d", name, size, MaxSize)
+	}
+
+	return nil
+}
+
+// The limit watchMemory enforces, which tests turn down
+var allocationLimit uint64 = MaxAllocation
+
+// overloadError means that a call was stopped because the server allocated
+// more than MaxAllocation while it ran. Everything else running at the time
+// counts towards that, so the script may not be to blame.
+type overloadError struct {
+	handler string
+}
+
+func (self overloadError) Error() string {
+	return fmt.Sprintf("%s was stopped after the server allocated more than %d bytes", self.handler, MaxAllocation)
+}
+
+// disables returns true if the error means that there's something wrong with
+// the script itself, and it shouldn't be run again until it's changed
+func disables(err error) bool {
+	_, overload := err.(overloadError)
+	return !overload
+}
+
+// watchMemory cancels a call once the server has allocated more than
+// MaxAllocation since it started. Go can't tell how much of that was done by
+// the script's state rather than the rest of the server, so going over only
+// stops the call. The data the script keeps is measured by stateSize.
+func watchMemory(ctx context.Context, cancel func(), start uint64, limit uint64, exceeded *int32) {
+	ticker := time.NewTicker(memoryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if allocated()-start > limit {
+				atomic.StoreInt32(exceeded, 1)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// Total bytes allocated on the heap since the server started
+func allocated() uint64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// stateSize estimates how much data a script is keeping, from the strings and
+// table entries that can be reached from its globals and closures
+func stateSize(L *lua.LState) int {
+	seen := map[lua.LValue]bool{}
+
+	var size func(lua.LValue) int
+	size = func(value lua.LValue) int {
+		switch v := value.(type) {
+		case lua.LString:
+			return len(v)
+		case *lua.LTable:
+			if seen[v] {
+				return 0
+			}
+			seen[v] = true
+
+			total := size(v.Metatable)
+			v.ForEach(func(key lua.LValue, value lua.LValue) {
+				total += entrySize + size(key) + size(value)
+			})
+			return total
+		case *lua.LFunction:
+			if seen[v] {
+				return 0
+			}
+			seen[v] = true
+
+			total := 0
+			for _, upvalue := range v.Upvalues {
+				total += size(upvalue.Value())
+			}
+			return total
+		}
+		return 0
+	}
+
+	return size(L.G.Global)
+}
+
+// Trims the Lua stack trace off of an error, leaving the message
+func errorMessage(err error) string {
+	message := err.Error()
+	if i := strings.Index(message, "\nstack traceback:"); i != -1 {
+		message = message[:i]
+	}
+	return message
+}
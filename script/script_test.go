@@ -0,0 +1,129 @@
+package script
+
+import (
+	"testing"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func Test_Check(t *testing.T) {
+	if err := Check("function on_enter(c) x = 1 end"); err != nil {
+		t.Errorf("Check() of a valid script failed: %s", err)
+	}
+
+	if err := Check("function on_enter(c) x = end"); err == nil {
+		t.Errorf("Check() of a broken script succeeded")
+	}
+}
+
+func Test_Sandbox(t *testing.T) {
+	for _, name := range []string{"os", "io", "debug", "dofile", "loadstring", "require", "package", "print"} {
+		h, err := newHost("found = " + name + " ~= nil")
+		if err != nil {
+			t.Fatalf("newHost() failed: %s", err)
+		}
+
+		if h.state.GetGlobal("found") != lua.LFalse {
+			t.Errorf("%s is available to scripts", name)
+		}
+		h.close()
+	}
+
+	h, _ := newHost("function on_say(c, message) result = string.rep(message, 1000000) end")
+	defer h.close()
+
+	if err := h.call("on_say", lua.LNil, lua.LString("spam")); err == nil {
+		t.Errorf("string.rep() built a huge string")
+	}
+}
+
+func Test_Timeout(t *testing.T) {
+	h, err := newHost("function on_enter(c) while true do end end")
+	if err != nil {
+		t.Fatalf("newHost() failed: %s", err)
+	}
+	defer h.close()
+
+	start := time.Now()
+	if err := h.call("on_enter", lua.LNil); err == nil {
+		t.Errorf("Endless loop didn't time out")
+	}
+
+	if elapsed := time.Since(start); elapsed > 10*Timeout {
+		t.Errorf("Endless loop ran for %v", elapsed)
+	}
+
+	if _, err := newHost("while true do end"); err == nil {
+		t.Errorf("Endless loop while loading didn't time out")
+	}
+}
+
+func Test_Memory(t *testing.T) {
+	defer func(limit uint64) { allocationLimit = limit }(allocationLimit)
+	allocationLimit = 1024 * 1024
+
+	h, err := newHost("function on_enter(c) local t = {} while true do t[#t + 1] = tostring(#t) end end")
+	if err != nil {
+		t.Fatalf("newHost() failed: %s", err)
+	}
+	defer h.close()
+
+	if err := h.call("on_enter", lua.LNil); err == nil || disables(err) {
+		t.Errorf("Growing table wasn't stopped as an overload: %v", err)
+	}
+
+	h, err = newHost(`
+		local kept = {}
+		function on_enter(c)
+			for i = 1, 200 do kept[#kept + 1] = string.rep(tostring(#kept), 1000) end
+		end`)
+	if err != nil {
+		t.Fatalf("newHost() failed: %s", err)
+	}
+	defer h.close()
+
+	for i := 0; i < 10; i++ {
+		if err := h.call("on_enter", lua.LNil); err != nil {
+			if i == 0 {
+				t.Errorf("Small script failed: %s", err)
+			} else if !disables(err) {
+				t.Errorf("Keeping too much data doesn't disable the script: %s", err)
+			}
+			return
+		}
+	}
+	t.Errorf("Script kept more than %d bytes", MaxSize)
+}
+
+func Test_State(t *testing.T) {
+	h, _ := newHost("count = 0 function on_enter(c) count = count + 1 end")
+	defer h.close()
+
+	h.call("on_enter", lua.LNil)
+	h.call("on_enter", lua.LNil)
+	h.call("on_leave", lua.LNil)
+
+	if count := h.state.GetGlobal("count"); count != lua.LNumber(2) {
+		t.Errorf("count == %v, want 2", count)
+	}
+}
+
+func Test_Allowed(t *testing.T) {
+	h := &host{}
+	now := time.Now()
+
+	for i := 0; i < CallsPerSecond; i++ {
+		if !h.allowed(now) {
+			t.Fatalf("Call %v wasn't allowed", i+1)
+		}
+	}
+
+	if h.allowed(now.Add(500 * time.Millisecond)) {
+		t.Errorf("Call over the limit was allowed")
+	}
+
+	if !h.allowed(now.Add(time.Second)) {
+		t.Errorf("Call in the next second wasn't allowed")
+	}
+}
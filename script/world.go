@@ -0,0 +1,431 @@
+package script
+
+import (
+	"fmt"
+
+	"github.com/Cristofori/kmud/combat"
+	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/types"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// How often hosts belonging to deleted objects are cleaned up, in ticks
+const pruneInterval = 60
+
+// hosted is an object's script along with what it's currently running for.
+// A nil host means the script failed to load, and it won't be tried again
+// until it's changed.
+type hosted struct {
+	owner  types.Object
+	source string
+	host   *host
+	call   *call
+}
+
+// call is what a handler is being run for. Scripts can only get at the
+// characters they've been handed, either as arguments or by mud.characters().
+type call struct {
+	state      *lua.LState
+	room       types.Room
+	npc        types.NPC
+	characters map[string]types.Character
+}
+
+// Hosts are only touched by the dispatcher goroutine
+var hosts = map[types.Id]*hosted{}
+
+// Start runs scripts in response to events until the server stops
+func Start() {
+	observer := &events.ObserverReceiver{}
+	eventChannel := events.Register(observer)
+
+	go func() {
+		defer events.Unregister(observer)
+
+		ticks := 0
+		for {
+			event := <-eventChannel
+
+			if _, ok := event.(events.TickEvent); ok {
+				ticks++
+				if ticks%pruneInterval == 0 {
+					prune()
+				}
+				continue
+			}
+
+			dispatch(event)
+		}
+	}()
+}
+
+func prune() {
+	for id, h := range hosts {
+		if h.owner.IsDestroyed() {
+			forget(id)
+		}
+	}
+}
+
+func forget(id types.Id) {
+	if h, found := hosts[id]; found {
+		if h.host != nil {
+			h.host.close()
+		}
+		delete(hosts, id)
+	}
+}
+
+func dispatch(event events.Event) {
+	var roomId types.Id
+	var actor, killer types.Character
+	var handler, message string
+
+	switch e := event.(type) {
+	case events.EnterEvent:
+		roomId, actor, handler = e.RoomId, e.Character, "on_enter"
+	case events.LeaveEvent:
+		roomId, actor, handler = e.RoomId, e.Character, "on_leave"
+	case events.SayEvent:
+		roomId, actor, handler, message = e.Character.GetRoomId(), e.Character, "on_say", e.Message
+	case events.EmoteEvent:
+		roomId, actor, handler, message = e.Character.GetRoomId(), e.Character, "on_emote", e.Emote
+	case events.DeathEvent:
+		roomId, actor, handler, killer = e.Character.GetRoomId(), e.Character, "on_death", e.Killer
+	case events.TimeEvent:
+		dispatchTime(e.Phase)
+		return
+	default:
+		return
+	}
+
+	room := model.GetRoom(roomId)
+	if room == nil {
+		return
+	}
+
+	args := func(c *call) []lua.LValue {
+		values := []lua.LValue{c.character(actor)}
+
+		switch handler {
+		case "on_say", "on_emote":
+			values = append(values, lua.LString(message))
+		case "on_death":
+			if killer != nil {
+				values = append(values, c.character(killer))
+			} else {
+				values = append(values, lua.LNil)
+			}
+		}
+
+		return values
+	}
+
+	run(room, room.GetScript(), room, nil, handler, args)
+
+	characters := model.CharactersIn(room.GetId())
+
+	for _, npc := range model.NpcsIn(room.GetId()) {
+		// NPCs don't react to their own comings and goings, but do get to
+		// know about their own death
+		if npc.GetId() != actor.GetId() || handler == "on_death" {
+			run(npc, npc.GetScript(), room, npc, handler, args)
+		}
+	}
+
+	items := model.ItemsIn(room.GetId())
+	for _, character := range characters {
+		items = append(items, model.ItemsIn(character.GetId())...)
+	}
+
+	for _, item := range items {
+		if template := model.GetTemplate(item.GetTemplateId()); template != nil {
+			run(item, template.GetScript(), room, nil, handler, args)
+		}
+	}
+}
+
+// Changes in the time of day go to every room and NPC script in the world,
+// wherever the players are
+func dispatchTime(phase types.DayPhase) {
+	args := func(c *call) []lua.LValue {
+		return []lua.LValue{lua.LString(phase)}
+	}
+
+	for _, room := range model.GetRooms() {
+		if room.GetScript() != "" {
+			run(room, room.GetScript(), room, nil, "on_time", args)
+		}
+	}
+
+	for _, npc := range model.GetNpcs() {
+		if npc.GetScript() == "" {
+			continue
+		}
+
+		if room := model.GetRoom(npc.GetRoomId()); room != nil {
+			run(npc, npc.GetScript(), room, npc, "on_time", args)
+		}
+	}
+}
+
+func run(owner types.Object, source string, room types.Room, npc types.NPC, handler string, args func(*call) []lua.LValue) {
+	if source == "" {
+		forget(owner.GetId())
+		return
+	}
+
+	h, found := hosts[owner.GetId()]
+	if !found || h.source != source {
+		forget(owner.GetId())
+		h = &hosted{owner: owner, source: source}
+		hosts[owner.GetId()] = h
+
+		host, err := newHost(source)
+		if err != nil {
+			report(owner, err)
+			if !disables(err) {
+				delete(hosts, owner.GetId())
+			}
+			return
+		}
+
+		h.host = host
+		h.bind()
+	}
+
+	if h.host == nil || !h.host.has(handler) {
+		return
+	}
+
+	h.call = &call{state: h.host.state, room: room, npc: npc, characters: map[string]types.Character{}}
+	defer func() { h.call = nil }()
+
+	if npc != nil {
+		h.host.state.SetGlobal("self", h.call.character(npc))
+	} else {
+		self := h.host.state.NewTable()
+		self.RawSetString("id", lua.LString(owner.GetId().Hex()))
+		if nameable, ok := owner.(types.Nameable); ok {
+			self.RawSetString("name", lua.LString(nameable.GetName()))
+		}
+		h.host.state.SetGlobal("self", self)
+	}
+
+	if err := h.host.call(handler, args(h.call)...); err != nil {
+		report(owner, err)
+		if disables(err) {
+			h.host.close()
+			h.host = nil
+		}
+	}
+}
+
+func report(owner types.Object, err error) {
+	name := owner.GetId().Hex()
+	if nameable, ok := owner.(types.Nameable); ok {
+		name = nameable.GetName()
+	}
+	fmt.Printf("Script error in %s: %s\n", name, errorMessage(err))
+}
+
+// Returns the table that stands for the character in the script
+func (self *call) character(character types.Character) lua.LValue {
+	id := character.GetId().Hex()
+	self.characters[id] = character
+
+	_, npc := character.(types.NPC)
+
+	table := self.state.NewTable()
+	table.RawSetString("id", lua.LString(id))
+	table.RawSetString("name", lua.LString(character.GetName()))
+	table.RawSetString("npc", lua.LBool(npc))
+	return table
+}
+
+func (self *call) checkCharacter(L *lua.LState, n int) types.Character {
+	id := L.CheckTable(n).RawGetString("id")
+	character, found := self.characters[id.String()]
+	if !found {
+		L.ArgError(n, "unknown character")
+	}
+	return character
+}
+
+func (self *call) checkNpc(L *lua.LState) types.NPC {
+	if self.npc == nil {
+		L.RaiseError("only NPC scripts can do that")
+	}
+	return self.npc
+}
+
+func (self *call) checkDirection(L *lua.LState, n int) types.Direction {
+	dir := types.StringToDirection(L.CheckString(n))
+	if dir == types.DirectionNone {
+		L.ArgError(n, "invalid direction")
+	}
+	return dir
+}
+
+// Fills in the mud table with the functions scripts use to affect the world
+func (self *hosted) bind() {
+	L := self.host.state
+
+	// Wraps the functions so they don't have to check whether a handler is
+	// running, which it won't be while the script is first loaded
+	fn := func(f func(*call, *lua.LState) int) *lua.LFunction {
+		return L.NewFunction(func(L *lua.LState) int {
+			if self.call == nil {
+				L.RaiseError("mud functions can only be used from a handler")
+			}
+			return f(self.call, L)
+		})
+	}
+
+	functions := map[string]func(*call, *lua.LState) int{
+		// mud.echo(message) shows a message to everyone in the room
+		"echo": func(c *call, L *lua.LState) int {
+			events.Broadcast(events.EchoEvent{RoomId: c.room.GetId(), Message: L.CheckString(1)})
+			return 0
+		},
+		// mud.tell(character, message) shows a message to one character
+		"tell": func(c *call, L *lua.LState) int {
+			events.Broadcast(events.EchoEvent{To: c.checkCharacter(L, 1), Message: L.CheckString(2)})
+			return 0
+		},
+		// mud.say(message) makes the NPC say something
+		"say": func(c *call, L *lua.LState) int {
+			events.Broadcast(events.SayEvent{Character: c.checkNpc(L), Message: L.CheckString(1)})
+			return 0
+		},
+		// mud.emote(emote) makes the NPC emote
+		"emote": func(c *call, L *lua.LState) int {
+			events.Broadcast(events.EmoteEvent{Character: c.checkNpc(L), Emote: L.CheckString(1)})
+			return 0
+		},
+		// mud.move(direction) moves the NPC, returning false if it can't go that way
+		"move": func(c *call, L *lua.LState) int {
+			err := model.MoveCharacter(c.checkNpc(L), c.checkDirection(L, 1))
+			L.Push(lua.LBool(err == nil))
+			return 1
+		},
+		// mud.characters() lists everyone in the room
+		"characters": func(c *call, L *lua.LState) int {
+			list := L.NewTable()
+			for _, character := range model.CharactersIn(c.room.GetId()) {
+				list.Append(c.character(character))
+			}
+			L.Push(list)
+			return 1
+		},
+		// mud.hitpoints(character) returns the character's hitpoints and maximum
+		"hitpoints": func(c *call, L *lua.LState) int {
+			character := c.checkCharacter(L, 1)
+			L.Push(lua.LNumber(character.GetHitPoints()))
+			L.Push(lua.LNumber(character.GetHealth()))
+			return 2
+		},
+		// mud.hurt(character, amount) can kill the character
+		"hurt": func(c *call, L *lua.LState) int {
+			character := c.checkCharacter(L, 1)
+			character.Hit(L.CheckInt(2))
+			if character.GetHitPoints() <= 0 {
+				combat.Kill(character)
+			}
+			return 0
+		},
+		// mud.heal(character, amount)
+		"heal": func(c *call, L *lua.LState) int {
+			c.checkCharacter(L, 1).Heal(L.CheckInt(2))
+			return 0
+		},
+		// mud.give(character, template) creates an item and gives it to the
+		// character, returning false if there's no such template
+		"give": func(c *call, L *lua.LState) int {
+			character := c.checkCharacter(L, 1)
+			template := model.GetTemplateByName(L.CheckString(2))
+			if template != nil {
+				item := model.CreateItem(template.GetId())
+				item.SetContainerId(character.GetId(), nil)
+			}
+			L.Push(lua.LBool(template != nil))
+			return 1
+		},
+		// mud.lock(direction) and mud.unlock(direction) return false if
+		// there's no door that way
+		"lock": func(c *call, L *lua.LState) int {
+			return setLocked(c, L, true)
+		},
+		"unlock": func(c *call, L *lua.LState) int {
+			return setLocked(c, L, false)
+		},
+		// mud.open(direction) and mud.close(direction) return false if there's
+		// no door that way
+		"open": func(c *call, L *lua.LState) int {
+			return setClosed(c, L, false)
+		},
+		"close": func(c *call, L *lua.LState) int {
+			return setClosed(c, L, true)
+		},
+		// mud.teleport(character, x, y, z) moves the character to another room
+		// in the zone, returning false if there's no room there or either room
+		// keeps out teleporting
+		"teleport": func(c *call, L *lua.LState) int {
+			character := c.checkCharacter(L, 1)
+			location := types.Coordinate{X: L.CheckInt(2), Y: L.CheckInt(3), Z: L.CheckInt(4)}
+			destination := model.GetRoomByLocation(location, c.room.GetZoneId())
+			moved := destination != nil && model.CanTeleport(model.GetRoom(character.GetRoomId()), destination)
+			if moved {
+				model.MoveCharacterToRoom(character, destination)
+			}
+			L.Push(lua.LBool(moved))
+			return 1
+		},
+		// mud.daytime() returns true during the day
+		"daytime": func(c *call, L *lua.LState) int {
+			L.Push(lua.LBool(model.GetWorld().IsDaytime()))
+			return 1
+		},
+		// mud.time() returns the time of day as text
+		"time": func(c *call, L *lua.LState) int {
+			L.Push(lua.LString(model.GetWorld().GetTime().String()))
+			return 1
+		},
+		// mud.phase() returns "Dawn", "Day", "Dusk" or "Night"
+		"phase": func(c *call, L *lua.LState) int {
+			L.Push(lua.LString(model.GetWorld().GetPhase()))
+			return 1
+		},
+		// mud.weather() returns "Clear", "Cloudy", "Rain" or "Storm" for the
+		// room's zone
+		"weather": func(c *call, L *lua.LState) int {
+			L.Push(lua.LString(model.GetZone(c.room.GetZoneId()).GetWeather()))
+			return 1
+		},
+	}
+
+	for name, f := range functions {
+		self.host.mud.RawSetString(name, fn(f))
+	}
+}
+
+func setLocked(c *call, L *lua.LState, locked bool) int {
+	dir := c.checkDirection(L, 1)
+	_, found := c.room.GetDoor(dir)
+	if found {
+		model.SetExitLocked(c.room, dir, locked)
+	}
+	L.Push(lua.LBool(found))
+	return 1
+}
+
+func setClosed(c *call, L *lua.LState, closed bool) int {
+	dir := c.checkDirection(L, 1)
+	_, found := c.room.GetDoor(dir)
+	if found {
+		model.SetExitClosed(c.room, dir, closed)
+	}
+	L.Push(lua.LBool(found))
+	return 1
+}
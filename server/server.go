@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/Cristofori/kmud/audit"
 	"github.com/Cristofori/kmud/database"
 	"github.com/Cristofori/kmud/engine"
 	"github.com/Cristofori/kmud/model"
@@ -80,11 +81,30 @@ func login(conn *wrappedConnection) types.User {
 			}
 			conn.WontEcho()
 
+			if ban := model.GetSanction(user.GetId(), types.SanctionBan); ban != nil {
+				utils.WriteLine(conn, banMessage(ban), types.ColorModeNone)
+				return nil
+			}
+
 			return user
 		}
 	}
 }
 
+func banMessage(ban types.Sanction) string {
+	message := "You have been banned"
+
+	if !ban.GetExpires().IsZero() {
+		message = fmt.Sprintf("%s until %s", message, ban.GetExpires().Format("2006-01-02 15:04"))
+	}
+
+	if ban.GetReason() != "" {
+		message = message + ": " + ban.GetReason()
+	}
+
+	return message
+}
+
 func newUser(conn *wrappedConnection) types.User {
 	for {
 		name := utils.GetUserInput(conn, "Desired username: ", types.ColorModeNone)
@@ -147,8 +167,7 @@ func (self *connectionHandler) newPlayer() types.PC {
 		} else if err := utils.ValidateName(name); err != nil {
 			self.user.WriteLine(err.Error())
 		} else {
-			room := model.GetRooms()[0] // TODO: Better way to pick an initial character location
-			return model.CreatePlayerCharacter(name, self.user.GetId(), room)
+			return model.CreatePlayerCharacter(name, self.user.GetId(), model.GetStartingRoom())
 		}
 	}
 }
@@ -290,15 +309,21 @@ func (self *connectionHandler) specificUserMenu(user types.User) {
 		self.user,
 		func(menu *utils.Menu) {
 			menu.AddAction("d", "Delete", func() {
+				self.audit("delete user", user.GetName(), "")
 				model.DeleteUser(user.GetId())
 				menu.Exit()
 			})
 
-			menu.AddAction("a", fmt.Sprintf("Admin - %v", user.IsAdmin()), func() {
-				u := model.GetUser(user.GetId())
-				u.SetAdmin(!u.IsAdmin())
+			menu.AddAction("r", fmt.Sprintf("Role - %v", user.GetRole()), func() {
+				self.roleMenu(user)
 			})
 
+			if user.GetRole() == types.RoleBuilder {
+				menu.AddAction("z", "Zones", func() {
+					self.builderZoneMenu(user)
+				})
+			}
+
 			if user.IsOnline() {
 				menu.AddAction("w", "Watch", func() {
 					if user == self.user {
@@ -315,6 +340,55 @@ func (self *connectionHandler) specificUserMenu(user types.User) {
 		})
 }
 
+func (self *connectionHandler) roleMenu(user types.User) {
+	utils.ExecMenu("Role", self.user, func(menu *utils.Menu) {
+		for i, role := range types.Roles {
+			r := role
+			menu.AddActionI(i, string(r), func() {
+				old := user.GetRole()
+				user.SetRole(r)
+				self.audit("role", user.GetName(), fmt.Sprintf("%v -> %v", old, r))
+				menu.Exit()
+			})
+		}
+	})
+}
+
+// Toggles which zones a builder is allowed to edit
+func (self *connectionHandler) builderZoneMenu(user types.User) {
+	utils.ExecMenu("Builder zones", self.user, func(menu *utils.Menu) {
+		assigned := map[types.Id]bool{}
+		for _, id := range user.GetBuilderZones() {
+			assigned[id] = true
+		}
+
+		for i, zone := range model.GetZones() {
+			z := zone
+			label := z.GetName()
+			if assigned[z.GetId()] {
+				label = fmt.Sprintf("%s (assigned)", label)
+			}
+
+			menu.AddActionI(i, label, func() {
+				if assigned[z.GetId()] {
+					user.RemoveBuilderZone(z.GetId())
+					self.audit("builder zone", user.GetName(), fmt.Sprintf("removed %s", z.GetName()))
+				} else {
+					user.AddBuilderZone(z.GetId())
+					self.audit("builder zone", user.GetName(), fmt.Sprintf("added %s", z.GetName()))
+				}
+			})
+		}
+	})
+}
+
+func (self *connectionHandler) audit(action string, target string, details string) {
+	err := audit.Log(audit.Entry{User: self.user.GetName(), Action: action, Target: target, Details: details})
+	if err != nil {
+		fmt.Println("Failed to write audit log:", err)
+	}
+}
+
 func (self *connectionHandler) Handle() {
 	go func() {
 		defer self.conn.Close()
@@ -392,6 +466,16 @@ func (self *connectionHandler) launchSession() {
 }
 
 func (self *Server) Start() {
+	Connect()
+
+	var err error
+	self.listener, err = net.Listen("tcp", ":8945")
+	utils.HandleError(err)
+}
+
+// Connect opens the database without starting the server, which is all that
+// offline tools need
+func Connect() {
 	fmt.Printf("Connecting to database... ")
 	session, err := mgo.Dial("localhost")
 
@@ -399,9 +483,6 @@ func (self *Server) Start() {
 
 	fmt.Println("done.")
 
-	self.listener, err = net.Listen("tcp", ":8945")
-	utils.HandleError(err)
-
 	database.Init(database.NewMongoSession(session.Copy()), "mud")
 }
 
@@ -409,6 +490,9 @@ func (self *Server) Bootstrap() {
 	// Create the world object if necessary
 	model.GetWorld()
 
+	model.CreateDefaultChannels()
+	model.AddDefaultDoors()
+
 	// If there are no rooms at all create one
 	rooms := model.GetRooms()
 	if len(rooms) == 0 {
@@ -431,6 +515,15 @@ func (self *Server) Listen() {
 		conn, err := self.listener.Accept()
 		utils.HandleError(err)
 		fmt.Println("Client connected:", conn.RemoteAddr())
+
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		if ban := model.GetAddressBan(host); ban != nil {
+			fmt.Println("Rejected banned address:", host)
+			utils.WriteLine(conn, banMessage(ban), types.ColorModeNone)
+			conn.Close()
+			continue
+		}
+
 		t := telnet.NewTelnet(conn)
 
 		wc := utils.NewWatchableReadWriter(t)
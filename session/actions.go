@@ -2,11 +2,13 @@ package session
 
 import (
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/Cristofori/kmud/combat"
-	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/group"
 	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/trade"
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
 )
@@ -29,7 +31,9 @@ var actions = map[string]action{
 			} else {
 				dir := types.StringToDirection(arg)
 
-				if dir == types.DirectionNone {
+				if dir == types.DirectionNone && !s.canSeeIn(s.GetRoom()) {
+					s.printError("It's too dark to see anything")
+				} else if dir == types.DirectionNone {
 					charList := model.CharactersIn(s.pc.GetRoomId())
 					index := utils.BestMatch(arg, charList.Names())
 
@@ -44,7 +48,7 @@ var actions = map[string]action{
 						index = utils.BestMatch(arg, itemList.Names())
 
 						if index == -1 {
-							s.WriteLine("Nothing to see")
+							s.lookAtExtra(arg)
 						} else if index == -2 {
 							s.printError("Which one do you mean?")
 						} else {
@@ -59,7 +63,7 @@ var actions = map[string]action{
 						}
 					}
 				} else {
-					if s.GetRoom().HasExit(dir) {
+					if model.CanSeeExit(s.pc, s.GetRoom(), dir) {
 						loc := s.GetRoom().NextLocation(dir)
 						roomToSee := model.GetRoomByLocation(loc, s.GetRoom().GetZoneId())
 						if roomToSee != nil {
@@ -88,6 +92,8 @@ var actions = map[string]action{
 				defender := charList[index]
 				if defender.GetId() == s.pc.GetId() {
 					s.printError("You can't attack yourself")
+				} else if s.GetRoom().HasFlag(types.RoomSafe) {
+					s.printError("You can't fight here")
 				} else {
 					combat.StartFight(s.pc, nil, defender)
 				}
@@ -134,7 +140,9 @@ var actions = map[string]action{
 					target = charList[index]
 				}
 
-				if target != nil {
+				if target != nil && s.GetRoom().HasFlag(types.RoomSafe) {
+					s.printError("You can't fight here")
+				} else if target != nil {
 					s.WriteLineColor(types.ColorRed, "Casting %s on %s", skill.GetName(), target.GetName())
 					combat.StartFight(s.pc, skill, target)
 				}
@@ -182,7 +190,7 @@ var actions = map[string]action{
 				s.printError("Which one do you mean?")
 			} else {
 				npc := npcList[index]
-				s.WriteLine(npc.PrettyConversation())
+				s.WriteLine(strings.Replace(npc.PrettyConversation(), "\n", "\r\n", -1))
 			}
 		},
 	},
@@ -228,6 +236,23 @@ var actions = map[string]action{
 				return
 			}
 
+			if !s.canSeeIn(s.GetRoom()) {
+				s.printError("It's too dark to see anything")
+				return
+			}
+
+			if arg == "cash" || arg == "money" {
+				cash := s.GetRoom().GetCash()
+				if cash == 0 {
+					s.printError("There's no cash here")
+				} else if err := model.TransferCash(s.GetRoom(), s.pc, cash); err == nil {
+					s.WriteLine("Picked up %v cash", cash)
+				} else {
+					s.printError("Not found")
+				}
+				return
+			}
+
 			itemsInRoom := model.ItemsIn(s.GetRoom().GetId())
 			index := utils.BestMatch(arg, itemsInRoom.Names())
 
@@ -263,6 +288,7 @@ var actions = map[string]action{
 			}
 
 			s.WriteLinef("Cash: %v", s.pc.GetCash())
+			s.WriteLinef("Experience: %v", s.pc.GetExperience())
 			s.WriteLinef("Weight: %v/%v", model.CharacterWeight(s.pc), s.pc.GetCapacity())
 		},
 	},
@@ -288,6 +314,11 @@ var actions = map[string]action{
 				return
 			}
 
+			if model.GetSanction(s.user.GetId(), types.SanctionJail) != nil {
+				s.printError("You can't leave while you are in jail")
+				return
+			}
+
 			links := s.GetRoom().GetLinks()
 			linkNames := s.GetRoom().LinkNames()
 			index := utils.BestMatch(arg, linkNames)
@@ -304,20 +335,84 @@ var actions = map[string]action{
 			}
 		},
 	},
+	"close": {
+		exec: func(s *Session, arg string) {
+			handleDoor(s, "close", arg, func(dir types.Direction, door types.Door) {
+				if s.GetRoom().IsClosed(dir) {
+					s.printError("The %s is already closed", door.Name)
+				} else {
+					model.SetExitClosed(s.GetRoom(), dir, true)
+				}
+			})
+		},
+	},
 	"lock": {
 		exec: func(s *Session, arg string) {
-			if arg == "" {
-				s.printError("Usage: lock <direction>")
-			}
-			handleLock(s, arg, true)
+			handleDoor(s, "lock", arg, func(dir types.Direction, door types.Door) {
+				room := s.GetRoom()
+				if room.IsLocked(dir) {
+					s.printError("The %s is already locked", door.Name)
+				} else if !room.IsClosed(dir) {
+					s.printError("The %s needs to be closed first", door.Name)
+				} else if checkKey(s, door) {
+					model.SetExitLocked(room, dir, true)
+				}
+			})
 		},
 	},
 	"unlock": {
 		exec: func(s *Session, arg string) {
-			if arg == "" {
-				s.printError("Usage: unlock <direction>")
+			handleDoor(s, "unlock", arg, func(dir types.Direction, door types.Door) {
+				if !s.GetRoom().IsLocked(dir) {
+					s.printError("The %s isn't locked", door.Name)
+				} else if checkKey(s, door) {
+					model.SetExitLocked(s.GetRoom(), dir, false)
+				}
+			})
+		},
+	},
+	"pick": {
+		exec: func(s *Session, arg string) {
+			handleDoor(s, "pick", arg, func(dir types.Direction, door types.Door) {
+				if !s.GetRoom().IsLocked(dir) {
+					s.printError("The %s isn't locked", door.Name)
+				} else if door.Difficulty <= 0 || door.Difficulty >= 100 {
+					s.printError("The lock on the %s can't be picked", door.Name)
+				} else if utils.Random(1, 100) <= door.Difficulty {
+					s.printError("You fail to pick the lock on the %s", door.Name)
+				} else {
+					model.SetExitLocked(s.GetRoom(), dir, false)
+				}
+			})
+		},
+	},
+	"recall": {
+		exec: func(s *Session, arg string) {
+			if combat.InCombat(s.pc) {
+				s.printError("You can't recall while fighting")
+			} else if err := model.Recall(s.pc); err != nil {
+				s.printError(err.Error())
+			} else {
+				s.PrintRoom()
+			}
+		},
+	},
+	"search": {
+		exec: func(s *Session, arg string) {
+			room := s.GetRoom()
+			found := false
+
+			for _, dir := range room.GetExits() {
+				if !model.CanSeeExit(s.pc, room, dir) {
+					model.RevealExit(s.pc, room, dir)
+					s.WriteLinef("You find a hidden exit leading %s", strings.ToLower(dir.ToString()))
+					found = true
+				}
+			}
+
+			if !found {
+				s.WriteLine("You don't find anything")
 			}
-			handleLock(s, arg, false)
 		},
 	},
 	"store": aAlias("shop"),
@@ -340,9 +435,10 @@ var actions = map[string]action{
 						s.execMenu("Buy Items", func(menu *utils.Menu) {
 							items := model.ItemsIn(store.GetId())
 							for i, item := range items {
-								menu.AddActionI(i, item.GetName(), func() {
-									confirmed := s.getConfirmation(fmt.Sprintf("Buy %s for %v? ", item.GetName(), item.GetValue()))
-									if confirmed && sellItem(s, store, s.pc, item) {
+								price := store.SellPrice(item.GetValue())
+								menu.AddActionI(i, fmt.Sprintf("%s (%v)", item.GetName(), price), func() {
+									confirmed := s.getConfirmation(fmt.Sprintf("Buy %s for %v? ", item.GetName(), price))
+									if confirmed && sellItem(s, store, s.pc, item, price) {
 										s.WriteLineColor(types.ColorGreen, "Bought %s", item.GetName())
 									}
 									if len(model.ItemsIn(store.GetId())) == 0 {
@@ -361,9 +457,21 @@ var actions = map[string]action{
 						s.execMenu("Sell Items", func(menu *utils.Menu) {
 							items := model.ItemsIn(s.pc.GetId())
 							for i, item := range items {
-								menu.AddActionI(i, item.GetName(), func() {
-									confirmed := s.getConfirmation(fmt.Sprintf("Sell %s for %v? ", item.GetName(), item.GetValue()))
-									if confirmed && sellItem(s, s.pc, store, item) {
+								template := model.GetTemplate(item.GetTemplateId())
+								if !store.AcceptsCategory(template.GetCategory()) {
+									continue
+								}
+
+								price := store.BuyPrice(item.GetValue())
+								menu.AddActionI(i, fmt.Sprintf("%s (%v)", item.GetName(), price), func() {
+									if !store.CanSpend(price) {
+										s.printError("%s isn't buying anything right now", store.GetName())
+										return
+									}
+
+									confirmed := s.getConfirmation(fmt.Sprintf("Sell %s for %v? ", item.GetName(), price))
+									if confirmed && sellItem(s, s.pc, store, item, price) {
+										store.RecordSpending(price)
 										s.WriteLineColor(types.ColorGreen, "Sold %s", item.GetName())
 									}
 									if len(model.ItemsIn(s.pc.GetId())) == 0 {
@@ -377,9 +485,280 @@ var actions = map[string]action{
 			})
 		},
 	},
+	"trade": {
+		exec: func(s *Session, arg string) {
+			usage := func() {
+				s.printError("Usage: trade <player> | trade offer <item|cash amount> | trade remove <item> | trade confirm | trade cancel")
+			}
+
+			subcommand, rest := utils.Argify(arg)
+
+			var err error
+
+			switch subcommand {
+			case "":
+				err = trade.Status(s.pc)
+			case "offer":
+				if rest == "" {
+					usage()
+					return
+				}
+
+				what, amount := utils.Argify(rest)
+				if what == "cash" || what == "money" {
+					var cash int
+					cash, err = utils.Atoir(amount, 0, math.MaxInt32)
+					if err == nil {
+						err = trade.OfferCash(s.pc, cash)
+					}
+				} else {
+					items := model.ItemsIn(s.pc.GetId())
+					index := utils.BestMatch(rest, items.Names())
+
+					if index == -1 {
+						s.printError("Not found")
+					} else if index == -2 {
+						s.printError("Which one do you mean?")
+					} else {
+						err = trade.OfferItem(s.pc, items[index])
+					}
+				}
+			case "remove":
+				if rest == "" {
+					usage()
+					return
+				}
+
+				items := model.ItemsIn(s.pc.GetId())
+				index := utils.BestMatch(rest, items.Names())
+
+				if index == -1 {
+					s.printError("Not found")
+				} else if index == -2 {
+					s.printError("Which one do you mean?")
+				} else {
+					err = trade.WithdrawItem(s.pc, items[index])
+				}
+			case "confirm", "accept":
+				err = trade.Confirm(s.pc)
+			case "cancel":
+				trade.Cancel(s.pc)
+			default:
+				pcs := model.PlayerCharactersIn(s.pc.GetRoomId(), s.pc.GetId())
+				index := utils.BestMatch(arg, pcs.Characters().Names())
+
+				if index == -1 {
+					s.printError("Not found")
+				} else if index == -2 {
+					s.printError("Which one do you mean?")
+				} else {
+					err = trade.Request(s.pc, pcs[index])
+				}
+			}
+
+			if err != nil {
+				s.printError(err.Error())
+			}
+		},
+	},
+	"history": {
+		exec: func(s *Session, arg string) {
+			s.showHistory(s.pc, arg)
+		},
+	},
+	"mail": {
+		exec: func(s *Session, arg string) {
+			usage := func() {
+				s.printError("Usage: mail | mail send <player> | mail read <#> | mail reply <#> | mail delete <#>")
+			}
+
+			subcommand, rest := utils.Argify(arg)
+			mail := model.GetUserMail(s.user.GetId())
+
+			pick := func() types.Mail {
+				index, err := utils.Atoir(rest, 1, len(mail))
+				if err != nil {
+					s.printError("Invalid message number")
+					return nil
+				}
+				return mail[index-1]
+			}
+
+			if (subcommand == "send" || subcommand == "reply") && s.isMuted() {
+				s.printError("You have been muted")
+				return
+			}
+
+			switch subcommand {
+			case "":
+				if len(mail) == 0 {
+					s.WriteLine("You have no mail")
+					return
+				}
+
+				for i, m := range mail {
+					status := " "
+					if !m.IsRead() {
+						status = types.Colorize(types.ColorYellow, "*")
+					}
+
+					s.WriteLine("%s%3v. %s  %-12s %s", status, i+1,
+						m.GetSent().Format("2006-01-02 15:04"), m.GetFromName(), m.GetSubject())
+				}
+			case "send":
+				if rest == "" {
+					usage()
+					return
+				}
+
+				var toUserId types.Id
+				var toName string
+
+				if pc := model.GetPlayerCharacterByName(rest); pc != nil {
+					toUserId, toName = pc.GetUserId(), pc.GetName()
+				} else if user := model.GetUserByName(rest); user != nil {
+					toUserId, toName = user.GetId(), user.GetName()
+				} else {
+					s.printError("Player '%s' not found", rest)
+					return
+				}
+
+				s.composeMail(toUserId, toName, s.getRawUserInput("Subject: "))
+			case "read":
+				if m := pick(); m != nil {
+					s.WriteLine("From: %s", m.GetFromName())
+					s.WriteLine("To: %s", m.GetToName())
+					s.WriteLine("Date: %s", m.GetSent().Format("2006-01-02 15:04"))
+					s.WriteLine("Subject: %s", m.GetSubject())
+					s.WriteLine("")
+					s.WriteLine(strings.Replace(m.GetBody(), "\n", "\r\n", -1))
+					m.MarkRead()
+				}
+			case "reply":
+				if m := pick(); m != nil {
+					from := model.GetPlayerCharacterByName(m.GetFromName())
+					if from == nil {
+						s.printError("%s no longer exists", m.GetFromName())
+						return
+					}
+
+					subject := m.GetSubject()
+					if !strings.HasPrefix(subject, "Re: ") {
+						subject = "Re: " + subject
+					}
+
+					s.composeMail(from.GetUserId(), from.GetName(), subject)
+				}
+			case "delete":
+				if m := pick(); m != nil {
+					model.DeleteMail(m.GetId())
+					s.WriteLine("Message deleted")
+				}
+			default:
+				usage()
+			}
+		},
+	},
+	"gs": {
+		exec: func(s *Session, arg string) {
+			groupSay(s, arg)
+		},
+	},
+	"group": {
+		exec: func(s *Session, arg string) {
+			usage := func() {
+				s.printError("Usage: group | group invite <player> | group accept | group decline | group leave | group disband | group follow | gs <message>")
+			}
+
+			subcommand, rest := utils.Argify(arg)
+
+			var err error
+
+			switch subcommand {
+			case "":
+				members := group.Members(s.pc)
+				if members == nil {
+					s.WriteLine("You aren't in a group")
+					return
+				}
+
+				leader := group.Leader(s.pc)
+				for _, member := range members {
+					role := ""
+					if member == leader {
+						role = " (leader)"
+					}
+					s.WriteLine("  %s%s - %v/%v", member.GetName(), role, member.GetHitPoints(), member.GetHealth())
+				}
+			case "invite":
+				if rest == "" {
+					usage()
+					return
+				}
+
+				pcs := model.PlayerCharactersIn(s.pc.GetRoomId(), s.pc.GetId())
+				index := utils.BestMatch(rest, pcs.Characters().Names())
+
+				if index == -1 {
+					s.printError("Not found")
+				} else if index == -2 {
+					s.printError("Which one do you mean?")
+				} else {
+					err = group.Invite(s.pc, pcs[index])
+				}
+			case "accept", "join":
+				err = group.Accept(s.pc)
+			case "decline":
+				err = group.Decline(s.pc)
+			case "leave":
+				group.Leave(s.pc)
+			case "disband":
+				err = group.Disband(s.pc)
+			case "follow":
+				var following bool
+				following, err = group.Follow(s.pc)
+				if err == nil {
+					if following {
+						s.WriteLine("You are now following %s", group.Leader(s.pc).GetName())
+					} else {
+						s.WriteLine("You are no longer following anyone")
+					}
+				}
+			default:
+				message := arg
+				if subcommand == "say" {
+					message = rest
+				}
+
+				if message == "" {
+					usage()
+				} else {
+					groupSay(s, message)
+				}
+			}
+
+			if err != nil {
+				s.printError(err.Error())
+			}
+		},
+	},
 	"o": aAlias("open"),
 	"open": {
 		exec: func(s *Session, arg string) {
+			if _, _, found := findDoor(s.pc, s.GetRoom(), arg); found {
+				handleDoor(s, "open", arg, func(dir types.Direction, door types.Door) {
+					room := s.GetRoom()
+					if !room.IsClosed(dir) {
+						s.printError("The %s is already open", door.Name)
+					} else if room.IsLocked(dir) {
+						s.printError("The %s is locked", door.Name)
+					} else {
+						model.SetExitClosed(room, dir, false)
+					}
+				})
+				return
+			}
+
 			items := model.ItemsIn(s.GetRoom().GetId())
 			containers := types.ItemList{}
 
@@ -439,45 +818,90 @@ var actions = map[string]action{
 	},
 }
 
-func handleLock(s *Session, arg string, locked bool) {
-	dir := types.StringToDirection(arg)
+// Returns the door the argument refers to, either by direction or by name.
+// Doors in hidden exits the character hasn't found yet aren't there.
+func findDoor(character types.Character, room types.Room, arg string) (types.Direction, types.Door, bool) {
+	if arg == "" {
+		return types.DirectionNone, types.Door{}, false
+	}
 
-	if dir == types.DirectionNone {
-		s.printError("Invalid direction")
-	} else {
-		s.GetRoom().SetLocked(dir, locked)
+	if dir := types.StringToDirection(arg); dir != types.DirectionNone {
+		if !model.CanSeeExit(character, room, dir) {
+			return dir, types.Door{}, false
+		}
+		door, found := room.GetDoor(dir)
+		return dir, door, found
+	}
 
-		events.Broadcast(events.LockEvent{
-			RoomId: s.pc.GetRoomId(),
-			Exit:   dir,
-			Locked: locked,
-		})
+	var dirs []types.Direction
+	var names []string
+	for _, exit := range model.VisibleExits(character, room) {
+		if door, found := room.GetDoor(exit); found {
+			dirs = append(dirs, exit)
+			names = append(names, door.Name)
+		}
+	}
 
-		// Lock on both sides
-		location := s.GetRoom().NextLocation(dir)
-		otherRoom := model.GetRoomByLocation(location, s.GetRoom().GetZoneId())
+	if index := utils.BestMatch(arg, names); index >= 0 {
+		door, _ := room.GetDoor(dirs[index])
+		return dirs[index], door, true
+	}
 
-		if otherRoom != nil {
-			otherRoom.SetLocked(dir.Opposite(), locked)
+	return types.DirectionNone, types.Door{}, false
+}
 
-			events.Broadcast(events.LockEvent{
-				RoomId: otherRoom.GetId(),
-				Exit:   dir.Opposite(),
-				Locked: locked,
-			})
-		}
+// Hands the door the argument refers to to the handler
+func handleDoor(s *Session, action string, arg string, handler func(types.Direction, types.Door)) {
+	if arg == "" {
+		s.printError("Usage: %s <direction|door>", action)
+		return
+	}
+
+	dir, door, found := findDoor(s.pc, s.GetRoom(), arg)
+	if !found {
+		s.printError("There's no door there")
+		return
 	}
+
+	handler(dir, door)
 }
 
-func sellItem(s *Session, seller types.Purchaser, buyer types.Purchaser, item types.Item) bool {
-	// TODO - Transferring the item and money needs be guarded in some kind of atomic transaction
-	if item.SetContainerId(buyer.GetId(), seller.GetId()) {
-		buyer.RemoveCash(item.GetValue())
-		seller.AddCash(item.GetValue())
+// Returns true if the character is carrying the door's key
+func checkKey(s *Session, door types.Door) bool {
+	if door.KeyId == nil {
+		s.printError("The %s has no keyhole", door.Name)
+		return false
+	}
+
+	if model.Carries(s.pc, door.KeyId) {
 		return true
-	} else {
-		s.printError("Transaction failed")
 	}
 
+	s.printError("You don't have the key to the %s", door.Name)
 	return false
 }
+
+func sellItem(s *Session, seller types.Purchaser, buyer types.Purchaser, item types.Item, price int) bool {
+	tx := model.NewTransaction()
+	tx.MoveItem(item, seller.GetId(), buyer.GetId())
+	tx.TransferCash(buyer, seller, price)
+
+	if err := tx.Commit(); err != nil {
+		s.printError("Transaction failed: %s", err)
+		return false
+	}
+
+	return true
+}
+
+// groupSay sends the whole message to the player's group, even when it
+// starts with one of the group subcommands
+func groupSay(s *Session, message string) {
+	if message == "" {
+		s.printError("Nothing to say")
+	} else if s.isMuted() {
+		s.printError("You have been muted")
+	} else if err := group.Say(s.pc, message); err != nil {
+		s.printError(err.Error())
+	}
+}
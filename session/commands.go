@@ -3,22 +3,29 @@ package session
 import (
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/Cristofori/kmud/audit"
 	"github.com/Cristofori/kmud/combat"
+	"github.com/Cristofori/kmud/diku"
 	"github.com/Cristofori/kmud/engine"
+	"github.com/Cristofori/kmud/events"
 	"github.com/Cristofori/kmud/model"
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
+	"github.com/Cristofori/kmud/worldfile"
 )
 
 type command struct {
-	admin bool
-	alias string
-	exec  func(*command, *Session, string)
-	usage string
+	permission types.Permission
+	chat       bool
+	alias      string
+	exec       func(*command, *Session, string)
+	usage      string
 }
 
 func (self *command) Usage(s *Session) {
@@ -63,20 +70,65 @@ func init() {
 			},
 		},
 		"store": {
-			admin: true,
+			permission: types.PermissionBuild,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Store", func(menu *utils.Menu) {
 					store := model.StoreIn(s.pc.GetRoomId())
 
 					if store != nil {
+						menu.SetTitle(fmt.Sprintf("Store - %s", store.GetName()))
+
 						menu.AddAction("r", "Rename", func() {
 							name := s.getCleanUserInput("New name: ")
 							if name != "" {
-								store.SetName("Name")
+								store.SetName(name)
+							}
+						})
+
+						menu.AddAction("s", "Stock", func() {
+							s.storeStockMenu(store)
+						})
+
+						menu.AddAction("b", fmt.Sprintf("Buy rate - %v%%", store.GetBuyRate()), func() {
+							rate, valid := s.getInt("Percentage of an item's value paid when buying: ", 1, 1000)
+							if valid {
+								store.SetBuyRate(rate)
+							}
+						})
+
+						menu.AddAction("e", fmt.Sprintf("Sell rate - %v%%", store.GetSellRate()), func() {
+							rate, valid := s.getInt("Percentage of an item's value charged when selling: ", 1, 1000)
+							if valid {
+								store.SetSellRate(rate)
+							}
+						})
+
+						menu.AddAction("l", fmt.Sprintf("Spend limit - %v", store.GetSpendLimit()), func() {
+							limit, valid := s.getInt("Maximum cash spent per hour (0 for no limit): ", 0, math.MaxInt32)
+							if valid {
+								store.SetSpendLimit(limit)
 							}
 						})
+
+						menu.AddAction("c", fmt.Sprintf("Cash - %v", store.GetCash()), func() {
+							cash, valid := s.getInt("New cash amount: ", 0, math.MaxInt32)
+							if valid {
+								store.SetCash(cash)
+							}
+						})
+
+						categories := "Any"
+						if len(store.GetCategories()) > 0 {
+							categories = strings.Join(store.GetCategories(), ", ")
+						}
+
+						menu.AddAction("a", fmt.Sprintf("Accepted categories - %s", categories), func() {
+							s.storeCategoryMenu(store)
+						})
+
 						menu.AddAction("d", "Delete", func() {
 							model.DeleteStore(store.GetId())
+							menu.Exit()
 						})
 					} else {
 						menu.AddAction("n", "New Store", func() {
@@ -91,13 +143,12 @@ func init() {
 		},
 		"loc": cAlias("location"),
 		"location": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				s.WriteLine("%v", s.GetRoom().GetLocation())
 			},
 		},
 		"room": {
-			admin: true,
+			permission: types.PermissionBuild,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu(
 					"Room",
@@ -110,16 +161,69 @@ func init() {
 						})
 
 						menu.AddAction("d", "Description", func() {
-							description := s.getRawUserInput("Enter new description: ")
-							if description != "" {
+							description, saved := s.editText("room description", s.GetRoom().GetDescription())
+							if saved {
 								s.GetRoom().SetDescription(description)
 							}
 						})
 
+						menu.AddAction("n", "Night description", func() {
+							description, saved := s.editText("night description", s.GetRoom().GetNightDescription())
+							if saved {
+								s.GetRoom().SetNightDescription(description)
+							}
+						})
+
 						menu.AddAction("e", "Exits", func() {
 							toggleExitMenu(s)
 						})
 
+						menu.AddAction("x", fmt.Sprintf("Extra descriptions - %v", len(s.GetRoom().GetExtraDescriptions())), func() {
+							s.extraDescriptionMenu(s.GetRoom())
+						})
+
+						menu.AddAction("c", fmt.Sprintf("Actions - %v", len(s.GetRoom().GetActions())), func() {
+							roomActionsMenu(s, s.GetRoom())
+						})
+
+						menu.AddAction("s", "Script", func() {
+							s.editScript("room script", s.GetRoom())
+						})
+
+						menu.AddAction("o", "Doors", func() {
+							doorsMenu(s, s.GetRoom())
+						})
+
+						menu.AddAction("p", "Passages", func() {
+							passagesMenu(s, s.GetRoom())
+						})
+
+						menu.AddAction("f", fmt.Sprintf("Flags - %v", len(s.GetRoom().GetFlags())), func() {
+							roomFlagsMenu(s, s.GetRoom())
+						})
+
+						terrain := s.GetRoom().GetTerrain()
+						if terrain == "" {
+							terrain = "(None)"
+						}
+
+						menu.AddAction("r", fmt.Sprintf("Terrain - %s", terrain), func() {
+							s.execMenu("Terrain", func(menu *utils.Menu) {
+								menu.AddAction("n", "None", func() {
+									s.GetRoom().SetTerrain("")
+									menu.Exit()
+								})
+
+								for i, terrain := range types.Terrains {
+									t := terrain
+									menu.AddActionI(i, fmt.Sprintf("%s - cost %v", t, t.MovementCost()), func() {
+										s.GetRoom().SetTerrain(t)
+										menu.Exit()
+									})
+								}
+							})
+						})
+
 						areaId := s.GetRoom().GetAreaId()
 						areaName := "(None)"
 						if areaId != nil {
@@ -152,7 +256,6 @@ func init() {
 			},
 		},
 		"map": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				zoneRooms := model.GetRoomsInZone(s.currentZone().GetId())
 				roomsByLocation := map[types.Coordinate]types.Room{}
@@ -172,6 +275,7 @@ func init() {
 
 				builder := newMapBuilder(width, height, 1)
 				builder.setUserRoom(s.GetRoom())
+				builder.setCharacter(s.pc)
 				center := s.GetRoom().GetLocation()
 
 				startX := center.X - (width / 2)
@@ -195,8 +299,8 @@ func init() {
 			},
 		},
 		"zone": {
-			admin: true,
-			usage: "/zone [list|rename <name>|new <name>|delete <name>]",
+			permission: types.PermissionBuild,
+			usage:      "/zone [list|rename <name>|new <name>|delete <name>]",
 			exec: func(self *command, s *Session, arg string) {
 				subcommand, arg := utils.Argify(arg)
 				if subcommand == "" {
@@ -207,6 +311,8 @@ func init() {
 					for _, zone := range model.GetZones() {
 						s.WriteLine(zone.GetName())
 					}
+				} else if (subcommand == "new" || subcommand == "delete") && !s.user.HasPermission(types.PermissionAdmin) {
+					s.printError("Only admins can create or delete zones")
 				} else if arg != "" {
 					if subcommand == "rename" {
 						zone := model.GetZoneByName(arg)
@@ -238,6 +344,8 @@ func init() {
 							if zone == s.currentZone() {
 								s.printError("You can't delete the zone you are in")
 							} else {
+								s.audit("delete zone", zone.GetName(),
+									fmt.Sprintf("%v rooms", len(model.GetRoomsInZone(zone.GetId()))))
 								model.DeleteZone(zone.GetId())
 								s.WriteLine("Zone deleted")
 							}
@@ -254,7 +362,7 @@ func init() {
 		},
 		"b": cAlias("broadcast"),
 		"broadcast": {
-			admin: false,
+			chat: true,
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					s.printError("Nothing to say")
@@ -265,7 +373,7 @@ func init() {
 		},
 		"s": cAlias("say"),
 		"say": {
-			admin: false,
+			chat: true,
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					s.printError("Nothing to say")
@@ -275,22 +383,313 @@ func init() {
 			},
 		},
 		"me": {
-			admin: false,
+			chat: true,
 			exec: func(self *command, s *Session, arg string) {
 				model.Emote(s.pc, arg)
 			},
 		},
+		"history": {
+			permission: types.PermissionModerate,
+			usage:      "/history <player> [tells|says|channels]",
+			exec: func(self *command, s *Session, arg string) {
+				name, kind := utils.Argify(arg)
+				if name == "" {
+					self.Usage(s)
+					return
+				}
+
+				pc := model.GetPlayerCharacterByName(name)
+				if pc == nil {
+					s.printError("Player '%s' not found", name)
+					return
+				}
+
+				s.showHistory(pc, kind)
+			},
+		},
+		"ignore": {
+			usage: "/ignore [player]",
+			exec: func(self *command, s *Session, arg string) {
+				if arg == "" {
+					var names []string
+					for _, id := range s.user.GetIgnored() {
+						names = append(names, model.GetUserCharacters(id).Characters().Names()...)
+					}
+
+					if len(names) == 0 {
+						s.WriteLine("You aren't ignoring anyone")
+					} else {
+						sort.Strings(names)
+						s.WriteLine("Ignoring: %s", strings.Join(names, ", "))
+					}
+					return
+				}
+
+				target := model.GetPlayerCharacterByName(arg)
+				if target == nil {
+					s.printError("Player '%s' not found", arg)
+					return
+				}
+
+				user := model.GetUser(target.GetUserId())
+				if user == s.user {
+					s.printError("You can't ignore yourself")
+				} else if user.HasPermission(types.PermissionModerate) {
+					s.printError("You can't ignore a moderator")
+				} else {
+					s.user.Ignore(user.GetId())
+					s.WriteLine("You are now ignoring %s", target.GetName())
+				}
+			},
+		},
+		"unignore": {
+			usage: "/unignore <player>",
+			exec: func(self *command, s *Session, arg string) {
+				if arg == "" {
+					self.Usage(s)
+					return
+				}
+
+				target := model.GetPlayerCharacterByName(arg)
+				if target == nil {
+					s.printError("Player '%s' not found", arg)
+					return
+				}
+
+				s.user.Unignore(target.GetUserId())
+				s.WriteLine("You are no longer ignoring %s", target.GetName())
+			},
+		},
+		"kick": {
+			permission: types.PermissionModerate,
+			usage:      "/kick <player> [reason]",
+			exec: func(self *command, s *Session, arg string) {
+				name, reason := utils.Argify(arg)
+				target := model.GetPlayerCharacterByName(name)
+
+				if name == "" {
+					self.Usage(s)
+				} else if target == nil || !target.IsOnline() {
+					s.printError("Player '%s' not found", name)
+				} else if user := model.GetUser(target.GetUserId()); !s.outranks(user) {
+					s.printError("You can't do that to %s", target.GetName())
+				} else {
+					kick(user, reason)
+					s.WriteLine("Kicked %s", target.GetName())
+				}
+			},
+		},
+		"mute":  sanctionCommand(types.SanctionMute),
+		"jail":  sanctionCommand(types.SanctionJail),
+		"ban":   sanctionCommand(types.SanctionBan),
+		"ipban": sanctionCommand(types.SanctionIpBan),
+		"export": {
+			permission: types.PermissionAdmin,
+			usage:      "/export [zone]",
+			exec: func(self *command, s *Session, arg string) {
+				zone := s.currentZone()
+				if arg != "" {
+					zone = model.GetZoneByName(arg)
+					if zone == nil {
+						s.printError("Zone not found")
+						return
+					}
+				}
+
+				file, warnings := worldfile.Export(zone)
+				for _, warning := range warnings {
+					s.WriteLineColor(types.ColorYellow, warning)
+				}
+
+				path := worldfile.Path(zone.GetName())
+				err := os.MkdirAll(worldfile.Dir, 0755)
+				if err == nil {
+					err = worldfile.Save(path, file)
+				}
+
+				if err != nil {
+					s.printError("Export failed: %s", err)
+				} else {
+					s.WriteLine("Exported %v rooms to %s", len(file.Rooms), path)
+				}
+			},
+		},
+		"import": {
+			permission: types.PermissionAdmin,
+			usage:      "/import <file|area file.are> [zone name]",
+			exec: func(self *command, s *Session, arg string) {
+				name, zoneName := utils.Argify(arg)
+				if name == "" {
+					self.Usage(s)
+					return
+				}
+
+				var file *worldfile.File
+				var err error
+
+				if path := worldfile.Path(name); filepath.Ext(path) == ".are" {
+					var warnings []string
+					file, warnings, err = diku.Load(path)
+					for _, warning := range warnings {
+						s.WriteLineColor(types.ColorYellow, warning)
+					}
+				} else {
+					file, err = worldfile.Load(path)
+				}
+
+				if err != nil {
+					s.printError("Import failed: %s", err)
+					return
+				}
+
+				conflicts := worldfile.Conflicts(file, zoneName)
+				for _, conflict := range conflicts {
+					s.WriteLineColor(types.ColorYellow, conflict.String())
+				}
+
+				if len(conflicts) > 0 && !s.getConfirmation("Existing templates, skills and effects will be reused, proceed? ") {
+					return
+				}
+
+				zone, err := worldfile.Import(file, zoneName)
+				if err != nil {
+					s.printError("Import failed: %s", err)
+					return
+				}
+
+				for _, area := range model.GetAreas(zone) {
+					for _, spawner := range model.GetAreaSpawners(area.GetId()) {
+						engine.StartSpawner(spawner)
+					}
+				}
+
+				for _, room := range model.GetRoomsInZone(zone.GetId()) {
+					engine.WatchRoom(room)
+				}
+
+				s.WriteLine("Imported %s with %v rooms", zone.GetName(), len(file.Rooms))
+			},
+		},
+		"jailroom": {
+			permission: types.PermissionAdmin,
+			usage:      "/jailroom",
+			exec: func(self *command, s *Session, arg string) {
+				model.GetWorld().SetJailRoomId(s.pc.GetRoomId())
+				s.WriteLine("This room is now the jail")
+			},
+		},
+		"sanctions": {
+			permission: types.PermissionModerate,
+			usage:      "/sanctions [pardon <#>]",
+			exec: func(self *command, s *Session, arg string) {
+				sanctions := model.GetSanctions()
+				subcommand, rest := utils.Argify(arg)
+
+				switch subcommand {
+				case "":
+					if len(sanctions) == 0 {
+						s.WriteLine("There are no active sanctions")
+					}
+
+					for i, sanction := range sanctions {
+						target := sanction.GetAddress()
+						if user := model.GetUser(sanction.GetUserId()); user != nil {
+							target = user.GetName()
+						}
+
+						expires := "never"
+						if !sanction.GetExpires().IsZero() {
+							expires = sanction.GetExpires().Format("2006-01-02 15:04")
+						}
+
+						s.WriteLine("%3v. %-6s %-16s by %-12s expires %s: %s", i+1,
+							sanction.GetKind(), target, sanction.GetIssuer(), expires, sanction.GetReason())
+					}
+				case "pardon":
+					index, err := utils.Atoir(rest, 1, len(sanctions))
+					if err != nil {
+						s.printError(err.Error())
+					} else if user := model.GetUser(sanctions[index-1].GetUserId()); user != nil && !s.outranks(user) {
+						s.printError("You can't pardon %s", user.GetName())
+					} else {
+						model.DeleteSanction(sanctions[index-1].GetId())
+						s.WriteLine("Sanction lifted")
+					}
+				default:
+					self.Usage(s)
+				}
+			},
+		},
+		"audit": {
+			permission: types.PermissionAdmin,
+			usage:      "/audit [user <name>|search <text>] [count]",
+			exec: func(self *command, s *Session, arg string) {
+				subcommand, rest := utils.Argify(arg)
+
+				var filter func(audit.Entry) bool
+				switch subcommand {
+				case "user":
+					name, count := utils.Argify(rest)
+					rest = count
+					filter = func(entry audit.Entry) bool {
+						return strings.EqualFold(entry.User, name) || strings.EqualFold(entry.Character, name)
+					}
+				case "search":
+					text, count := utils.Argify(rest)
+					rest = count
+					filter = func(entry audit.Entry) bool {
+						return entry.Matches(text)
+					}
+				default:
+					rest = subcommand
+				}
+
+				count := 20
+				if rest != "" {
+					var err error
+					count, err = utils.Atoir(rest, 1, 1000)
+					if err != nil {
+						self.Usage(s)
+						return
+					}
+				}
+
+				entries, err := audit.Query(filter, count)
+				if err != nil {
+					s.printError(err.Error())
+					return
+				}
+
+				if len(entries) == 0 {
+					s.WriteLine("No matching entries")
+				}
+
+				for _, entry := range entries {
+					s.WriteLine("%s %s/%s %s %s %s",
+						types.Colorize(types.ColorBlue, entry.Time.Format("2006-01-02 15:04:05")),
+						entry.User, entry.Character,
+						types.Colorize(types.ColorYellow, entry.Action),
+						entry.Target,
+						types.Colorize(types.ColorBlue, entry.Details))
+				}
+			},
+		},
+		"ch": cAlias("channel"),
+		"channel": {
+			usage: "/channel [join|leave|mute|history|who|create|delete|color <channel>] | /channel [silence|unsilence|kick|unkick <channel> <player>] | /<channel> <message>",
+			exec:  channelCommand,
+		},
 		"w":    cAlias("whisper"),
 		"tell": cAlias("whisper"),
 		"whisper": {
-			admin: false,
+			chat:  true,
 			usage: "/whisper <player> <message>",
 			exec:  whisper,
 		},
 		"tp": cAlias("teleport"),
 		"teleport": {
-			admin: true,
-			usage: "/teleport [<zone>|<X> <Y> <Z>]",
+			permission: types.PermissionHelp,
+			usage:      "/teleport [<zone>|<X> <Y> <Z>]",
 			exec: func(self *command, s *Session, arg string) {
 				newZone := model.GetZoneByName(arg)
 				var newRoom types.Room
@@ -324,13 +723,17 @@ func init() {
 				}
 
 				if newRoom != nil {
+					if !model.CanTeleport(s.GetRoom(), newRoom) && !s.user.CanBuildIn(newRoom.GetZoneId()) {
+						s.printError("Something keeps you from teleporting")
+						return
+					}
+
 					model.MoveCharacterToRoom(s.pc, newRoom)
 					s.PrintRoom()
 				}
 			},
 		},
 		"who": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				chars := model.GetOnlinePlayerCharacters()
 
@@ -345,7 +748,6 @@ func init() {
 			},
 		},
 		"colors": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				s.WriteLineColor(types.ColorNormal, "Normal")
 				s.WriteLineColor(types.ColorRed, "Red")
@@ -367,7 +769,6 @@ func init() {
 		},
 		"cm": cAlias("colormode"),
 		"colormode": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					message := "Current color mode is: "
@@ -397,10 +798,41 @@ func init() {
 				}
 			},
 		},
+		"wrap": {
+			usage: "/wrap [<width>|auto|off]",
+			exec: func(self *command, s *Session, arg string) {
+				switch strings.ToLower(arg) {
+				case "":
+					switch width := s.user.GetWrapWidth(); width {
+					case types.WrapAuto:
+						windowWidth, _ := s.user.GetWindowSize()
+						s.WriteLine("Wrapping to the window width (%v)", windowWidth)
+					case types.WrapOff:
+						s.WriteLine("Wrapping is off")
+					default:
+						s.WriteLine("Wrapping to %v columns", width)
+					}
+				case "auto":
+					s.user.SetWrapWidth(types.WrapAuto)
+					s.WriteLine("Wrapping to the window width")
+				case "off":
+					s.user.SetWrapWidth(types.WrapOff)
+					s.WriteLine("Wrapping is off")
+				default:
+					width, err := utils.Atoir(arg, 20, 500)
+					if err != nil {
+						s.printError(err.Error())
+						return
+					}
+					s.user.SetWrapWidth(width)
+					s.WriteLine("Wrapping to %v columns", width)
+				}
+			},
+		},
 		"dr": cAlias("destroyroom"),
 		"destroyroom": {
-			admin: true,
-			usage: "/destroyroom <direction>",
+			permission: types.PermissionBuild,
+			usage:      "/destroyroom <direction>",
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					self.Usage(s)
@@ -413,6 +845,7 @@ func init() {
 						loc := s.GetRoom().NextLocation(direction)
 						roomToDelete := model.GetRoomByLocation(loc, s.GetRoom().GetZoneId())
 						if roomToDelete != nil {
+							s.audit("delete room", roomToDelete.GetTitle(), fmt.Sprintf("%v", roomToDelete.GetLocation()))
 							model.DeleteRoom(roomToDelete)
 							s.WriteLine("Room destroyed")
 						} else {
@@ -423,7 +856,7 @@ func init() {
 			},
 		},
 		"npc": {
-			admin: true,
+			permission: types.PermissionBuild,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("NPCs", func(menu *utils.Menu) {
 					var npcs types.NPCList
@@ -448,8 +881,8 @@ func init() {
 			},
 		},
 		"items": {
-			admin: true,
-			usage: "Usage: /items",
+			permission: types.PermissionAdmin,
+			usage:      "Usage: /items",
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Items", func(menu *utils.Menu) {
 					menu.AddAction("n", "New", func() {
@@ -470,8 +903,8 @@ func init() {
 			},
 		},
 		"destroyitem": {
-			admin: true,
-			usage: "/destroyitem <item name>",
+			permission: types.PermissionBuild,
+			usage:      "/destroyitem <item name>",
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					self.Usage(s)
@@ -482,6 +915,7 @@ func init() {
 
 					for _, item := range itemsInRoom {
 						if strings.ToLower(item.GetName()) == name {
+							s.audit("delete item", item.GetName(), fmt.Sprintf("%v", item.GetId()))
 							model.DeleteItem(item.GetId())
 							s.WriteLine("Item destroyed")
 							return
@@ -493,21 +927,23 @@ func init() {
 			},
 		},
 		"roomid": {
-			admin: true,
+			permission: types.PermissionHelp,
 			exec: func(self *command, s *Session, arg string) {
 				s.WriteLine("Room ID: %v", s.GetRoom().GetId())
 			},
 		},
 		"cash": {
-			admin: true,
-			usage: "/cash give <amount>",
+			permission: types.PermissionAdmin,
+			usage:      "/cash give <amount>",
 			exec: func(self *command, s *Session, arg string) {
 				subcommand, arg := utils.Argify(arg)
 
 				if subcommand == "give" {
 					amount, err := utils.Atoir(arg, 1, math.MaxInt32)
 					if err == nil {
+						before := s.pc.GetCash()
 						s.pc.AddCash(amount)
+						s.audit("cash", s.pc.GetName(), fmt.Sprintf("%v -> %v", before, s.pc.GetCash()))
 						s.WriteLine("Received: %v monies", amount)
 					} else {
 						s.printError(err.Error())
@@ -520,7 +956,6 @@ func init() {
 		},
 		"ws": cAlias("windowsize"),
 		"windowsize": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				width, height := s.user.GetWindowSize()
 
@@ -541,13 +976,11 @@ func init() {
 		},
 		"tt": cAlias("terminaltype"),
 		"terminaltype": {
-			admin: false,
 			exec: func(self *command, s *Session, arg string) {
 				s.WriteLine("Terminal type: %s", s.user.GetTerminalType())
 			},
 		},
 		"silent": {
-			admin: false,
 			usage: "/silent [on|off]",
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
@@ -569,7 +1002,7 @@ func init() {
 		},
 		"r": cAlias("reply"),
 		"reply": {
-			admin: false,
+			chat: true,
 			exec: func(self *command, s *Session, arg string) {
 				targetChar := model.GetPlayerCharacter(s.replyId)
 
@@ -590,7 +1023,7 @@ func init() {
 			},
 		},
 		"area": {
-			admin: true,
+			permission: types.PermissionBuild,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Areas", func(menu *utils.Menu) {
 					menu.AddAction("n", "New", func() {
@@ -610,8 +1043,8 @@ func init() {
 			},
 		},
 		"link": {
-			admin: true,
-			usage: "Usage: /link <name> [single|double*] to start, /link to finish, /link remove <name> [single|double*], /link rename <old name> <new name>, /link cancel",
+			permission: types.PermissionBuild,
+			usage:      "Usage: /link <name> [single|double*] to start, /link to finish, /link remove <name> [single|double*], /link rename <old name> <new name>, /link cancel",
 			exec: func(self *command, s *Session, arg string) {
 				args := strings.Split(arg, " ")
 				StateName := "Linking"
@@ -700,8 +1133,8 @@ func init() {
 			},
 		},
 		"kill": {
-			admin: true,
-			usage: "/kill [npc name]",
+			permission: types.PermissionAdmin,
+			usage:      "/kill [npc name]",
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					self.Usage(s)
@@ -722,8 +1155,8 @@ func init() {
 			},
 		},
 		"inspect": {
-			admin: true,
-			usage: "/inspect [name]",
+			permission: types.PermissionHelp,
+			usage:      "/inspect [name]",
 			exec: func(self *command, s *Session, arg string) {
 				if arg == "" {
 					self.Usage(s)
@@ -745,7 +1178,7 @@ func init() {
 			},
 		},
 		"skills": {
-			admin: true,
+			permission: types.PermissionAdmin,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Skills", func(menu *utils.Menu) {
 					menu.AddAction("n", "New", func() {
@@ -765,7 +1198,7 @@ func init() {
 			},
 		},
 		"effects": {
-			admin: true,
+			permission: types.PermissionAdmin,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Effects", func(menu *utils.Menu) {
 					menu.AddAction("n", "New", func() {
@@ -786,7 +1219,7 @@ func init() {
 			},
 		},
 		"testmenu": {
-			admin: true,
+			permission: types.PermissionAdmin,
 			exec: func(self *command, s *Session, arg string) {
 				s.execMenu("Menu Test", func(menu *utils.Menu) {
 					for i := 0; i < 500; i++ {
@@ -798,8 +1231,8 @@ func init() {
 			},
 		},
 		"path": {
-			admin: true,
-			usage: "/path <coordinates>",
+			permission: types.PermissionHelp,
+			usage:      "/path <coordinates>",
 			exec: func(self *command, s *Session, arg string) {
 				coords, err := utils.Atois(strings.Fields(arg))
 				if err == nil {
@@ -813,7 +1246,7 @@ func init() {
 
 						room := model.GetRoomByLocation(types.Coordinate{X: x, Y: y, Z: z}, s.GetRoom().GetZoneId())
 						if room != nil {
-							path := engine.FindPath(s.GetRoom(), room)
+							path := engine.FindPath(s.pc, s.GetRoom(), room)
 							/*
 								s.WriteLine("Path:")
 								for _, room := range path {
@@ -842,15 +1275,16 @@ func init() {
 			},
 		},
 		"time": {
-			admin: false,
 			usage: "/time",
 			exec: func(self *command, s *Session, arg string) {
-				s.WriteLine("%v", model.GetWorld().GetTime())
+				world := model.GetWorld()
+				s.WriteLine("%v (%s)", world.GetTime(), strings.ToLower(string(world.GetPhase())))
+				s.WriteLine("Weather in %s: %s", s.currentZone().GetName(), s.currentZone().GetWeather())
 			},
 		},
 		"join": {
-			admin: true,
-			usage: "/join <player name>",
+			permission: types.PermissionHelp,
+			usage:      "/join <player name>",
 			exec: func(self *command, s *Session, arg string) {
 				target := model.GetCharacterByName(arg)
 				if target == nil {
@@ -862,8 +1296,8 @@ func init() {
 			},
 		},
 		"bring": {
-			admin: true,
-			usage: "/bring <player name>",
+			permission: types.PermissionHelp,
+			usage:      "/bring <player name>",
 			exec: func(self *command, s *Session, arg string) {
 				// TODO - The target receives no indication that they've been moved
 				target := model.GetCharacterByName(arg)
@@ -1007,17 +1441,9 @@ func (s *Session) specificNpcMenu(npc types.NPC) {
 		})
 
 		menu.AddAction("c", "Conversation", func() {
-			conversation := npc.GetConversation()
-
-			if conversation == "" {
-				conversation = "<empty>"
-			}
-
-			s.WriteLine("Conversation: %s", conversation)
-			newConversation := s.getRawUserInput("New conversation text: ")
-
-			if newConversation != "" {
-				npc.SetConversation(newConversation)
+			conversation, saved := s.editText("conversation", npc.GetConversation())
+			if saved {
+				npc.SetConversation(conversation)
 			}
 		})
 
@@ -1029,6 +1455,10 @@ func (s *Session) specificNpcMenu(npc types.NPC) {
 		menu.AddAction("o", fmt.Sprintf("Roaming - %s", roamingState), func() {
 			npc.SetRoaming(!npc.GetRoaming())
 		})
+
+		menu.AddAction("s", "Script", func() {
+			s.editScript("NPC script", npc)
+		})
 	})
 }
 
@@ -1079,68 +1509,628 @@ func templateMenu(s *Session, template types.Template) {
 				template.SetCapacity(capacity)
 			}
 		})
-	})
-}
 
-func toggleExitMenu(s *Session) {
-	onOrOff := func(direction types.Direction) string {
-		text := "Off"
-		if s.GetRoom().HasExit(direction) {
-			text = "On"
+		category := template.GetCategory()
+		if category == "" {
+			category = "(None)"
 		}
-		return types.Colorize(types.ColorBlue, text)
-	}
 
-	toggleExit := func(direction types.Direction, menu *utils.Menu) func() {
-		return func() {
-			room := s.GetRoom()
-
-			enable := !room.HasExit(direction)
-			room.SetExitEnabled(direction, enable)
-
-			loc := room.NextLocation(direction)
-			otherRoom := model.GetRoomByLocation(loc, room.GetZoneId())
-			if otherRoom != nil {
-				otherRoom.SetExitEnabled(direction.Opposite(), enable)
+		menu.AddAction("t", fmt.Sprintf("Category - %s", category), func() {
+			category := s.getCleanUserInput("New category: ")
+			if category != "" {
+				template.SetCategory(category)
 			}
-			menu.Exit()
+		})
+
+		light := "No"
+		if template.IsLight() {
+			light = "Yes"
 		}
-	}
 
-	s.execMenu("Edit Exits", func(menu *utils.Menu) {
-		menu.AddAction("n", "North: "+onOrOff(types.DirectionNorth), toggleExit(types.DirectionNorth, menu))
-		menu.AddAction("ne", "North East: "+onOrOff(types.DirectionNorthEast), toggleExit(types.DirectionNorthEast, menu))
-		menu.AddAction("e", "East: "+onOrOff(types.DirectionEast), toggleExit(types.DirectionEast, menu))
-		menu.AddAction("se", "South East: "+onOrOff(types.DirectionSouthEast), toggleExit(types.DirectionSouthEast, menu))
-		menu.AddAction("s", "South: "+onOrOff(types.DirectionSouth), toggleExit(types.DirectionSouth, menu))
-		menu.AddAction("sw", "South West: "+onOrOff(types.DirectionSouthWest), toggleExit(types.DirectionSouthWest, menu))
-		menu.AddAction("w", "West: "+onOrOff(types.DirectionWest), toggleExit(types.DirectionWest, menu))
-		menu.AddAction("nw", "North West: "+onOrOff(types.DirectionNorthWest), toggleExit(types.DirectionNorthWest, menu))
-		menu.AddAction("u", "Up: "+onOrOff(types.DirectionUp), toggleExit(types.DirectionUp, menu))
-		menu.AddAction("d", "Down: "+onOrOff(types.DirectionDown), toggleExit(types.DirectionDown, menu))
+		menu.AddAction("l", fmt.Sprintf("Light - %s", light), func() {
+			template.SetLight(!template.IsLight())
+		})
+
+		menu.AddAction("x", fmt.Sprintf("Extra descriptions - %v", len(template.GetExtraDescriptions())), func() {
+			s.extraDescriptionMenu(template)
+		})
+
+		menu.AddAction("s", "Script", func() {
+			s.editScript("item script", template)
+		})
 	})
 }
 
-var linkData struct {
-	source types.Id
-	mode   string
-}
+func (s *Session) storeStockMenu(store types.Store) {
+	s.execMenu("", func(menu *utils.Menu) {
+		menu.SetTitle(fmt.Sprintf("%s - Stock", store.GetName()))
 
-const LinkSingle = "Single"
-const LinkDouble = "Double"
+		menu.AddAction("a", "Add", func() {
+			template := pickTemplate(s)
+			if template != nil {
+				quantity, valid := s.getInt("Quantity to keep in stock: ", 1, 1000)
+				if !valid {
+					return
+				}
 
-func cAlias(name string) *command {
-	return &command{alias: name}
-}
+				interval, valid := s.getInt("Minutes between restocks: ", 0, math.MaxInt32)
+				if valid {
+					store.SetStock(template.GetId(), quantity, interval)
+				}
+			}
+		})
 
-func quickRoom(s *Session, command string) {
-	dir := types.StringToDirection(command)
+		menu.AddAction("r", "Restock now", func() {
+			model.RestockStore(store, true)
+			s.WriteLine("Store restocked")
+		})
 
-	if dir == types.DirectionNone {
-		return
-	}
+		for i, entry := range store.GetStock() {
+			e := entry
+			template := model.GetTemplate(entry.TemplateId)
+			menu.AddActionI(i, fmt.Sprintf("%s - %v every %v minutes", template.GetName(), entry.Quantity, entry.Interval), func() {
+				s.execMenu(template.GetName(), func(menu *utils.Menu) {
+					menu.AddAction("q", "Quantity", func() {
+						quantity, valid := s.getInt("Quantity to keep in stock: ", 1, 1000)
+						if valid {
+							store.SetStock(e.TemplateId, quantity, e.Interval)
+							menu.Exit()
+						}
+					})
 
-	room := s.GetRoom()
+					menu.AddAction("i", "Interval", func() {
+						interval, valid := s.getInt("Minutes between restocks: ", 0, math.MaxInt32)
+						if valid {
+							store.SetStock(e.TemplateId, e.Quantity, interval)
+							menu.Exit()
+						}
+					})
+
+					menu.AddAction("d", "Delete", func() {
+						store.RemoveStock(e.TemplateId)
+						menu.Exit()
+					})
+				})
+			})
+		}
+	})
+}
+
+func (s *Session) storeCategoryMenu(store types.Store) {
+	s.execMenu("", func(menu *utils.Menu) {
+		menu.SetTitle(fmt.Sprintf("%s - Accepted categories", store.GetName()))
+
+		menu.AddAction("a", "Add", func() {
+			category := s.getCleanUserInput("Category: ")
+			if category != "" {
+				store.AddCategory(category)
+			}
+		})
+
+		for i, category := range store.GetCategories() {
+			c := category
+			menu.AddActionI(i, category, func() {
+				if s.getConfirmation(fmt.Sprintf("Stop accepting %s? ", c)) {
+					store.RemoveCategory(c)
+				}
+			})
+		}
+	})
+}
+
+func pickTemplate(s *Session) types.Template {
+	var chosenTemplate types.Template
+
+	s.execMenu("Items", func(menu *utils.Menu) {
+		for i, template := range model.GetAllTemplates() {
+			t := template
+			menu.AddActionI(i, template.GetName(), func() {
+				chosenTemplate = t
+				menu.Exit()
+			})
+		}
+	})
+
+	return chosenTemplate
+}
+
+func roomActionsMenu(s *Session, room types.Room) {
+	s.execMenu("Room Actions", func(menu *utils.Menu) {
+		menu.AddAction("n", "New", func() {
+			trigger, ok := pickTrigger(s)
+			if !ok {
+				return
+			}
+
+			action := types.RoomAction{Trigger: trigger}
+			if trigger == types.TriggerCommand {
+				action.Command = s.getCleanUserInput("Command: ")
+				if action.Command == "" {
+					return
+				}
+			}
+
+			room.AddAction(action)
+			engine.WatchRoom(room)
+			roomActionMenu(s, room, len(room.GetActions())-1)
+		})
+
+		for i, action := range room.GetActions() {
+			index := i
+			menu.AddActionI(i, fmt.Sprintf("%s - %v responses", action, len(action.Responses)), func() {
+				roomActionMenu(s, room, index)
+			})
+		}
+	})
+}
+
+func roomActionMenu(s *Session, room types.Room, index int) {
+	s.execMenu("", func(menu *utils.Menu) {
+		actions := room.GetActions()
+		if index >= len(actions) {
+			menu.Exit()
+			return
+		}
+
+		action := actions[index]
+		menu.SetTitle(fmt.Sprintf("Room Action - %s", action))
+
+		update := func(responses []types.RoomResponse) {
+			action.Responses = responses
+			room.SetAction(index, action)
+		}
+
+		menu.AddAction("t", fmt.Sprintf("Trigger - %s", action.Trigger), func() {
+			trigger, ok := pickTrigger(s)
+			if ok {
+				action.Trigger = trigger
+				if trigger == types.TriggerCommand && action.Command == "" {
+					action.Command = s.getCleanUserInput("Command: ")
+				}
+				room.SetAction(index, action)
+				engine.WatchRoom(room)
+			}
+		})
+
+		if action.Trigger == types.TriggerCommand {
+			menu.AddAction("c", fmt.Sprintf("Command - %s", action.Command), func() {
+				command := s.getCleanUserInput("Command: ")
+				if command != "" {
+					action.Command = command
+					room.SetAction(index, action)
+				}
+			})
+		}
+
+		menu.AddAction("a", "Add response", func() {
+			response, ok := editRoomResponse(s, room)
+			if ok {
+				update(append(append([]types.RoomResponse{}, action.Responses...), response))
+			}
+		})
+
+		for i, response := range action.Responses {
+			r := i
+			menu.AddActionI(i, describeRoomResponse(response), func() {
+				s.execMenu("", func(menu *utils.Menu) {
+					menu.AddAction("e", "Edit", func() {
+						response, ok := editRoomResponse(s, room)
+						if ok {
+							responses := append([]types.RoomResponse{}, action.Responses...)
+							responses[r] = response
+							update(responses)
+							menu.Exit()
+						}
+					})
+
+					menu.AddAction("d", "Delete", func() {
+						responses := append([]types.RoomResponse{}, action.Responses[:r]...)
+						update(append(responses, action.Responses[r+1:]...))
+						menu.Exit()
+					})
+				})
+			})
+		}
+
+		menu.AddAction("d", "Delete", func() {
+			if s.getConfirmation("Delete this action? ") {
+				room.RemoveAction(index)
+				menu.Exit()
+			}
+		})
+	})
+}
+
+func pickTrigger(s *Session) (types.ActionTrigger, bool) {
+	var trigger types.ActionTrigger
+	chosen := false
+
+	s.execMenu("Trigger", func(menu *utils.Menu) {
+		for i, t := range types.ActionTriggers {
+			t := t
+			menu.AddActionI(i, string(t), func() {
+				trigger = t
+				chosen = true
+				menu.Exit()
+			})
+		}
+	})
+
+	return trigger, chosen
+}
+
+func describeRoomResponse(response types.RoomResponse) string {
+	switch response.Kind {
+	case types.ResponseMessage:
+		return fmt.Sprintf("Message - %s", response.Message)
+	case types.ResponseLock, types.ResponseUnlock, types.ResponseToggle, types.ResponseOpen, types.ResponseClose:
+		return fmt.Sprintf("%s - %s", response.Kind, response.Direction.ToString())
+	case types.ResponseTeleport:
+		if room := model.GetRoom(response.Target); room != nil {
+			return fmt.Sprintf("Teleport - %s %v", room.GetTitle(), room.GetLocation())
+		}
+	case types.ResponseItem:
+		if template := model.GetTemplate(response.Target); template != nil {
+			return fmt.Sprintf("Item - %s", template.GetName())
+		}
+	case types.ResponseEffect:
+		if effect := model.GetEffect(response.Target); effect != nil {
+			return fmt.Sprintf("Effect - %s", effect.GetName())
+		}
+	}
+	return fmt.Sprintf("%s - (missing)", response.Kind)
+}
+
+// Asks for the kind of response and whatever that kind needs
+func editRoomResponse(s *Session, room types.Room) (types.RoomResponse, bool) {
+	var response types.RoomResponse
+
+	s.execMenu("Response", func(menu *utils.Menu) {
+		for i, kind := range types.ResponseKinds {
+			k := kind
+			menu.AddActionI(i, string(k), func() {
+				response.Kind = k
+				menu.Exit()
+			})
+		}
+	})
+
+	switch response.Kind {
+	case types.ResponseMessage:
+		response.Message = s.getRawUserInput("Message to the character: ")
+		response.RoomMessage = s.getRawUserInput("Message to the room ($n is the character's name): ")
+		return response, response.Message != "" || response.RoomMessage != ""
+	case types.ResponseLock, types.ResponseUnlock, types.ResponseToggle, types.ResponseOpen, types.ResponseClose:
+		response.Direction = types.StringToDirection(s.getCleanUserInput("Direction: "))
+		if _, found := room.GetDoor(response.Direction); !found {
+			s.printError("There's no door in that direction")
+			return response, false
+		}
+		return response, true
+	case types.ResponseTeleport:
+		coords, err := utils.Atois(strings.Fields(s.getCleanUserInput("Coordinates (x y z): ")))
+		if err != nil || len(coords) != 3 {
+			s.printError("Invalid coordinates")
+			return response, false
+		}
+
+		destination := model.GetRoomByLocation(types.Coordinate{X: coords[0], Y: coords[1], Z: coords[2]}, room.GetZoneId())
+		if destination == nil {
+			s.printError("No room found at the given coordinates")
+			return response, false
+		}
+		response.Target = destination.GetId()
+		return response, true
+	case types.ResponseItem:
+		if template := pickTemplate(s); template != nil {
+			response.Target = template.GetId()
+			return response, true
+		}
+	case types.ResponseEffect:
+		if effect := pickEffect(s); effect != nil {
+			response.Target = effect.GetId()
+			return response, true
+		}
+	}
+
+	return response, false
+}
+
+func doorsMenu(s *Session, room types.Room) {
+	s.execMenu("Doors", func(menu *utils.Menu) {
+		for i, dir := range room.GetExits() {
+			d := dir
+			text := d.ToString()
+			if door, found := room.GetDoor(d); found {
+				text = fmt.Sprintf("%s - %s", text, door.Name)
+			}
+
+			menu.AddActionI(i, text, func() {
+				if _, found := room.GetDoor(d); !found {
+					name := s.getRawUserInput("Door name: ")
+					if name == "" {
+						return
+					}
+					model.SetDoor(room, d, types.Door{Name: name})
+				}
+				doorMenu(s, room, d)
+			})
+		}
+	})
+}
+
+func doorMenu(s *Session, room types.Room, dir types.Direction) {
+	onOrOff := func(on bool) string {
+		if on {
+			return "Yes"
+		}
+		return "No"
+	}
+
+	s.execMenu("", func(menu *utils.Menu) {
+		door, found := room.GetDoor(dir)
+		if !found {
+			menu.Exit()
+			return
+		}
+
+		menu.SetTitle(fmt.Sprintf("Door - %s", dir.ToString()))
+
+		menu.AddAction("n", fmt.Sprintf("Name - %s", door.Name), func() {
+			name := s.getRawUserInput("Door name: ")
+			if name != "" {
+				door.Name = name
+				model.SetDoor(room, dir, door)
+			}
+		})
+
+		key := "(None)"
+		if door.KeyId != nil {
+			if template := model.GetTemplate(door.KeyId); template != nil {
+				key = template.GetName()
+			}
+		}
+
+		menu.AddAction("k", fmt.Sprintf("Key - %s", key), func() {
+			s.execMenu("Key", func(menu *utils.Menu) {
+				menu.AddAction("n", "None", func() {
+					door.KeyId = nil
+					model.SetDoor(room, dir, door)
+					menu.Exit()
+				})
+
+				for i, template := range model.GetAllTemplates() {
+					t := template
+					menu.AddActionI(i, t.GetName(), func() {
+						door.KeyId = t.GetId()
+						model.SetDoor(room, dir, door)
+						menu.Exit()
+					})
+				}
+			})
+		})
+
+		difficulty := "Can't be picked"
+		if door.Difficulty > 0 && door.Difficulty < 100 {
+			difficulty = fmt.Sprintf("%v%%", door.Difficulty)
+		}
+
+		menu.AddAction("p", fmt.Sprintf("Pick difficulty - %s", difficulty), func() {
+			value, valid := s.getInt("Chance of failing to pick the lock (0 for unpickable): ", 0, 100)
+			if valid {
+				door.Difficulty = value
+				model.SetDoor(room, dir, door)
+			}
+		})
+
+		menu.AddAction("c", fmt.Sprintf("Closed - %s", onOrOff(room.IsClosed(dir))), func() {
+			model.SetExitClosed(room, dir, !room.IsClosed(dir))
+		})
+
+		menu.AddAction("l", fmt.Sprintf("Locked - %s", onOrOff(room.IsLocked(dir))), func() {
+			model.SetExitLocked(room, dir, !room.IsLocked(dir))
+		})
+
+		menu.AddAction("d", "Remove door", func() {
+			model.RemoveDoor(room, dir)
+			menu.Exit()
+		})
+	})
+}
+
+func roomFlagsMenu(s *Session, room types.Room) {
+	s.execMenu("Room Flags", func(menu *utils.Menu) {
+		for i, flag := range types.RoomFlags {
+			f := flag
+			state := "Off"
+			if room.HasFlag(f) {
+				state = "On"
+			}
+
+			menu.AddActionI(i, fmt.Sprintf("%s - %s", f, state), func() {
+				room.SetFlag(f, !room.HasFlag(f))
+			})
+		}
+	})
+}
+
+func passagesMenu(s *Session, room types.Room) {
+	s.execMenu("Passages", func(menu *utils.Menu) {
+		for i, dir := range room.GetExits() {
+			d := dir
+
+			var notes []string
+			if room.IsHidden(d) {
+				notes = append(notes, "hidden")
+			}
+			if model.IsOneWay(room, d) {
+				notes = append(notes, "one-way")
+			}
+			if !room.GetRestriction(d).IsEmpty() {
+				notes = append(notes, "restricted")
+			}
+
+			text := d.ToString()
+			if len(notes) > 0 {
+				text = fmt.Sprintf("%s - %s", text, strings.Join(notes, ", "))
+			}
+
+			menu.AddActionI(i, text, func() {
+				passageMenu(s, room, d)
+			})
+		}
+	})
+}
+
+func passageMenu(s *Session, room types.Room, dir types.Direction) {
+	onOrOff := func(on bool) string {
+		if on {
+			return "Yes"
+		}
+		return "No"
+	}
+
+	s.execMenu("", func(menu *utils.Menu) {
+		if !room.HasExit(dir) {
+			menu.Exit()
+			return
+		}
+
+		menu.SetTitle(fmt.Sprintf("Passage - %s", dir.ToString()))
+		restriction := room.GetRestriction(dir)
+
+		menu.AddAction("h", fmt.Sprintf("Hidden - %s", onOrOff(room.IsHidden(dir))), func() {
+			room.SetHidden(dir, !room.IsHidden(dir))
+		})
+
+		if model.GetRoomByLocation(room.NextLocation(dir), room.GetZoneId()) != nil {
+			oneWay := model.IsOneWay(room, dir)
+			menu.AddAction("w", fmt.Sprintf("One-way - %s", onOrOff(oneWay)), func() {
+				model.SetOneWay(room, dir, !oneWay)
+			})
+		}
+
+		menu.AddAction("x", fmt.Sprintf("Minimum experience - %v", restriction.MinExperience), func() {
+			value, valid := s.getInt("Minimum experience: ", 0, math.MaxInt32)
+			if valid {
+				restriction.MinExperience = value
+				room.SetRestriction(dir, restriction)
+			}
+		})
+
+		skill := "(None)"
+		if restriction.SkillId != nil {
+			if sk := model.GetSkill(restriction.SkillId); sk != nil {
+				skill = sk.GetName()
+			}
+		}
+
+		menu.AddAction("k", fmt.Sprintf("Required skill - %s", skill), func() {
+			s.execMenu("Required Skill", func(menu *utils.Menu) {
+				menu.AddAction("n", "None", func() {
+					restriction.SkillId = nil
+					room.SetRestriction(dir, restriction)
+					menu.Exit()
+				})
+
+				for i, skill := range model.GetAllSkills() {
+					sk := skill
+					menu.AddActionI(i, sk.GetName(), func() {
+						restriction.SkillId = sk.GetId()
+						room.SetRestriction(dir, restriction)
+						menu.Exit()
+					})
+				}
+			})
+		})
+
+		item := "(None)"
+		if restriction.TemplateId != nil {
+			if template := model.GetTemplate(restriction.TemplateId); template != nil {
+				item = template.GetName()
+			}
+		}
+
+		menu.AddAction("i", fmt.Sprintf("Required item - %s", item), func() {
+			s.execMenu("Required Item", func(menu *utils.Menu) {
+				menu.AddAction("n", "None", func() {
+					restriction.TemplateId = nil
+					room.SetRestriction(dir, restriction)
+					menu.Exit()
+				})
+
+				for i, template := range model.GetAllTemplates() {
+					t := template
+					menu.AddActionI(i, t.GetName(), func() {
+						restriction.TemplateId = t.GetId()
+						room.SetRestriction(dir, restriction)
+						menu.Exit()
+					})
+				}
+			})
+		})
+	})
+}
+
+func toggleExitMenu(s *Session) {
+	onOrOff := func(direction types.Direction) string {
+		text := "Off"
+		if s.GetRoom().HasExit(direction) {
+			text = "On"
+		}
+		return types.Colorize(types.ColorBlue, text)
+	}
+
+	toggleExit := func(direction types.Direction, menu *utils.Menu) func() {
+		return func() {
+			room := s.GetRoom()
+
+			enable := !room.HasExit(direction)
+			room.SetExitEnabled(direction, enable)
+
+			loc := room.NextLocation(direction)
+			otherRoom := model.GetRoomByLocation(loc, room.GetZoneId())
+			if otherRoom != nil {
+				otherRoom.SetExitEnabled(direction.Opposite(), enable)
+			}
+			menu.Exit()
+		}
+	}
+
+	s.execMenu("Edit Exits", func(menu *utils.Menu) {
+		menu.AddAction("n", "North: "+onOrOff(types.DirectionNorth), toggleExit(types.DirectionNorth, menu))
+		menu.AddAction("ne", "North East: "+onOrOff(types.DirectionNorthEast), toggleExit(types.DirectionNorthEast, menu))
+		menu.AddAction("e", "East: "+onOrOff(types.DirectionEast), toggleExit(types.DirectionEast, menu))
+		menu.AddAction("se", "South East: "+onOrOff(types.DirectionSouthEast), toggleExit(types.DirectionSouthEast, menu))
+		menu.AddAction("s", "South: "+onOrOff(types.DirectionSouth), toggleExit(types.DirectionSouth, menu))
+		menu.AddAction("sw", "South West: "+onOrOff(types.DirectionSouthWest), toggleExit(types.DirectionSouthWest, menu))
+		menu.AddAction("w", "West: "+onOrOff(types.DirectionWest), toggleExit(types.DirectionWest, menu))
+		menu.AddAction("nw", "North West: "+onOrOff(types.DirectionNorthWest), toggleExit(types.DirectionNorthWest, menu))
+		menu.AddAction("u", "Up: "+onOrOff(types.DirectionUp), toggleExit(types.DirectionUp, menu))
+		menu.AddAction("d", "Down: "+onOrOff(types.DirectionDown), toggleExit(types.DirectionDown, menu))
+	})
+}
+
+var linkData struct {
+	source types.Id
+	mode   string
+}
+
+const LinkSingle = "Single"
+const LinkDouble = "Double"
+
+func cAlias(name string) *command {
+	return &command{alias: name}
+}
+
+func quickRoom(s *Session, command string) {
+	dir := types.StringToDirection(command)
+
+	if dir == types.DirectionNone {
+		return
+	}
+
+	room := s.GetRoom()
 
 	room.SetExitEnabled(dir, true)
 	s.handleAction(command, "")
@@ -1162,6 +2152,258 @@ func whisper(self *command, s *Session, arg string) {
 	}
 }
 
+// Returns a command which places a sanction of the given kind on a player, or
+// on an address for IP bans
+func sanctionCommand(kind types.SanctionKind) *command {
+	target := "player"
+	if kind == types.SanctionIpBan {
+		target = "address|CIDR"
+	}
+
+	return &command{
+		permission: types.PermissionModerate,
+		usage: fmt.Sprintf("/%s <%s> <minutes, 0 for permanent> [reason]",
+			strings.ToLower(strings.Replace(string(kind), " ", "", -1)), target),
+		exec: func(self *command, s *Session, arg string) {
+			name, rest := utils.Argify(arg)
+			minutesArg, reason := utils.Argify(rest)
+
+			minutes, err := utils.Atoir(minutesArg, 0, math.MaxInt32)
+			if name == "" || err != nil {
+				self.Usage(s)
+				return
+			}
+
+			duration := time.Duration(minutes) * time.Minute
+
+			if kind == types.SanctionIpBan {
+				if !utils.ValidAddressPattern(name) {
+					s.printError("'%s' isn't an IP address or CIDR range", name)
+					return
+				}
+
+				model.CreateSanction(kind, nil, name, reason, s.pc.GetName(), duration)
+				s.WriteLine("Banned %s", name)
+				return
+			}
+
+			pc := model.GetPlayerCharacterByName(name)
+			if pc == nil {
+				s.printError("Player '%s' not found", name)
+				return
+			}
+
+			user := model.GetUser(pc.GetUserId())
+			if user == s.user {
+				s.printError("You can't do that to yourself")
+				return
+			}
+
+			if !s.outranks(user) {
+				s.printError("You can't do that to %s", user.GetName())
+				return
+			}
+
+			if kind == types.SanctionJail && model.GetWorld().GetJailRoomId() == nil {
+				s.printError("No jail room has been set, use /jailroom")
+				return
+			}
+
+			sanction := model.CreateSanction(kind, user.GetId(), "", reason, s.pc.GetName(), duration)
+
+			for _, char := range model.GetUserCharacters(user.GetId()) {
+				if !char.IsOnline() {
+					continue
+				}
+
+				events.Broadcast(events.SanctionEvent{Sanction: sanction, Character: char})
+
+				switch kind {
+				case types.SanctionJail:
+					model.JailCharacter(char)
+				case types.SanctionBan:
+					kick(user, reason)
+				}
+			}
+
+			s.WriteLine("%s: %s", sanction.GetKind(), user.GetName())
+		},
+	}
+}
+
+// outranks returns true if the session's user holds a higher role than the
+// given one, which moderation commands require of their targets
+func (self *Session) outranks(user types.User) bool {
+	return user != nil && self.user.GetRole().Outranks(user.GetRole())
+}
+
+// Disconnects the user, letting them know why
+func kick(user types.User, reason string) {
+	if user == nil || !user.IsOnline() || user.GetConnection() == nil {
+		return
+	}
+
+	message := "You have been disconnected by an admin"
+	if reason != "" {
+		message = message + ": " + reason
+	}
+
+	user.WriteLine("%s", types.Colorize(types.ColorRed, message))
+	user.GetConnection().Close()
+}
+
+var channelColors = map[string]types.Color{
+	"red":     types.ColorRed,
+	"green":   types.ColorGreen,
+	"yellow":  types.ColorYellow,
+	"blue":    types.ColorBlue,
+	"magenta": types.ColorMagenta,
+	"cyan":    types.ColorCyan,
+	"white":   types.ColorWhite,
+}
+
+func channelCommand(self *command, s *Session, arg string) {
+	subcommand, arg := utils.Argify(arg)
+
+	if subcommand == "" {
+		for _, channel := range model.GetChannels() {
+			if channel.IsAdminOnly() && !s.user.IsAdmin() {
+				continue
+			}
+
+			state := ""
+			if channel.IsMuted(s.pc.GetId()) {
+				state = " (muted)"
+			} else if channel.IsMember(s.pc.GetId()) {
+				state = " (joined)"
+			}
+
+			s.WriteLine("%s%s", types.Colorize(channel.GetColor(), channel.GetName()), state)
+		}
+		return
+	}
+
+	name, rest := utils.Argify(arg)
+	if name == "" {
+		self.Usage(s)
+		return
+	}
+
+	if subcommand == "create" {
+		if findCommand(name) != nil {
+			s.printError("That name is already taken by a command")
+			return
+		}
+
+		channel, err := model.CreateChannel(name, s.user.GetId())
+		if err != nil {
+			s.printError(err.Error())
+			return
+		}
+
+		channel.Join(s.pc.GetId())
+		s.WriteLine("Created channel %s", channel.GetName())
+		return
+	}
+
+	channel := model.GetChannelByName(name)
+	if channel == nil || (channel.IsAdminOnly() && !s.user.IsAdmin()) {
+		s.printError("Channel '%s' not found", name)
+		return
+	}
+
+	moderator := s.user.HasPermission(types.PermissionModerate) || channel.GetOwnerId() == s.user.GetId()
+
+	switch subcommand {
+	case "join":
+		if channel.IsBanned(s.pc.GetId()) {
+			s.printError("You have been kicked from %s", channel.GetName())
+			return
+		}
+
+		channel.Join(s.pc.GetId())
+		s.WriteLine("Joined %s", channel.GetName())
+	case "leave":
+		channel.Leave(s.pc.GetId())
+		s.WriteLine("Left %s", channel.GetName())
+	case "mute":
+		if !channel.IsMember(s.pc.GetId()) {
+			s.printError("You aren't on that channel")
+			return
+		}
+
+		muted := !channel.IsMuted(s.pc.GetId())
+		channel.SetMuted(s.pc.GetId(), muted)
+
+		if muted {
+			s.WriteLine("Muted %s", channel.GetName())
+		} else {
+			s.WriteLine("Unmuted %s", channel.GetName())
+		}
+	case "history":
+		for _, message := range channel.GetHistory() {
+			s.WriteLine("%s %s %s",
+				types.Colorize(types.ColorBlue, message.Time.Format("15:04")),
+				types.Colorize(channel.GetColor(), message.From+":"),
+				message.Message)
+		}
+	case "who":
+		var names []string
+		for _, id := range channel.GetMembers() {
+			pc := model.GetPlayerCharacter(id)
+			if pc != nil && pc.IsOnline() {
+				names = append(names, pc.GetName())
+			}
+		}
+		sort.Strings(names)
+		s.WriteLine("On %s: %s", channel.GetName(), strings.Join(names, ", "))
+	case "color":
+		color, found := channelColors[strings.ToLower(rest)]
+		if !moderator {
+			s.printError("You don't have permission to do that")
+		} else if !found {
+			s.printError("Unknown color, choose one of: red, green, yellow, blue, magenta, cyan, white")
+		} else {
+			channel.SetColor(color)
+		}
+	case "delete":
+		if !moderator || channel.GetOwnerId() == nil {
+			s.printError("You don't have permission to do that")
+		} else {
+			model.DeleteChannel(channel.GetId())
+			s.WriteLine("Deleted %s", channel.GetName())
+		}
+	case "silence", "unsilence", "kick", "unkick":
+		if !moderator {
+			s.printError("You don't have permission to do that")
+			return
+		}
+
+		target := model.GetPlayerCharacterByName(rest)
+		if target == nil {
+			s.printError("Player '%s' not found", rest)
+			return
+		}
+
+		switch subcommand {
+		case "silence":
+			channel.SetSilenced(target.GetId(), true)
+			s.WriteLine("%s has been silenced on %s", target.GetName(), channel.GetName())
+		case "unsilence":
+			channel.SetSilenced(target.GetId(), false)
+			s.WriteLine("%s may speak on %s again", target.GetName(), channel.GetName())
+		case "kick":
+			channel.SetBanned(target.GetId(), true)
+			s.WriteLine("%s has been removed from %s", target.GetName(), channel.GetName())
+		case "unkick":
+			channel.SetBanned(target.GetId(), false)
+			s.WriteLine("%s may join %s again", target.GetName(), channel.GetName())
+		}
+	default:
+		self.Usage(s)
+	}
+}
+
 func (s *Session) specificAreaMenu(area types.Area) {
 	s.execMenu(area.GetName(), func(menu *utils.Menu) {
 		menu.AddAction("r", "Rename", func() {
@@ -1196,7 +2438,8 @@ func (s *Session) spawnerMenu(area types.Area) {
 		menu.AddAction("n", "New", func() {
 			name := s.getName("Name of spawned NPC: ", types.SpawnerType)
 			if name != "" {
-				model.CreateSpawner(name, area.GetId())
+				spawner := model.CreateSpawner(name, area.GetId())
+				engine.StartSpawner(spawner)
 			}
 		})
 	})
@@ -1224,6 +2467,250 @@ func (s *Session) specificSpawnerMenu(spawner types.Spawner) {
 				spawner.SetHealth(health)
 			}
 		})
+
+		menu.AddAction("s", fmt.Sprintf("Strength - %v", spawner.GetStrength()), func() {
+			strength, valid := s.getInt("New strength: ", 0, 1000)
+			if valid {
+				spawner.SetStrength(strength)
+			}
+		})
+
+		menu.AddAction("m", fmt.Sprintf("Cash - %v", spawner.GetCash()), func() {
+			cash, valid := s.getInt("Cash carried by each NPC: ", 0, math.MaxInt32)
+			if valid {
+				spawner.SetCash(cash)
+			}
+		})
+
+		roamingState := "Off"
+		if spawner.GetRoaming() {
+			roamingState = "On"
+		}
+
+		menu.AddAction("o", fmt.Sprintf("Roaming - %s", roamingState), func() {
+			spawner.SetRoaming(!spawner.GetRoaming())
+		})
+
+		menu.AddAction("v", "Conversation", func() {
+			conversation, saved := s.editText("conversation", spawner.GetConversation())
+			if saved {
+				spawner.SetConversation(conversation)
+			}
+		})
+
+		menu.AddAction("i", "Script", func() {
+			s.editScript("NPC script", spawner)
+		})
+
+		menu.AddAction("k", "Skills", func() {
+			s.spawnerSkillMenu(spawner)
+		})
+
+		menu.AddAction("e", "Equipment", func() {
+			s.spawnerEquipmentMenu(spawner)
+		})
+
+		menu.AddAction("l", "Loot", func() {
+			s.lootMenu(spawner)
+		})
+
+		menu.AddAction("y", fmt.Sprintf("Respawn delay - %vs", spawner.GetRespawnDelay()), func() {
+			delay, valid := s.getInt("Seconds to wait before replacing a dead NPC: ", 0, math.MaxInt32)
+			if valid {
+				spawner.SetRespawnDelay(delay)
+			}
+		})
+
+		rooms := "Whole area"
+		if len(spawner.GetRoomIds()) > 0 {
+			rooms = fmt.Sprintf("%v specific", len(spawner.GetRoomIds()))
+		}
+
+		menu.AddAction("g", fmt.Sprintf("Rooms - %s", rooms), func() {
+			s.spawnerRoomMenu(spawner)
+		})
+
+		maxPerRoom := "Unlimited"
+		if spawner.GetMaxPerRoom() > 0 {
+			maxPerRoom = fmt.Sprintf("%v", spawner.GetMaxPerRoom())
+		}
+
+		menu.AddAction("p", fmt.Sprintf("Max per room - %s", maxPerRoom), func() {
+			max, valid := s.getInt("Maximum NPCs per room (0 for unlimited): ", 0, 1000)
+			if valid {
+				spawner.SetMaxPerRoom(max)
+			}
+		})
+
+		menu.AddAction("t", fmt.Sprintf("Schedule - %s", spawner.GetSchedule()), func() {
+			switch spawner.GetSchedule() {
+			case types.SpawnAlways:
+				spawner.SetSchedule(types.SpawnDay)
+			case types.SpawnDay:
+				spawner.SetSchedule(types.SpawnNight)
+			default:
+				spawner.SetSchedule(types.SpawnAlways)
+			}
+		})
+
+		pausedState := "Running"
+		if spawner.IsPaused() {
+			pausedState = "Paused"
+		}
+
+		menu.AddAction("u", fmt.Sprintf("State - %s", pausedState), func() {
+			spawner.SetPaused(!spawner.IsPaused())
+		})
+
+		menu.AddAction("x", "Delete", func() {
+			if s.getConfirmation(fmt.Sprintf("Delete %s? ", spawner.GetName())) {
+				model.DeleteSpawner(spawner.GetId())
+				menu.Exit()
+			}
+		})
+	})
+}
+
+func (s *Session) spawnerRoomMenu(spawner types.Spawner) {
+	s.execMenu("", func(menu *utils.Menu) {
+		menu.SetTitle(fmt.Sprintf("Rooms - %s", spawner.GetName()))
+
+		for i, room := range model.GetAreaRooms(spawner.GetAreaId()) {
+			r := room
+			state := "Off"
+			if spawner.HasRoom(r.GetId()) {
+				state = "On"
+			}
+
+			menu.AddActionI(i, fmt.Sprintf("%s %v - %s", r.GetTitle(), r.GetLocation(), state), func() {
+				if spawner.HasRoom(r.GetId()) {
+					spawner.RemoveRoom(r.GetId())
+				} else {
+					spawner.AddRoom(r.GetId())
+				}
+			})
+		}
+	})
+}
+
+func (s *Session) spawnerSkillMenu(spawner types.Spawner) {
+	s.execMenu("", func(menu *utils.Menu) {
+		menu.SetTitle(fmt.Sprintf("Skills - %s", spawner.GetName()))
+
+		for i, skill := range model.GetAllSkills() {
+			sk := skill
+			state := "Off"
+			if spawner.HasSkill(sk.GetId()) {
+				state = "On"
+			}
+
+			menu.AddActionI(i, fmt.Sprintf("%s - %s", sk.GetName(), state), func() {
+				if spawner.HasSkill(sk.GetId()) {
+					spawner.RemoveSkill(sk.GetId())
+				} else {
+					spawner.AddSkill(sk.GetId())
+				}
+			})
+		}
+	})
+}
+
+func (s *Session) spawnerEquipmentMenu(spawner types.Spawner) {
+	s.execMenu("", func(menu *utils.Menu) {
+		menu.SetTitle(fmt.Sprintf("Equipment - %s", spawner.GetName()))
+
+		menu.AddAction("a", "Add", func() {
+			template := pickTemplate(s)
+			if template != nil {
+				spawner.AddEquipment(template.GetId())
+			}
+		})
+
+		for i, templateId := range spawner.GetEquipment() {
+			template := model.GetTemplate(templateId)
+			menu.AddActionI(i, template.GetName(), func() {
+				if s.getConfirmation(fmt.Sprintf("Remove %s? ", template.GetName())) {
+					spawner.RemoveEquipment(template.GetId())
+				}
+			})
+		}
+	})
+}
+
+func (s *Session) lootMenu(spawner types.Spawner) {
+	s.execMenu("", func(menu *utils.Menu) {
+		loot := spawner.GetLoot()
+		menu.SetTitle(fmt.Sprintf("Loot - %s", spawner.GetName()))
+
+		menu.AddAction("a", "Add drop", func() {
+			template := pickTemplate(s)
+			if template == nil {
+				return
+			}
+
+			drop := types.LootDrop{TemplateId: template.GetId()}
+			drop.Guaranteed = s.getConfirmation("Always drop this item? ")
+
+			if !drop.Guaranteed {
+				weight, valid := s.getInt("Weight: ", 1, 1000)
+				if !valid {
+					return
+				}
+				drop.Weight = weight
+			}
+
+			loot.Drops = append(loot.Drops, drop)
+			spawner.SetLoot(loot)
+		})
+
+		menu.AddAction("r", fmt.Sprintf("Rolls - %v", loot.Rolls), func() {
+			rolls, valid := s.getInt("Number of weighted drops to roll: ", 0, 100)
+			if valid {
+				loot.Rolls = rolls
+				spawner.SetLoot(loot)
+			}
+		})
+
+		menu.AddAction("c", fmt.Sprintf("Cash - %v to %v", loot.MinCash, loot.MaxCash), func() {
+			min, valid := s.getInt("Minimum cash: ", 0, math.MaxInt32)
+			if !valid {
+				return
+			}
+
+			max, valid := s.getInt("Maximum cash: ", min, math.MaxInt32)
+			if valid {
+				loot.MinCash = min
+				loot.MaxCash = max
+				spawner.SetLoot(loot)
+			}
+		})
+
+		when := "Spawn"
+		if loot.OnDeath {
+			when = "Death"
+		}
+
+		menu.AddAction("w", fmt.Sprintf("Roll on - %s", when), func() {
+			loot.OnDeath = !loot.OnDeath
+			spawner.SetLoot(loot)
+		})
+
+		for i, drop := range loot.Drops {
+			index := i
+			template := model.GetTemplate(drop.TemplateId)
+
+			chance := fmt.Sprintf("weight %v", drop.Weight)
+			if drop.Guaranteed {
+				chance = "always"
+			}
+
+			menu.AddActionI(i, fmt.Sprintf("%s (%s)", template.GetName(), chance), func() {
+				if s.getConfirmation(fmt.Sprintf("Remove %s from the loot table? ", template.GetName())) {
+					loot.Drops = append(loot.Drops[:index], loot.Drops[index+1:]...)
+					spawner.SetLoot(loot)
+				}
+			})
+		}
 	})
 }
 
@@ -1,6 +1,7 @@
 package session
 
 import (
+	"github.com/Cristofori/kmud/model"
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
 )
@@ -11,6 +12,9 @@ type mapBuilder struct {
 	depth    int
 	data     [][][]mapTile
 	userRoom types.Room
+
+	// Only the exits this character can see are drawn
+	character types.Character
 }
 
 type mapTile struct {
@@ -61,16 +65,24 @@ func (self *mapBuilder) setUserRoom(room types.Room) {
 	self.userRoom = room
 }
 
+func (self *mapBuilder) setCharacter(character types.Character) {
+	self.character = character
+}
+
 func (self *mapBuilder) addRoom(room types.Room, x int, y int, z int) {
 	x = x * 2
 	y = y * 2
 
+	hasExit := func(dir types.Direction) bool {
+		return model.CanSeeExit(self.character, room, dir)
+	}
+
 	addIfExists := func(dir types.Direction, x int, y int) {
 		if x < 0 || y < 0 {
 			return
 		}
 
-		if room.HasExit(dir) {
+		if hasExit(dir) {
 			self.data[z][y][x].addExit(dir)
 		}
 	}
@@ -80,11 +92,11 @@ func (self *mapBuilder) addRoom(room types.Room, x int, y int, z int) {
 		self.data[z][y][x].color = types.ColorRed
 	} else {
 		self.data[z][y][x].color = types.ColorMagenta
-		if room.HasExit(types.DirectionUp) && room.HasExit(types.DirectionDown) {
+		if hasExit(types.DirectionUp) && hasExit(types.DirectionDown) {
 			self.data[z][y][x].char = '+'
-		} else if room.HasExit(types.DirectionUp) {
+		} else if hasExit(types.DirectionUp) {
 			self.data[z][y][x].char = '^'
-		} else if room.HasExit(types.DirectionDown) {
+		} else if hasExit(types.DirectionDown) {
 			self.data[z][y][x].char = 'v'
 		} else {
 			char := '#'
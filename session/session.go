@@ -6,9 +6,14 @@ import (
 	"sort"
 	"strconv"
 
+	"github.com/Cristofori/kmud/audit"
 	"github.com/Cristofori/kmud/combat"
+	"github.com/Cristofori/kmud/engine"
 	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/group"
 	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/script"
+	"github.com/Cristofori/kmud/trade"
 	"github.com/Cristofori/kmud/types"
 	"github.com/Cristofori/kmud/utils"
 	// "log"
@@ -75,10 +80,21 @@ const (
 func (self *Session) Exec() {
 	defer events.Unregister(self.pc)
 	defer model.Logout(self.pc)
+	defer trade.Cancel(self.pc)
+	defer group.Leave(self.pc)
 
 	self.WriteLine("Welcome, " + self.pc.GetName())
 	self.PrintRoom()
 
+	if model.GetSanction(self.user.GetId(), types.SanctionJail) != nil {
+		model.JailCharacter(self.pc)
+		self.printError("You are in jail")
+	}
+
+	if unread := model.CountUnreadMail(self.user.GetId()); unread > 0 {
+		self.WriteLineColor(types.ColorYellow, "You have %v unread message(s), type 'mail' to read them", unread)
+	}
+
 	// Main routine in charge of actually reading input from the connection object,
 	// also has built in throttling to limit how fast we are allowed to process
 	// commands from the user.
@@ -147,7 +163,21 @@ func (self *Session) printLine(line string, a ...interface{}) {
 }
 
 func (self *Session) Write(text string) {
-	self.user.Write(text)
+	self.user.Write(self.wrap(text))
+}
+
+// Wraps the text to the user's chosen width, or the width of their window
+func (self *Session) wrap(text string) string {
+	width := self.user.GetWrapWidth()
+
+	switch width {
+	case types.WrapOff:
+		return text
+	case types.WrapAuto:
+		width, _ = self.user.GetWindowSize()
+	}
+
+	return utils.WrapLines(text, width)
 }
 
 func (self *Session) printError(err string, a ...interface{}) {
@@ -209,6 +239,14 @@ func (self *Session) getUserInputP(inputMode userInputMode, prompter utils.Promp
 				self.Write(prompter.GetPrompt())
 			}
 
+			// Only walking is followed, since teleports, recalls and jailings
+			// can go places the follower isn't allowed to
+			if e, ok := event.(events.LeaveEvent); ok && e.Direction != types.DirectionNone &&
+				e.RoomId == self.pc.GetRoomId() && group.IsFollowing(self.pc, e.Character) {
+				self.follow(e.Character, e.Direction)
+				self.Write(prompter.GetPrompt())
+			}
+
 		case quitMessage := <-self.panicChannel:
 			panic(quitMessage)
 		}
@@ -278,7 +316,7 @@ func (self *Session) handleAction(action string, arg string) {
 		direction := types.StringToDirection(action)
 
 		if direction != types.DirectionNone {
-			if self.GetRoom().HasExit(direction) {
+			if model.CanSeeExit(self.pc, self.GetRoom(), direction) {
 				err := model.MoveCharacter(self.pc, direction)
 				if err == nil {
 					self.PrintRoom()
@@ -301,6 +339,12 @@ func (self *Session) handleAction(action string, arg string) {
 			handler = actions[handler.alias]
 		}
 		handler.exec(self, arg)
+	} else if roomAction, found := engine.FindRoomAction(self.GetRoom(), action+" "+arg); found {
+		room := self.GetRoom()
+		engine.RunRoomAction(roomAction, self.pc, room)
+		if self.pc.GetRoomId() != room.GetId() {
+			self.PrintRoom()
+		}
 	} else {
 		self.printError("You can't do that")
 	}
@@ -311,7 +355,8 @@ func (self *Session) handleCommand(name string, arg string) {
 		return
 	}
 
-	if name[0] == '/' && self.user.IsAdmin() {
+	if name[0] == '/' && self.user.CanBuildIn(self.GetRoom().GetZoneId()) {
+		self.auditHere(name, arg)
 		quickRoom(self, name[1:])
 		return
 	}
@@ -320,19 +365,346 @@ func (self *Session) handleCommand(name string, arg string) {
 
 	if found {
 		if command.alias != "" {
+			name = command.alias
 			command = commands[command.alias]
 		}
 
-		if command.admin && !self.user.IsAdmin() {
+		if !self.user.HasPermission(command.permission) {
 			self.printError("You don't have permission to do that")
+		} else if command.permission == types.PermissionBuild && !self.user.CanBuildIn(self.GetRoom().GetZoneId()) {
+			self.printError("You aren't allowed to build in this zone")
+		} else if command.chat && self.isMuted() {
+			self.printError("You have been muted")
 		} else {
+			if command.permission != types.PermissionNone {
+				self.auditHere(name, arg)
+			}
 			command.exec(command, self, arg)
 		}
+	} else if channel := model.GetChannelByName(name); channel != nil && channel.IsMember(self.pc.GetId()) {
+		if self.isMuted() {
+			self.printError("You have been muted")
+		} else if arg == "" {
+			self.printError("Nothing to say")
+		} else if err := model.ChannelMessage(channel, self.pc, arg); err != nil {
+			self.printError(err.Error())
+		}
 	} else {
 		self.printError("Unrecognized command: %s", name)
 	}
 }
 
+// Prints the character's message history one page at a time. The kind can be
+// "tells", "says" or "channels", or empty to show everything.
+func (self *Session) showHistory(pc types.PC, kind string) {
+	var kinds []types.HistoryKind
+
+	switch strings.ToLower(kind) {
+	case "":
+		kinds = []types.HistoryKind{types.HistoryTell, types.HistorySay, types.HistoryChannel}
+	case "tell", "tells":
+		kinds = []types.HistoryKind{types.HistoryTell}
+	case "say", "says":
+		kinds = []types.HistoryKind{types.HistorySay}
+	case "channel", "channels":
+		kinds = []types.HistoryKind{types.HistoryChannel}
+	default:
+		self.printError("Usage: history [tells|says|channels]")
+		return
+	}
+
+	type line struct {
+		time time.Time
+		text string
+	}
+
+	var lines []line
+	for _, k := range kinds {
+		for _, entry := range pc.GetHistory(k) {
+			text := fmt.Sprintf("%s: %s", entry.From, entry.Message)
+
+			switch k {
+			case types.HistoryTell:
+				text = fmt.Sprintf("%s -> %s: %s", entry.From, entry.To, entry.Message)
+			case types.HistoryChannel:
+				text = fmt.Sprintf("[%s] %s", entry.To, text)
+			}
+
+			lines = append(lines, line{time: entry.Time, text: text})
+		}
+	}
+
+	if len(lines) == 0 {
+		self.WriteLine("No history")
+		return
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time.Before(lines[j].time)
+	})
+
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		texts[i] = types.Colorize(types.ColorBlue, l.time.Format("2006-01-02 15:04")) + " " +
+			types.Colorize(types.ColorWhite, l.text)
+	}
+
+	width, height := self.user.GetWindowSize()
+	pages := utils.Paginate(texts, width, height-2)
+
+	for i, page := range pages {
+		self.Write(page)
+
+		if i < len(pages)-1 {
+			input := self.getCleanUserInput(fmt.Sprintf("-- Page %v/%v, enter to continue or 'q' to stop -- ", i+1, len(pages)))
+			if input == "q" {
+				return
+			}
+		}
+	}
+}
+
+// Reads a multi-line message from the user and sends it as mail. A line
+// containing only a "." finishes the message.
+func (self *Session) composeMail(toUserId types.Id, toName string, subject string) {
+	body, saved := self.editText("message", "")
+	if !saved {
+		self.WriteLine("Message not sent")
+		return
+	}
+
+	if strings.TrimSpace(body) == "" {
+		self.printError("Message is empty, not sent")
+		return
+	}
+
+	model.SendMail(self.pc, toUserId, toName, subject, body)
+	self.WriteLine("Message sent to %s", toName)
+}
+
+var editorHelp = []string{
+	"Lines that don't start with '.' are added to the end of the text",
+	".s              Show the text with line numbers",
+	".i <#> <text>   Insert a line before line #",
+	".r <#> <text>   Replace line #",
+	".d <#>          Delete line #",
+	".c              Clear all of the text",
+	".f              Format the text to fit the window",
+	". or .x         Save and exit",
+	".q              Exit without saving",
+	".h              Show this help",
+}
+
+// Runs a line editor on the given text, returning the edited text and whether
+// the user saved it rather than aborting
+func (self *Session) editText(title string, text string) (string, bool) {
+	buffer := utils.NewTextBuffer(text)
+
+	self.WriteLineColor(types.ColorBlue, "Editing %s, type .h for help and . on its own to save", title)
+	self.showText(buffer)
+
+	for {
+		input := self.getRawUserInput("] ")
+
+		if !strings.HasPrefix(input, ".") {
+			buffer.Append(input)
+			continue
+		}
+
+		command, arg := utils.Argify(input[1:])
+
+		// Commands that take a line number
+		n := 0
+		line := ""
+		if command == "i" || command == "r" || command == "d" {
+			var numberStr string
+			numberStr, line = utils.Argify(arg)
+
+			var err error
+			n, err = utils.Atoir(numberStr, 1, buffer.Len()+1)
+			if err != nil {
+				self.printError(err.Error())
+				continue
+			}
+		}
+
+		var err error
+
+		switch command {
+		case "", "x":
+			return buffer.String(), true
+		case "q":
+			return text, false
+		case "s":
+			self.showText(buffer)
+		case "i":
+			err = buffer.Insert(n, line)
+		case "r":
+			err = buffer.Replace(n, line)
+		case "d":
+			err = buffer.Delete(n)
+		case "c":
+			buffer.Clear()
+		case "f":
+			width, _ := self.user.GetWindowSize()
+			buffer.Format(width - 1)
+			self.showText(buffer)
+		case "h":
+			for _, line := range editorHelp {
+				self.WriteLine(line)
+			}
+		default:
+			self.printError("Unrecognized editor command: .%s", command)
+		}
+
+		if err != nil {
+			self.printError(err.Error())
+		}
+	}
+}
+
+func (self *Session) showText(buffer *utils.TextBuffer) {
+	if buffer.Len() == 0 {
+		self.WriteLine("<empty>")
+	}
+
+	for i, line := range buffer.Lines() {
+		self.WriteLine("%s %s", types.Colorize(types.ColorDarkBlue, fmt.Sprintf("%3v:", i+1)), line)
+	}
+}
+
+// Looks for an extra description matching the keyword, first in the room and
+// then on the items in the room and the character's inventory
+func (self *Session) lookAtExtra(keyword string) {
+	extras := self.GetRoom().GetExtraDescriptions()
+
+	items := append(model.ItemsIn(self.GetRoom().GetId()), model.ItemsIn(self.pc.GetId())...)
+	for _, item := range items {
+		if template := model.GetTemplate(item.GetTemplateId()); template != nil {
+			for k, description := range template.GetExtraDescriptions() {
+				if _, found := extras[k]; !found {
+					extras[k] = description
+				}
+			}
+		}
+	}
+
+	keywords := make([]string, 0, len(extras))
+	for k := range extras {
+		keywords = append(keywords, k)
+	}
+
+	index := utils.BestMatch(keyword, keywords)
+
+	if index == -1 {
+		self.WriteLine("Nothing to see")
+	} else if index == -2 {
+		self.printError("Which one do you mean?")
+	} else {
+		self.WriteLine(strings.Replace(extras[keywords[index]], "\n", "\r\n", -1))
+	}
+}
+
+// Lists the extra descriptions of a room or template so that builders can
+// add, edit and remove them
+func (self *Session) extraDescriptionMenu(describer types.ExtraDescriber) {
+	self.execMenu("Extra descriptions", func(menu *utils.Menu) {
+		menu.AddAction("n", "New", func() {
+			keyword := self.getCleanUserInput("Keyword: ")
+			if keyword == "" {
+				return
+			}
+
+			description, saved := self.editText(keyword, describer.GetExtraDescriptions()[utils.Simplify(keyword)])
+			if saved {
+				describer.SetExtraDescription(keyword, description)
+			}
+		})
+
+		extras := describer.GetExtraDescriptions()
+		keywords := make([]string, 0, len(extras))
+		for keyword := range extras {
+			keywords = append(keywords, keyword)
+		}
+		sort.Strings(keywords)
+
+		for i, keyword := range keywords {
+			k := keyword
+			menu.AddActionI(i, k, func() {
+				self.execMenu(k, func(menu *utils.Menu) {
+					menu.AddAction("e", "Edit", func() {
+						description, saved := self.editText(k, describer.GetExtraDescriptions()[k])
+						if saved {
+							describer.SetExtraDescription(k, description)
+						}
+					})
+
+					menu.AddAction("d", "Delete", func() {
+						describer.RemoveExtraDescription(k)
+						menu.Exit()
+					})
+				})
+			})
+		}
+	})
+}
+
+// editScript opens the script in the editor. Scripts that don't compile are
+// only saved if the builder insists.
+func (self *Session) editScript(title string, scriptable types.Scriptable) {
+	source, saved := self.editText(title, scriptable.GetScript())
+	if !saved {
+		return
+	}
+
+	if err := script.Check(source); err != nil {
+		self.printError("%s", err)
+		if !self.getConfirmation("Save anyway? ") {
+			return
+		}
+	}
+
+	scriptable.SetScript(source)
+}
+
+// Moves the character after the leader they are following
+func (self *Session) follow(leader types.Character, direction types.Direction) {
+	err := model.MoveCharacter(self.pc, direction)
+
+	self.clearLine()
+	if err == nil {
+		self.WriteLine("You follow %s", leader.GetName())
+		self.PrintRoom()
+	} else {
+		self.printError("You can't follow %s: %s", leader.GetName(), err.Error())
+	}
+}
+
+// Audits an action along with the zone and location it was taken in
+func (self *Session) auditHere(action string, target string) {
+	room := self.GetRoom()
+	self.audit(action, target, fmt.Sprintf("in %s %v", self.currentZone().GetName(), room.GetLocation()))
+}
+
+// Records an action taken by this session's user in the audit log
+func (self *Session) audit(action string, target string, details string) {
+	err := audit.Log(audit.Entry{
+		User:      self.user.GetName(),
+		Character: self.pc.GetName(),
+		Action:    action,
+		Target:    target,
+		Details:   details,
+	})
+
+	if err != nil {
+		fmt.Println("Failed to write audit log:", err)
+	}
+}
+
+func (self *Session) isMuted() bool {
+	return model.GetSanction(self.user.GetId(), types.SanctionMute) != nil
+}
+
 func (self *Session) GetRoom() types.Room {
 	return model.GetRoom(self.pc.GetRoomId())
 }
@@ -341,6 +713,20 @@ func (self *Session) PrintRoom() {
 	self.printRoom(self.GetRoom())
 }
 
+// How the sky is described in each kind of weather
+var weatherWords = map[types.Weather]string{
+	types.WeatherClear:  "clear",
+	types.WeatherCloudy: "cloudy",
+	types.WeatherRain:   "rainy",
+	types.WeatherStorm:  "stormy",
+}
+
+// Returns false if the room is too dark to see anything in. Builders can see
+// inside dark rooms in their own zones so that they can work on them.
+func (self *Session) canSeeIn(room types.Room) bool {
+	return self.user.CanBuildIn(room.GetZoneId()) || model.IsLit(room)
+}
+
 func (self *Session) printRoom(room types.Room) {
 	pcs := model.PlayerCharactersIn(self.pc.GetRoomId(), self.pc.GetId())
 	npcs := model.NpcsIn(room.GetId())
@@ -352,6 +738,23 @@ func (self *Session) printRoom(room types.Room) {
 		area = model.GetArea(room.GetAreaId())
 	}
 
+	// Builders see hidden exits in their own zones so that they can work on
+	// them
+	builder := self.user.CanBuildIn(room.GetZoneId())
+
+	description := room.GetDescription()
+	if night := room.GetNightDescription(); night != "" && !model.GetWorld().IsDaytime() {
+		description = night
+	}
+
+	cash := room.GetCash()
+	dark := !self.canSeeIn(room)
+
+	if dark {
+		description = "It's too dark to see anything."
+		pcs, npcs, items, store, cash = nil, nil, nil, nil, 0
+	}
+
 	var str string
 
 	areaStr := ""
@@ -365,7 +768,14 @@ func (self *Session) printRoom(room types.Room) {
 		types.ColorWhite, types.ColorBlue,
 		room.GetLocation().X, room.GetLocation().Y, room.GetLocation().Z,
 		types.ColorWhite,
-		room.GetDescription())
+		strings.Replace(description, "\n", "\r\n ", -1))
+
+	if !dark && !room.HasFlag(types.RoomIndoors) {
+		weather := model.GetZone(room.GetZoneId()).GetWeather()
+		phase := model.GetWorld().GetPhase()
+		str = fmt.Sprintf("%s %s %s\r\n\r\n", str, types.Colorize(types.ColorBlue, "Sky:"),
+			types.Colorize(types.ColorWhite, fmt.Sprintf("A %s %s", weatherWords[weather], strings.ToLower(string(phase)))))
+	}
 
 	if store != nil {
 		str = fmt.Sprintf("%s Store: %s\r\n\r\n", str, types.Colorize(types.ColorBlue, store.GetName()))
@@ -429,11 +839,31 @@ func (self *Session) printRoom(room types.Room) {
 		extraNewLine = "\r\n"
 	}
 
+	if cash > 0 {
+		str = fmt.Sprintf("%s %s%s\r\n", str,
+			types.Colorize(types.ColorBlue, "Cash: "),
+			types.Colorize(types.ColorWhite, strconv.Itoa(cash)))
+
+		extraNewLine = "\r\n"
+	}
+
 	str = str + extraNewLine + " " + types.Colorize(types.ColorBlue, "Exits: ")
 
 	var exitList []string
 	for _, direction := range room.GetExits() {
-		exitList = append(exitList, utils.DirectionToExitString(direction))
+		visible := model.CanSeeExit(self.pc, room, direction)
+		if !visible && !builder {
+			continue
+		}
+
+		exit := utils.DirectionToExitString(direction)
+		if room.IsClosed(direction) {
+			exit += types.Colorize(types.ColorBlue, "(closed)")
+		}
+		if !visible {
+			exit += types.Colorize(types.ColorBlue, "(hidden)")
+		}
+		exitList = append(exitList, exit)
 	}
 
 	if len(exitList) == 0 {
@@ -103,7 +103,11 @@ func (*MockContainer) GetCash() int {
 	return 0
 }
 
-func (*MockContainer) RemoveCash(int) {
+func (*MockContainer) SetCash(int) {
+}
+
+func (*MockContainer) RemoveCash(int) bool {
+	return true
 }
 
 func (*MockContainer) AddItem(types.Id) {
@@ -154,6 +158,20 @@ func (*MockCharacter) GetWeight() int {
 	return 0
 }
 
+func (*MockCharacter) GetStrength() int {
+	return 10
+}
+
+func (*MockCharacter) SetStrength(int) {
+}
+
+func (*MockCharacter) GetExperience() int {
+	return 0
+}
+
+func (*MockCharacter) AddExperience(int) {
+}
+
 type MockPC struct {
 	MockCharacter
 	RoomId types.Id
@@ -179,6 +197,24 @@ func (self MockPC) IsOnline() bool {
 	return true
 }
 
+func (self MockPC) SetOnline(bool) {
+}
+
+func (self MockPC) GetUserId() types.Id {
+	return nil
+}
+
+func (self MockPC) AddHistory(types.HistoryKind, types.HistoryEntry) {
+}
+
+func (self MockPC) GetHistory(types.HistoryKind) []types.HistoryEntry {
+	return nil
+}
+
+func (self MockPC) IsIgnoring(types.Character) bool {
+	return false
+}
+
 func (self MockPC) SetRoomId(types.Id) {
 }
 
@@ -188,3 +224,10 @@ func (self MockPC) GetSkills() []types.Id {
 
 func (self MockPC) AddSkill(types.Id) {
 }
+
+func (self MockPC) RemoveSkill(types.Id) {
+}
+
+func (self MockPC) HasSkill(types.Id) bool {
+	return false
+}
@@ -0,0 +1,287 @@
+package trade
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Cristofori/kmud/events"
+	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/types"
+)
+
+type offer struct {
+	character types.PC
+	items     types.ItemList
+	cash      int
+	confirmed bool
+}
+
+type trade struct {
+	offers [2]*offer
+}
+
+var requests map[types.PC]types.PC
+var trades map[types.PC]*trade
+var mutex sync.Mutex
+
+func init() {
+	requests = map[types.PC]types.PC{}
+	trades = map[types.PC]*trade{}
+}
+
+// Request asks the target to start trading with the requester. If the target
+// has already asked to trade with the requester then the trade is started.
+func Request(from types.PC, to types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if from == to {
+		return errors.New("You can't trade with yourself")
+	}
+
+	if from.GetRoomId() != to.GetRoomId() {
+		return errors.New("You can only trade with someone in the same room")
+	}
+
+	if _, found := trades[from]; found {
+		return errors.New("You are already trading")
+	}
+
+	if _, found := trades[to]; found {
+		return errors.New("They are already trading with someone else")
+	}
+
+	if requests[to] == from {
+		delete(requests, to)
+		delete(requests, from)
+
+		t := &trade{offers: [2]*offer{{character: from}, {character: to}}}
+		trades[from] = t
+		trades[to] = t
+
+		t.broadcast("Trade started")
+		return nil
+	}
+
+	requests[from] = to
+	events.Broadcast(events.TradeRequestEvent{From: from, To: to})
+	return nil
+}
+
+// OfferItem adds the given item to the character's side of the trade
+func OfferItem(pc types.PC, item types.Item) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	t, o, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	if item.GetContainerId() != pc.GetId() {
+		return errors.New("You aren't carrying that")
+	}
+
+	for _, offered := range o.items {
+		if offered == item {
+			return errors.New("You are already offering that")
+		}
+	}
+
+	o.items = append(o.items, item)
+	t.changed(pc.GetName() + " offered " + item.GetName())
+	return nil
+}
+
+// WithdrawItem removes the given item from the character's side of the trade
+func WithdrawItem(pc types.PC, item types.Item) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	t, o, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	for i, offered := range o.items {
+		if offered == item {
+			o.items = append(o.items[:i], o.items[i+1:]...)
+			t.changed(pc.GetName() + " withdrew " + item.GetName())
+			return nil
+		}
+	}
+
+	return errors.New("You aren't offering that")
+}
+
+// OfferCash sets the amount of cash the character is offering
+func OfferCash(pc types.PC, amount int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	t, o, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	if amount < 0 {
+		return errors.New("You can't offer a negative amount")
+	}
+
+	if amount > pc.GetCash() {
+		return errors.New("You don't have that much cash")
+	}
+
+	o.cash = amount
+	t.changed(pc.GetName() + " changed their cash offer")
+	return nil
+}
+
+// Confirm marks the character's side of the trade as accepted. Once both
+// sides have confirmed the exchange is carried out.
+func Confirm(pc types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	t, o, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	o.confirmed = true
+
+	if !t.offers[0].confirmed || !t.offers[1].confirmed {
+		t.broadcast(pc.GetName() + " confirmed the trade")
+		return nil
+	}
+
+	t.end()
+
+	if err := t.execute(); err != nil {
+		t.broadcast("Trade failed: " + err.Error())
+		return err
+	}
+
+	t.broadcast("Trade completed")
+	return nil
+}
+
+// Cancel aborts any trade or outstanding trade request involving the character
+func Cancel(pc types.PC) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	delete(requests, pc)
+
+	if t, found := trades[pc]; found {
+		t.end()
+		t.broadcast(pc.GetName() + " cancelled the trade")
+	}
+}
+
+// Status sends the current state of the character's trade to both parties
+func Status(pc types.PC) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	t, _, err := lookup(pc)
+	if err != nil {
+		return err
+	}
+
+	t.broadcast("Current trade")
+	return nil
+}
+
+// IsTrading returns true if the character is currently involved in a trade
+func IsTrading(pc types.PC) bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	_, found := trades[pc]
+	return found
+}
+
+func lookup(pc types.PC) (*trade, *offer, error) {
+	t, found := trades[pc]
+	if !found {
+		return nil, nil, errors.New("You aren't trading with anyone")
+	}
+
+	if t.offers[0].character.GetRoomId() != t.offers[1].character.GetRoomId() {
+		t.end()
+		t.broadcast("Trade cancelled, you are no longer in the same room")
+		return nil, nil, errors.New("Trade cancelled")
+	}
+
+	return t, t.offerOf(pc), nil
+}
+
+func (self *trade) offerOf(pc types.PC) *offer {
+	if self.offers[0].character == pc {
+		return self.offers[0]
+	}
+	return self.offers[1]
+}
+
+func (self *trade) otherOffer(o *offer) *offer {
+	if self.offers[0] == o {
+		return self.offers[1]
+	}
+	return self.offers[0]
+}
+
+func (self *trade) end() {
+	for _, o := range self.offers {
+		delete(trades, o.character)
+	}
+}
+
+// Any change to the offer resets both confirmations so that nobody can be
+// tricked into accepting something they didn't see
+func (self *trade) changed(message string) {
+	for _, o := range self.offers {
+		o.confirmed = false
+	}
+	self.broadcast(message)
+}
+
+func (self *trade) broadcast(message string) {
+	var event events.TradeEvent
+	event.Message = message
+
+	for i, o := range self.offers {
+		event.Offers[i] = events.TradeOffer{
+			Character: o.character,
+			Items:     o.items.Names(),
+			Cash:      o.cash,
+			Confirmed: o.confirmed,
+		}
+	}
+
+	events.Broadcast(event)
+}
+
+func (self *trade) execute() error {
+	first, second := self.offers[0], self.offers[1]
+
+	if first.character.GetRoomId() != second.character.GetRoomId() {
+		return errors.New("You are no longer in the same room")
+	}
+
+	tx := model.NewTransaction()
+
+	for _, o := range self.offers {
+		other := self.otherOffer(o)
+
+		for _, item := range o.items {
+			tx.MoveItem(item, o.character.GetId(), other.character.GetId())
+		}
+
+		if o.cash > 0 {
+			tx.TransferCash(o.character, other.character, o.cash)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,75 @@
+package trade
+
+import (
+	"testing"
+
+	"github.com/Cristofori/kmud/testutils"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type TradeSuite struct{}
+
+var _ = Suite(&TradeSuite{})
+
+func (s *TradeSuite) TestRequest(c *C) {
+	char1 := testutils.NewMockPC()
+	char2 := testutils.NewMockPC()
+
+	c.Assert(Request(char1, char1), Not(IsNil))
+	c.Assert(Request(char1, char2), Not(IsNil))
+
+	char2.RoomId = char1.RoomId
+
+	c.Assert(Request(char1, char2), IsNil)
+	c.Assert(IsTrading(char1), Equals, false)
+	c.Assert(IsTrading(char2), Equals, false)
+
+	c.Assert(Request(char2, char1), IsNil)
+	c.Assert(IsTrading(char1), Equals, true)
+	c.Assert(IsTrading(char2), Equals, true)
+
+	c.Assert(Request(char1, char2), Not(IsNil))
+
+	Cancel(char2)
+	c.Assert(IsTrading(char1), Equals, false)
+	c.Assert(IsTrading(char2), Equals, false)
+}
+
+func (s *TradeSuite) TestConfirm(c *C) {
+	char1 := testutils.NewMockPC()
+	char2 := testutils.NewMockPC()
+	char2.RoomId = char1.RoomId
+
+	c.Assert(Confirm(char1), Not(IsNil))
+
+	Request(char1, char2)
+	Request(char2, char1)
+
+	c.Assert(OfferCash(char1, 10), Not(IsNil))
+	c.Assert(OfferCash(char1, -1), Not(IsNil))
+	c.Assert(OfferCash(char1, 0), IsNil)
+
+	c.Assert(Confirm(char1), IsNil)
+	c.Assert(IsTrading(char1), Equals, true)
+
+	c.Assert(Confirm(char2), IsNil)
+	c.Assert(IsTrading(char1), Equals, false)
+	c.Assert(IsTrading(char2), Equals, false)
+}
+
+func (s *TradeSuite) TestLeaveRoom(c *C) {
+	char1 := testutils.NewMockPC()
+	char2 := testutils.NewMockPC()
+	char2.RoomId = char1.RoomId
+
+	Request(char1, char2)
+	Request(char2, char1)
+
+	char2.RoomId = testutils.NewMockPC().RoomId
+
+	c.Assert(Status(char1), Not(IsNil))
+	c.Assert(IsTrading(char1), Equals, false)
+	c.Assert(IsTrading(char2), Equals, false)
+}
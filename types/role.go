@@ -0,0 +1,62 @@
+package types
+
+type Role string
+
+const (
+	RolePlayer    Role = "Player"
+	RoleHelper    Role = "Helper"
+	RoleBuilder   Role = "Builder"
+	RoleModerator Role = "Moderator"
+	RoleAdmin     Role = "Admin"
+)
+
+var Roles = []Role{RolePlayer, RoleHelper, RoleBuilder, RoleModerator, RoleAdmin}
+
+type Permission string
+
+const (
+	PermissionNone     Permission = ""
+	PermissionHelp     Permission = "Help"
+	PermissionBuild    Permission = "Build"
+	PermissionModerate Permission = "Moderate"
+	PermissionAdmin    Permission = "Admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RolePlayer:    {},
+	RoleHelper:    {PermissionHelp},
+	RoleBuilder:   {PermissionHelp, PermissionBuild},
+	RoleModerator: {PermissionHelp, PermissionModerate},
+	RoleAdmin:     {PermissionHelp, PermissionBuild, PermissionModerate, PermissionAdmin},
+}
+
+// HasPermission returns true if the role grants the given permission.
+// Everybody has PermissionNone.
+func (self Role) HasPermission(permission Permission) bool {
+	if permission == PermissionNone {
+		return true
+	}
+
+	for _, p := range rolePermissions[self] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Outranks returns true if the role sits above the other one in Roles.
+// Unknown roles rank below everybody.
+func (self Role) Outranks(other Role) bool {
+	return self.rank() > other.rank()
+}
+
+func (self Role) rank() int {
+	for i, role := range Roles {
+		if role == self {
+			return i
+		}
+	}
+	return -1
+}
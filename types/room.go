@@ -0,0 +1,56 @@
+package types
+
+type RoomFlag string
+
+const (
+	// Nothing can be seen without a light
+	RoomDark RoomFlag = "Dark"
+	// Nobody can start a fight
+	RoomSafe RoomFlag = "Safe"
+	// Sheltered from the sky and the weather
+	RoomIndoors RoomFlag = "Indoors"
+	// Nobody can teleport in or out
+	RoomNoTeleport RoomFlag = "NoTeleport"
+	// Players can't recall out
+	RoomNoRecall RoomFlag = "NoRecall"
+)
+
+var RoomFlags = []RoomFlag{RoomDark, RoomSafe, RoomIndoors, RoomNoTeleport, RoomNoRecall}
+
+type Terrain string
+
+const (
+	TerrainCity     Terrain = "City"
+	TerrainField    Terrain = "Field"
+	TerrainForest   Terrain = "Forest"
+	TerrainHills    Terrain = "Hills"
+	TerrainMountain Terrain = "Mountain"
+	TerrainSwamp    Terrain = "Swamp"
+	TerrainWater    Terrain = "Water"
+)
+
+var Terrains = []Terrain{
+	TerrainCity,
+	TerrainField,
+	TerrainForest,
+	TerrainHills,
+	TerrainMountain,
+	TerrainSwamp,
+	TerrainWater,
+}
+
+// MovementCost is how hard the terrain is to cross compared to a city street.
+// Rooms without a terrain cost the same as a street.
+func (self Terrain) MovementCost() int {
+	switch self {
+	case TerrainField:
+		return 2
+	case TerrainForest:
+		return 3
+	case TerrainHills, TerrainSwamp:
+		return 4
+	case TerrainMountain, TerrainWater:
+		return 6
+	}
+	return 1
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"strings"
+)
+
+type ActionTrigger string
+
+const (
+	TriggerCommand ActionTrigger = "Command"
+	TriggerEnter   ActionTrigger = "Enter"
+	TriggerLeave   ActionTrigger = "Leave"
+)
+
+var ActionTriggers = []ActionTrigger{TriggerCommand, TriggerEnter, TriggerLeave}
+
+type ResponseKind string
+
+const (
+	ResponseMessage  ResponseKind = "Message"
+	ResponseLock     ResponseKind = "Lock"
+	ResponseUnlock   ResponseKind = "Unlock"
+	ResponseToggle   ResponseKind = "Toggle"
+	ResponseOpen     ResponseKind = "Open"
+	ResponseClose    ResponseKind = "Close"
+	ResponseTeleport ResponseKind = "Teleport"
+	ResponseItem     ResponseKind = "Item"
+	ResponseEffect   ResponseKind = "Effect"
+)
+
+var ResponseKinds = []ResponseKind{
+	ResponseMessage,
+	ResponseLock,
+	ResponseUnlock,
+	ResponseToggle,
+	ResponseOpen,
+	ResponseClose,
+	ResponseTeleport,
+	ResponseItem,
+	ResponseEffect,
+}
+
+// RoomResponse is one of the things that happens when a room action runs.
+// Which fields are used depends on the kind: messages use Message and
+// RoomMessage, the lock and door kinds use Direction, and teleports, items and effects
+// use Target for the room, template or effect.
+type RoomResponse struct {
+	Kind        ResponseKind
+	Message     string    `bson:",omitempty"`
+	RoomMessage string    `bson:",omitempty"`
+	Direction   Direction `bson:",omitempty"`
+	Target      Id        `bson:",omitempty"`
+}
+
+// RoomAction is something a builder has set up to happen in a room, either
+// when a character types the command or when they enter or leave the room
+type RoomAction struct {
+	Trigger   ActionTrigger
+	Command   string `bson:",omitempty"`
+	Responses []RoomResponse
+}
+
+// Matches returns true if the given input runs the action. Case and extra
+// spaces between words are ignored.
+func (self RoomAction) Matches(input string) bool {
+	if self.Trigger != TriggerCommand || self.Command == "" {
+		return false
+	}
+
+	return strings.Join(strings.Fields(strings.ToLower(input)), " ") ==
+		strings.Join(strings.Fields(strings.ToLower(self.Command)), " ")
+}
+
+func (self RoomAction) String() string {
+	if self.Trigger == TriggerCommand {
+		return self.Command
+	}
+	return "(" + strings.ToLower(string(self.Trigger)) + ")"
+}
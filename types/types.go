@@ -2,6 +2,7 @@ package types
 
 import (
 	"net"
+	"time"
 
 	"github.com/Cristofori/kmud/utils/naturalsort"
 )
@@ -27,6 +28,9 @@ const (
 	EffectType   ObjectType = "Effect"
 	StoreType    ObjectType = "Store"
 	WorldType    ObjectType = "World"
+	ChannelType  ObjectType = "Channel"
+	MailType     ObjectType = "Mail"
+	SanctionType ObjectType = "Sanction"
 )
 
 type Identifiable interface {
@@ -59,12 +63,27 @@ type Loginable interface {
 
 type Container interface {
 	AddCash(int)
-	RemoveCash(int)
+	RemoveCash(int) bool
 	GetCash() int
+	SetCash(int)
 	SetCapacity(int)
 	GetCapacity() int
 }
 
+// ExtraDescriber is anything with extra descriptions, details that players
+// can look at by keyword
+type ExtraDescriber interface {
+	SetExtraDescription(keyword string, description string)
+	RemoveExtraDescription(keyword string)
+	GetExtraDescriptions() map[string]string
+}
+
+// Scriptable is anything builders can attach a script to
+type Scriptable interface {
+	GetScript() string
+	SetScript(string)
+}
+
 type Object interface {
 	Identifiable
 	ReadLockable
@@ -86,6 +105,12 @@ type Character interface {
 	SetHealth(int)
 	GetSkills() []Id
 	AddSkill(Id)
+	RemoveSkill(Id)
+	HasSkill(Id) bool
+	GetStrength() int
+	SetStrength(int)
+	GetExperience() int
+	AddExperience(int)
 }
 
 type CharacterList []Character
@@ -98,9 +123,31 @@ func (self CharacterList) Names() []string {
 	return names
 }
 
+type HistoryKind string
+
+const (
+	HistoryTell    HistoryKind = "Tell"
+	HistorySay     HistoryKind = "Say"
+	HistoryChannel HistoryKind = "Channel"
+)
+
+// HistoryEntry is a single message that a character sent or received. To
+// holds the recipient of a tell or the name of a channel, and is empty for
+// messages said out loud.
+type HistoryEntry struct {
+	Time    time.Time
+	From    string
+	To      string
+	Message string
+}
+
 type PC interface {
 	Character
 	Loginable
+	GetUserId() Id
+	AddHistory(HistoryKind, HistoryEntry)
+	GetHistory(HistoryKind) []HistoryEntry
+	IsIgnoring(Character) bool
 }
 
 type PCList []PC
@@ -120,15 +167,63 @@ type NPC interface {
 	SetConversation(string)
 	GetConversation() string
 	PrettyConversation() string
+	Scriptable
+	GetSpawnerId() Id
 }
 
 type NPCList []NPC
 
+type LootDrop struct {
+	TemplateId Id
+	Weight     int
+	Guaranteed bool
+}
+
+type LootTable struct {
+	Drops   []LootDrop
+	Rolls   int
+	MinCash int
+	MaxCash int
+	OnDeath bool
+}
+
+type SpawnSchedule string
+
+const (
+	SpawnAlways SpawnSchedule = "Always"
+	SpawnDay    SpawnSchedule = "Day"
+	SpawnNight  SpawnSchedule = "Night"
+)
+
 type Spawner interface {
 	Character
 	GetAreaId() Id
 	SetCount(int)
 	GetCount() int
+	GetLoot() LootTable
+	SetLoot(LootTable)
+	GetRoaming() bool
+	SetRoaming(bool)
+	GetConversation() string
+	SetConversation(string)
+	Scriptable
+	GetEquipment() []Id
+	AddEquipment(Id)
+	RemoveEquipment(Id)
+	GetRespawnDelay() int
+	SetRespawnDelay(int)
+	GetRoomIds() []Id
+	AddRoom(Id)
+	RemoveRoom(Id)
+	HasRoom(Id) bool
+	GetMaxPerRoom() int
+	SetMaxPerRoom(int)
+	GetSchedule() SpawnSchedule
+	SetSchedule(SpawnSchedule)
+	IsPaused() bool
+	SetPaused(bool)
+	RecordDeath()
+	PendingRespawns() int
 }
 
 type SpawnerList []Spawner
@@ -156,12 +251,61 @@ type Room interface {
 	SetTitle(string)
 	GetDescription() string
 	SetDescription(string)
+	GetNightDescription() string
+	SetNightDescription(string)
 	SetLink(string, Id)
 	RemoveLink(string)
 	GetLinks() map[string]Id
 	LinkNames() []string
 	SetLocked(Direction, bool)
 	IsLocked(Direction) bool
+	SetDoor(Direction, Door)
+	RemoveDoor(Direction)
+	GetDoor(Direction) (Door, bool)
+	SetClosed(Direction, bool)
+	IsClosed(Direction) bool
+	SetHidden(Direction, bool)
+	IsHidden(Direction) bool
+	SetRestriction(Direction, ExitRestriction)
+	GetRestriction(Direction) ExitRestriction
+	SetFlag(RoomFlag, bool)
+	HasFlag(RoomFlag) bool
+	GetFlags() []RoomFlag
+	SetTerrain(Terrain)
+	GetTerrain() Terrain
+	ExtraDescriber
+	Scriptable
+	GetActions() []RoomAction
+	AddAction(RoomAction)
+	SetAction(int, RoomAction)
+	RemoveAction(int)
+}
+
+// Door describes the door in an exit. Unlocking it takes an item made from
+// the key template, and Difficulty is the chance out of 100 that an attempt
+// to pick the lock fails. A door without a key can only be locked and
+// unlocked by builders, and a Difficulty of 0 means the lock can't be picked.
+type Door struct {
+	Name       string
+	KeyId      Id
+	Difficulty int
+}
+
+// DefaultDoor is put in exits that were locked before exits had doors, so
+// that they stay locked
+var DefaultDoor = Door{Name: "door"}
+
+// ExitRestriction limits who can use an exit. Characters need at least
+// MinExperience, and when they're set, the skill and an item made from the
+// template.
+type ExitRestriction struct {
+	MinExperience int `bson:",omitempty"`
+	SkillId       Id  `bson:",omitempty"`
+	TemplateId    Id  `bson:",omitempty"`
+}
+
+func (self ExitRestriction) IsEmpty() bool {
+	return self.MinExperience <= 0 && self.SkillId == nil && self.TemplateId == nil
 }
 
 type RoomList []Room
@@ -169,6 +313,7 @@ type RoomList []Room
 type Area interface {
 	Object
 	Nameable
+	GetZoneId() Id
 }
 
 type AreaList []Area
@@ -176,6 +321,8 @@ type AreaList []Area
 type Zone interface {
 	Object
 	Nameable
+	GetWeather() Weather
+	SetWeather(Weather)
 }
 
 type ZoneList []Zone
@@ -187,6 +334,10 @@ type Time interface {
 type World interface {
 	GetTime() Time
 	AdvanceTime()
+	IsDaytime() bool
+	GetPhase() DayPhase
+	GetJailRoomId() Id
+	SetJailRoomId(Id)
 }
 
 type Communicable interface {
@@ -196,6 +347,13 @@ type Communicable interface {
 	GetWindowSize() (int, int)
 }
 
+// Special wrap widths. WrapAuto wraps output to the width of the user's
+// window, and WrapOff leaves it alone.
+const (
+	WrapAuto = 0
+	WrapOff  = -1
+)
+
 type User interface {
 	Object
 	Nameable
@@ -209,8 +367,21 @@ type User interface {
 	GetTerminalType() string
 	GetColorMode() ColorMode
 	SetColorMode(ColorMode)
+	GetWrapWidth() int
+	SetWrapWidth(int)
 	IsAdmin() bool
 	SetAdmin(bool)
+	GetRole() Role
+	SetRole(Role)
+	HasPermission(Permission) bool
+	CanBuildIn(Id) bool
+	AddBuilderZone(Id)
+	RemoveBuilderZone(Id)
+	GetBuilderZones() []Id
+	Ignore(Id)
+	Unignore(Id)
+	IsIgnoring(Id) bool
+	GetIgnored() []Id
 }
 
 type UserList []User
@@ -236,6 +407,12 @@ type Template interface {
 	GetWeight() int
 	GetCapacity() int
 	SetCapacity(int)
+	GetCategory() string
+	SetCategory(string)
+	IsLight() bool
+	SetLight(bool)
+	ExtraDescriber
+	Scriptable
 }
 
 type TemplateList []Template
@@ -348,14 +525,146 @@ func (self EffectList) Names() []string {
 	return names
 }
 
+type StockEntry struct {
+	TemplateId  Id
+	Quantity    int
+	Interval    int // Minutes between restocks
+	LastRestock time.Time
+}
+
 type Store interface {
 	Object
 	Nameable
 	Container
-}
+	GetStock() []StockEntry
+	SetStock(Id, int, int)
+	RemoveStock(Id)
+	SetRestocked(Id, time.Time)
+	GetBuyRate() int
+	SetBuyRate(int)
+	GetSellRate() int
+	SetSellRate(int)
+	BuyPrice(int) int
+	SellPrice(int) int
+	GetSpendLimit() int
+	SetSpendLimit(int)
+	CanSpend(int) bool
+	RecordSpending(int)
+	AddCategory(string)
+	RemoveCategory(string)
+	GetCategories() []string
+	AcceptsCategory(string) bool
+}
+
+type StoreList []Store
 
 type Purchaser interface {
 	GetId() Id
 	AddCash(int)
-	RemoveCash(int)
+	RemoveCash(int) bool
+}
+
+type Transaction interface {
+	TransferCash(from Identifiable, to Identifiable, amount int)
+	MoveItem(item Item, from Id, to Id)
+	Commit() error
+}
+
+type ChannelMessage struct {
+	From    string
+	Message string
+	Time    time.Time
+}
+
+type Channel interface {
+	Object
+	Nameable
+	GetColor() Color
+	SetColor(Color)
+	GetOwnerId() Id
+	IsAdminOnly() bool
+	SetAdminOnly(bool)
+	Join(Id)
+	Leave(Id)
+	IsMember(Id) bool
+	GetMembers() []Id
+	SetMuted(Id, bool)
+	IsMuted(Id) bool
+	SetSilenced(Id, bool)
+	IsSilenced(Id) bool
+	SetBanned(Id, bool)
+	IsBanned(Id) bool
+	AddHistory(string, string)
+	GetHistory() []ChannelMessage
+}
+
+type ChannelList []Channel
+
+func (self ChannelList) Names() []string {
+	names := make([]string, len(self))
+	for i, channel := range self {
+		names[i] = channel.GetName()
+	}
+	return names
+}
+
+type Mail interface {
+	Object
+	GetFromName() string
+	GetToUserId() Id
+	GetToName() string
+	GetSubject() string
+	GetBody() string
+	GetSent() time.Time
+	IsRead() bool
+	MarkRead()
+}
+
+type MailList []Mail
+
+func (self MailList) Len() int {
+	return len(self)
+}
+
+func (self MailList) Less(i, j int) bool {
+	return self[i].GetSent().Before(self[j].GetSent())
+}
+
+func (self MailList) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
+}
+
+type SanctionKind string
+
+const (
+	SanctionMute  SanctionKind = "Mute"
+	SanctionJail  SanctionKind = "Jail"
+	SanctionBan   SanctionKind = "Ban"
+	SanctionIpBan SanctionKind = "IP ban"
+)
+
+type Sanction interface {
+	Object
+	GetKind() SanctionKind
+	GetUserId() Id
+	GetAddress() string
+	GetReason() string
+	GetIssuer() string
+	GetIssued() time.Time
+	GetExpires() time.Time
+	IsExpired() bool
+}
+
+type SanctionList []Sanction
+
+func (self SanctionList) Len() int {
+	return len(self)
+}
+
+func (self SanctionList) Less(i, j int) bool {
+	return self[i].GetIssued().Before(self[j].GetIssued())
+}
+
+func (self SanctionList) Swap(i, j int) {
+	self[i], self[j] = self[j], self[i]
 }
@@ -1 +1,96 @@
 package types
+
+import "testing"
+
+func Test_HasPermission(t *testing.T) {
+	tests := []struct {
+		role       Role
+		permission Permission
+		output     bool
+	}{
+		{RolePlayer, PermissionNone, true},
+		{RolePlayer, PermissionHelp, false},
+		{RoleHelper, PermissionHelp, true},
+		{RoleHelper, PermissionBuild, false},
+		{RoleBuilder, PermissionBuild, true},
+		{RoleBuilder, PermissionModerate, false},
+		{RoleModerator, PermissionModerate, true},
+		{RoleModerator, PermissionBuild, false},
+		{RoleAdmin, PermissionAdmin, true},
+		{RoleAdmin, PermissionBuild, true},
+		{Role("Unknown"), PermissionHelp, false},
+	}
+
+	for _, test := range tests {
+		result := test.role.HasPermission(test.permission)
+		if result != test.output {
+			t.Errorf("%s.HasPermission(%s) == %v, want %v", test.role, test.permission, result, test.output)
+		}
+	}
+}
+
+func Test_Outranks(t *testing.T) {
+	tests := []struct {
+		role   Role
+		other  Role
+		output bool
+	}{
+		{RoleAdmin, RoleModerator, true},
+		{RoleModerator, RoleBuilder, true},
+		{RoleModerator, RoleModerator, false},
+		{RoleModerator, RoleAdmin, false},
+		{RolePlayer, Role("Unknown"), true},
+		{Role("Unknown"), RolePlayer, false},
+	}
+
+	for _, test := range tests {
+		result := test.role.Outranks(test.other)
+		if result != test.output {
+			t.Errorf("%s.Outranks(%s) == %v, want %v", test.role, test.other, result, test.output)
+		}
+	}
+}
+
+func Test_RoomActionMatches(t *testing.T) {
+	lever := RoomAction{Trigger: TriggerCommand, Command: "pull lever"}
+	enter := RoomAction{Trigger: TriggerEnter, Command: "pull lever"}
+
+	tests := []struct {
+		action RoomAction
+		input  string
+		output bool
+	}{
+		{lever, "pull lever", true},
+		{lever, "  Pull   LEVER ", true},
+		{lever, "pull", false},
+		{lever, "pull lever hard", false},
+		{enter, "pull lever", false},
+		{RoomAction{Trigger: TriggerCommand}, "", false},
+	}
+
+	for _, test := range tests {
+		result := test.action.Matches(test.input)
+		if result != test.output {
+			t.Errorf("%v.Matches(%q) == %v, want %v", test.action, test.input, result, test.output)
+		}
+	}
+}
+
+func Test_MovementCost(t *testing.T) {
+	tests := []struct {
+		terrain Terrain
+		output  int
+	}{
+		{"", 1},
+		{TerrainCity, 1},
+		{TerrainForest, 3},
+		{TerrainWater, 6},
+	}
+
+	for _, test := range tests {
+		result := test.terrain.MovementCost()
+		if result != test.output {
+			t.Errorf("%q.MovementCost() == %v, want %v", test.terrain, result, test.output)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package types
+
+type DayPhase string
+
+const (
+	PhaseDawn  DayPhase = "Dawn"
+	PhaseDay   DayPhase = "Day"
+	PhaseDusk  DayPhase = "Dusk"
+	PhaseNight DayPhase = "Night"
+)
+
+type Weather string
+
+const (
+	WeatherClear  Weather = "Clear"
+	WeatherCloudy Weather = "Cloudy"
+	WeatherRain   Weather = "Rain"
+	WeatherStorm  Weather = "Storm"
+)
+
+// Weathers runs from the best weather to the worst. Weather only ever moves
+// one step at a time.
+var Weathers = []Weather{WeatherClear, WeatherCloudy, WeatherRain, WeatherStorm}
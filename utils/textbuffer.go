@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+)
+
+// TextBuffer holds the lines of a piece of text while it is being edited.
+// Line numbers are 1 based, to match what's shown to the user.
+type TextBuffer struct {
+	lines []string
+}
+
+func NewTextBuffer(text string) *TextBuffer {
+	buffer := &TextBuffer{}
+	if text != "" {
+		buffer.lines = strings.Split(text, "\n")
+	}
+	return buffer
+}
+
+func (self *TextBuffer) Lines() []string {
+	lines := make([]string, len(self.lines))
+	copy(lines, self.lines)
+	return lines
+}
+
+func (self *TextBuffer) Len() int {
+	return len(self.lines)
+}
+
+func (self *TextBuffer) String() string {
+	return strings.Join(self.lines, "\n")
+}
+
+func (self *TextBuffer) Append(line string) {
+	self.lines = append(self.lines, line)
+}
+
+func (self *TextBuffer) checkLine(n int) error {
+	if n < 1 || n > len(self.lines) {
+		return errors.New("Line number out of range")
+	}
+	return nil
+}
+
+// Insert adds a line before line n. Inserting at one past the last line is
+// the same as appending.
+func (self *TextBuffer) Insert(n int, line string) error {
+	if n == len(self.lines)+1 {
+		self.Append(line)
+		return nil
+	}
+
+	if err := self.checkLine(n); err != nil {
+		return err
+	}
+
+	self.lines = append(self.lines, "")
+	copy(self.lines[n:], self.lines[n-1:])
+	self.lines[n-1] = line
+	return nil
+}
+
+func (self *TextBuffer) Delete(n int) error {
+	if err := self.checkLine(n); err != nil {
+		return err
+	}
+
+	self.lines = append(self.lines[:n-1], self.lines[n:]...)
+	return nil
+}
+
+func (self *TextBuffer) Replace(n int, line string) error {
+	if err := self.checkLine(n); err != nil {
+		return err
+	}
+
+	self.lines[n-1] = line
+	return nil
+}
+
+func (self *TextBuffer) Clear() {
+	self.lines = nil
+}
+
+// Format rewraps the text to the given width. Blank lines separate
+// paragraphs and are kept, while the lines within a paragraph are joined
+// before wrapping.
+func (self *TextBuffer) Format(width int) {
+	var formatted []string
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) > 0 {
+			formatted = append(formatted, WrapText(strings.Join(paragraph, " "), width)...)
+			paragraph = nil
+		}
+	}
+
+	for _, line := range self.lines {
+		if strings.TrimSpace(line) == "" {
+			flush()
+			formatted = append(formatted, "")
+		} else {
+			paragraph = append(paragraph, line)
+		}
+	}
+
+	flush()
+	self.lines = formatted
+}
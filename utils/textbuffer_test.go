@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_TextBuffer(t *testing.T) {
+	buffer := NewTextBuffer("")
+	if buffer.Len() != 0 {
+		t.Errorf("NewTextBuffer(\"\") has %v lines, want 0", buffer.Len())
+	}
+
+	buffer = NewTextBuffer("one\ntwo")
+	buffer.Append("four")
+
+	if err := buffer.Insert(3, "three"); err != nil {
+		t.Errorf("Insert(3) failed: %s", err)
+	}
+
+	if err := buffer.Insert(5, "five"); err != nil {
+		t.Errorf("Insert(5) failed: %s", err)
+	}
+
+	want := []string{"one", "two", "three", "four", "five"}
+	if !reflect.DeepEqual(buffer.Lines(), want) {
+		t.Errorf("Lines() == %q, want %q", buffer.Lines(), want)
+	}
+
+	buffer.Replace(1, "ONE")
+	buffer.Delete(2)
+
+	if buffer.String() != "ONE\nthree\nfour\nfive" {
+		t.Errorf("String() == %q", buffer.String())
+	}
+
+	for _, n := range []int{0, 5} {
+		if buffer.Delete(n) == nil {
+			t.Errorf("Delete(%v) succeeded on a %v line buffer", n, buffer.Len())
+		}
+		if buffer.Replace(n, "") == nil {
+			t.Errorf("Replace(%v) succeeded on a %v line buffer", n, buffer.Len())
+		}
+	}
+
+	if buffer.Insert(6, "") == nil {
+		t.Errorf("Insert(6) succeeded on a 4 line buffer")
+	}
+
+	buffer.Clear()
+	if buffer.Len() != 0 {
+		t.Errorf("Clear() left %v lines", buffer.Len())
+	}
+}
+
+func Test_TextBufferFormat(t *testing.T) {
+	buffer := NewTextBuffer("The quick brown\nfox jumps\n\nover the lazy dog")
+	buffer.Format(10)
+
+	want := []string{"The quick", "brown fox", "jumps", "", "over the", "lazy dog"}
+	if !reflect.DeepEqual(buffer.Lines(), want) {
+		t.Errorf("Format(10) == %q, want %q", buffer.Lines(), want)
+	}
+}
@@ -9,11 +9,13 @@ import (
 	"io"
 	"log"
 	"math/rand"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/Cristofori/kmud/types"
 )
@@ -327,6 +329,36 @@ func Random(low, high int) int {
 	return result
 }
 
+// WeightedRandom picks an index from the given list of weights, where the
+// chance of an index being picked is proportional to its weight. Returns -1 if
+// none of the weights are positive.
+func WeightedRandom(weights []int) int {
+	total := 0
+	for _, weight := range weights {
+		if weight > 0 {
+			total += weight
+		}
+	}
+
+	if total == 0 {
+		return -1
+	}
+
+	roll := Random(1, total)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+
+		roll -= weight
+		if roll <= 0 {
+			return i
+		}
+	}
+
+	panic("Unexpected code path")
+}
+
 func DirectionToExitString(direction types.Direction) string {
 	letterColor := types.ColorBlue
 	bracketColor := types.ColorDarkBlue
@@ -368,6 +400,128 @@ func DirectionToExitString(direction types.Direction) string {
 	panic("Unexpected code path")
 }
 
+// WrapText breaks the text into lines no longer than width, splitting at
+// spaces. Color codes don't count towards a line's length, and words longer
+// than width are given a line of their own.
+func WrapText(text string, width int) []string {
+	var lines []string
+	line := ""
+	length := 0
+
+	for _, word := range strings.Fields(text) {
+		wordLength := len(types.StripColors(word))
+
+		if length > 0 && length+1+wordLength > width {
+			lines = append(lines, line)
+			line = ""
+			length = 0
+		}
+
+		if length > 0 {
+			line += " "
+			length++
+		}
+
+		line += word
+		length += wordLength
+	}
+
+	if length > 0 || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// WrapLines wraps each of the lines in text to the given width using
+// WrapLine. Line endings are kept as they are, and wrapped lines are broken
+// with "\r\n".
+func WrapLines(text string, width int) string {
+	parts := strings.Split(text, "\n")
+
+	for i, part := range parts {
+		cr := strings.HasSuffix(part, "\r")
+		part = strings.TrimSuffix(part, "\r")
+
+		parts[i] = strings.Join(WrapLine(part, width), "\r\n")
+		if cr {
+			parts[i] += "\r"
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// WrapLine breaks a line that is wider than width at spaces. Unlike WrapText
+// the spacing within each piece is left alone, and the pieces after the first
+// are indented to match the original line. Color codes don't count towards the
+// width, and words that are too long to fit are left whole.
+func WrapLine(line string, width int) []string {
+	visible := types.StripColors(line)
+	if utf8.RuneCountInString(visible) <= width {
+		return []string{line}
+	}
+
+	indent := len(visible) - len(strings.TrimLeft(visible, " "))
+	if indent > width/2 {
+		indent = 0
+	}
+
+	var lines []string
+
+	for {
+		cut := breakPoint(line, width, indent)
+		if cut == -1 {
+			break
+		}
+
+		lines = append(lines, strings.TrimRight(line[:cut], " "))
+
+		rest := strings.TrimLeft(line[cut:], " ")
+		if rest == "" {
+			return lines
+		}
+
+		line = strings.Repeat(" ", indent) + rest
+	}
+
+	return append(lines, line)
+}
+
+// Returns the index of the space to break the line at, or -1 if the line
+// doesn't need breaking or has nowhere to break
+func breakPoint(line string, width int, indent int) int {
+	codes := types.ColorRegex.FindAllStringIndex(line, -1)
+	column := 0
+	lastSpace := -1
+
+	for i, r := range line {
+		for len(codes) > 0 && i >= codes[0][1] {
+			codes = codes[1:]
+		}
+
+		if len(codes) > 0 && i >= codes[0][0] {
+			continue
+		}
+
+		if r == ' ' && column > indent {
+			if column > width {
+				// Nothing fit, so break after the long word instead
+				return i
+			}
+			lastSpace = i
+		}
+
+		column++
+
+		if column > width && lastSpace != -1 {
+			return lastSpace
+		}
+	}
+
+	return -1
+}
+
 func Paginate(list []string, width, height int) []string {
 	itemLength := func(item string) int {
 		return len(types.StripColors(item))
@@ -507,3 +661,27 @@ func Filter(list []string, pattern string) []string {
 func FilterItem(item, pattern string) bool {
 	return strings.Contains(strings.ToLower(types.StripColors(item)), strings.ToLower(pattern))
 }
+
+// ValidAddressPattern returns true if the pattern is a single IP address or a
+// range in CIDR notation
+func ValidAddressPattern(pattern string) bool {
+	if _, _, err := net.ParseCIDR(pattern); err == nil {
+		return true
+	}
+	return net.ParseIP(pattern) != nil
+}
+
+// AddressMatches returns true if the IP address matches the pattern, which is
+// either a single IP address or a range in CIDR notation
+func AddressMatches(pattern string, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	if _, network, err := net.ParseCIDR(pattern); err == nil {
+		return network.Contains(ip)
+	}
+
+	return ip.Equal(net.ParseIP(pattern))
+}
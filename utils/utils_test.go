@@ -342,6 +342,37 @@ func Test_Random(t *testing.T) {
 	}
 }
 
+func Test_WeightedRandom(t *testing.T) {
+	tests := []struct {
+		weights []int
+		allowed []int
+	}{
+		{[]int{}, []int{-1}},
+		{[]int{0, 0}, []int{-1}},
+		{[]int{0, 5}, []int{1}},
+		{[]int{3, -1, 0, 2}, []int{0, 3}},
+		{[]int{1, 1, 1}, []int{0, 1, 2}},
+	}
+
+	for i := 0; i < 100; i++ {
+		rand.Seed(int64(i))
+		for _, test := range tests {
+			result := WeightedRandom(test.weights)
+
+			found := false
+			for _, allowed := range test.allowed {
+				if result == allowed {
+					found = true
+				}
+			}
+
+			if !found {
+				t.Errorf("WeightedRandom(%v) == %v, expected one of %v", test.weights, result, test.allowed)
+			}
+		}
+	}
+}
+
 func Test_Atois(t *testing.T) {
 	tests := []struct {
 		input  []string
@@ -467,3 +498,109 @@ func Test_Bound(t *testing.T) {
 		}
 	}
 }
+
+func Test_ValidAddressPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		output  bool
+	}{
+		{"10.0.0.1", true},
+		{"10.0.0.0/24", true},
+		{"::1", true},
+		{"2001:db8::/32", true},
+		{"10.0.0.256", false},
+		{"10.0.0.0/33", false},
+		{"garbage", false},
+		{"", false},
+	}
+
+	for _, test := range tests {
+		result := ValidAddressPattern(test.pattern)
+		if result != test.output {
+			t.Errorf("ValidAddressPattern(%s) == %v, want %v", test.pattern, result, test.output)
+		}
+	}
+}
+
+func Test_AddressMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		address string
+		output  bool
+	}{
+		{"10.0.0.1", "10.0.0.1", true},
+		{"10.0.0.1", "10.0.0.2", false},
+		{"10.0.0.0/24", "10.0.0.200", true},
+		{"10.0.0.0/24", "10.0.1.1", false},
+		{"10.0.0.0/8", "10.200.3.4", true},
+		{"::1", "::1", true},
+		{"10.0.0.1", "not an address", false},
+		{"garbage", "10.0.0.1", false},
+	}
+
+	for _, test := range tests {
+		result := AddressMatches(test.pattern, test.address)
+		if result != test.output {
+			t.Errorf("AddressMatches(%s, %s) == %v, want %v", test.pattern, test.address, result, test.output)
+		}
+	}
+}
+
+func Test_WrapText(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  []string
+	}{
+		{"", 10, []string{""}},
+		{"one two three", 20, []string{"one two three"}},
+		{"one two three", 7, []string{"one two", "three"}},
+		{"one   two\nthree", 9, []string{"one two", "three"}},
+		{"a verylongword b", 5, []string{"a", "verylongword", "b"}},
+		{types.Colorize(types.ColorRed, "red") + " text", 8, []string{types.Colorize(types.ColorRed, "red") + " text"}},
+	}
+
+	for _, test := range tests {
+		got := WrapText(test.text, test.width)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WrapText(%q, %v) == %q, want %q", test.text, test.width, got, test.want)
+		}
+	}
+}
+
+func Test_WrapLine(t *testing.T) {
+	red := string(types.ColorRed)
+	normal := string(types.ColorNormal)
+
+	tests := []struct {
+		line  string
+		width int
+		want  []string
+	}{
+		{"short line", 20, []string{"short line"}},
+		{"one two three four", 9, []string{"one two", "three", "four"}},
+		{"  indented text that wraps", 16, []string{"  indented text", "  that wraps"}},
+		{"keeps  inner  spacing here", 14, []string{"keeps  inner", "spacing here"}},
+		{red + "colored" + normal + " words fit", 17, []string{red + "colored" + normal + " words fit"}},
+		{red + "colored" + normal + " words wrap", 10, []string{red + "colored" + normal, "words wrap"}},
+		{"averyveryverylongword and more", 10, []string{"averyveryverylongword", "and more"}},
+		{"trailing spaces          ", 15, []string{"trailing spaces"}},
+	}
+
+	for _, test := range tests {
+		got := WrapLine(test.line, test.width)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("WrapLine(%q, %v) == %q, want %q", test.line, test.width, got, test.want)
+		}
+	}
+}
+
+func Test_WrapLines(t *testing.T) {
+	text := "first line is long\r\nshort\r\n"
+	want := "first line\r\nis long\r\nshort\r\n"
+
+	got := WrapLines(text, 10)
+	if got != want {
+		t.Errorf("WrapLines(%q, 10) == %q, want %q", text, got, want)
+	}
+}
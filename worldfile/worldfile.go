@@ -0,0 +1,1042 @@
+// Package worldfile converts zones to and from a versioned JSON format so that
+// they can be reviewed in version control and moved between servers.
+//
+// Objects in a file refer to each other by the ids they had when they were
+// exported. Those ids are only used to link things together within the file;
+// importing creates new objects and remaps every reference.
+package worldfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Cristofori/kmud/model"
+	"github.com/Cristofori/kmud/types"
+	"github.com/Cristofori/kmud/utils"
+	"github.com/Cristofori/kmud/utils/naturalsort"
+)
+
+// Dir is where the server reads and writes world files
+var Dir = "world"
+
+// Path returns the location in Dir of the world file with the given name.
+// Anything other than the base name is stripped so that callers can't reach
+// outside of Dir.
+func Path(name string) string {
+	name = filepath.Base(name)
+	if filepath.Ext(name) == "" {
+		name = name + ".json"
+	}
+	return filepath.Join(Dir, name)
+}
+
+// Version is written to every file. Bump it whenever the format changes in a
+// way that older servers can't read.
+const Version = 1
+
+type File struct {
+	Version   int
+	Zone      string
+	Areas     []Area     `json:",omitempty"`
+	Rooms     []Room     `json:",omitempty"`
+	Templates []Template `json:",omitempty"`
+	Skills    []Skill    `json:",omitempty"`
+	Effects   []Effect   `json:",omitempty"`
+	Spawners  []Spawner  `json:",omitempty"`
+	Stores    []Store    `json:",omitempty"`
+}
+
+type Area struct {
+	Id   string
+	Name string
+}
+
+type Room struct {
+	Id               string
+	Location         types.Coordinate
+	Area             string `json:",omitempty"`
+	Title            string
+	Description      string
+	NightDescription string            `json:",omitempty"`
+	Exits            []types.Direction `json:",omitempty"`
+	Locked           []types.Direction `json:",omitempty"`
+	Doors            []Door            `json:",omitempty"`
+	Hidden           []types.Direction `json:",omitempty"`
+	Limits           []Limit           `json:",omitempty"`
+	Links            map[string]string `json:",omitempty"`
+	Extras           map[string]string `json:",omitempty"`
+	Actions          []RoomAction      `json:",omitempty"`
+	Script           string            `json:",omitempty"`
+	Flags            []types.RoomFlag  `json:",omitempty"`
+	Terrain          types.Terrain     `json:",omitempty"`
+	Cash             int               `json:",omitempty"`
+	Items            []Item            `json:",omitempty"`
+}
+
+// Item is an item lying in the room, made from a template in the file, along
+// with the templates of whatever is inside of it
+type Item struct {
+	Template string
+	Contents []string `json:",omitempty"`
+}
+
+// Door is a types.Door on one of the room's exits, keyed by a template in
+// the file
+type Door struct {
+	Direction  types.Direction
+	Name       string
+	Key        string `json:",omitempty"`
+	Difficulty int    `json:",omitempty"`
+	Closed     bool   `json:",omitempty"`
+}
+
+// Limit is a types.ExitRestriction on one of the room's exits, with
+// the skill and template given by their ids in the file
+type Limit struct {
+	Direction     types.Direction
+	MinExperience int    `json:",omitempty"`
+	Skill         string `json:",omitempty"`
+	Template      string `json:",omitempty"`
+}
+
+// RoomResponse is a types.RoomResponse whose target is the id of a room,
+// template or effect in the file
+type RoomResponse struct {
+	Kind        types.ResponseKind
+	Message     string          `json:",omitempty"`
+	RoomMessage string          `json:",omitempty"`
+	Direction   types.Direction `json:",omitempty"`
+	Target      string          `json:",omitempty"`
+}
+
+type RoomAction struct {
+	Trigger   types.ActionTrigger
+	Command   string         `json:",omitempty"`
+	Responses []RoomResponse `json:",omitempty"`
+}
+
+type Template struct {
+	Id       string
+	Name     string
+	Value    int
+	Weight   int
+	Capacity int
+	Category string            `json:",omitempty"`
+	Extras   map[string]string `json:",omitempty"`
+	Script   string            `json:",omitempty"`
+	Light    bool              `json:",omitempty"`
+}
+
+type Skill struct {
+	Id      string
+	Name    string
+	Effects []string `json:",omitempty"`
+}
+
+type Effect struct {
+	Id       string
+	Name     string
+	Type     types.EffectKind
+	Power    int
+	Cost     int
+	Variance int
+	Speed    int
+	Time     int
+}
+
+type LootDrop struct {
+	Template   string
+	Weight     int
+	Guaranteed bool
+}
+
+type Loot struct {
+	Drops   []LootDrop `json:",omitempty"`
+	Rolls   int
+	MinCash int
+	MaxCash int
+	OnDeath bool
+}
+
+type Spawner struct {
+	Id           string
+	Name         string
+	Area         string
+	Health       int
+	Strength     int
+	Cash         int
+	Count        int
+	Skills       []string `json:",omitempty"`
+	Loot         Loot
+	Roaming      bool
+	Conversation string   `json:",omitempty"`
+	Script       string   `json:",omitempty"`
+	Equipment    []string `json:",omitempty"`
+	RespawnDelay int
+	Rooms        []string `json:",omitempty"`
+	MaxPerRoom   int
+	Schedule     types.SpawnSchedule
+	Paused       bool
+}
+
+type StockEntry struct {
+	Template string
+	Quantity int
+	Interval int
+}
+
+type Store struct {
+	Id         string
+	Name       string
+	Room       string
+	Cash       int
+	Stock      []StockEntry `json:",omitempty"`
+	BuyRate    int
+	SellRate   int
+	SpendLimit int
+	Categories []string `json:",omitempty"`
+}
+
+// Conflict describes an object in a file which has the same name as one that
+// already exists on this server
+type Conflict struct {
+	Type types.ObjectType
+	Name string
+}
+
+func (self Conflict) String() string {
+	return fmt.Sprintf("%s '%s' already exists", self.Type, self.Name)
+}
+
+func hex(id types.Id) string {
+	if id == nil {
+		return ""
+	}
+	return id.Hex()
+}
+
+// Export builds a file from the zone along with every area, room, spawner and
+// store in it, and the templates, skills and effects that those depend on.
+// Links and spawner rooms which lead outside of the zone can't be exported,
+// and a warning is returned for each one that was left out.
+func Export(zone types.Zone) (*File, []string) {
+	file := &File{Version: Version, Zone: zone.GetName()}
+	var warnings []string
+
+	rooms := model.GetRoomsInZone(zone.GetId())
+	inZone := map[string]bool{}
+	for _, room := range rooms {
+		inZone[hex(room.GetId())] = true
+	}
+
+	templates := map[string]types.Template{}
+	skills := map[string]types.Skill{}
+	effects := map[string]types.Effect{}
+
+	// These return the id to write to the file, or an empty string if the
+	// object has since been deleted
+	addTemplate := func(id types.Id) string {
+		template := model.GetTemplate(id)
+		if template == nil {
+			return ""
+		}
+		templates[hex(id)] = template
+		return hex(id)
+	}
+
+	addEffect := func(id types.Id) string {
+		effect := model.GetEffect(id)
+		if effect == nil {
+			return ""
+		}
+		effects[hex(id)] = effect
+		return hex(id)
+	}
+
+	addSkill := func(id types.Id) string {
+		skill := model.GetSkill(id)
+		if skill == nil {
+			return ""
+		}
+		skills[hex(id)] = skill
+		for _, effectId := range skill.GetEffects() {
+			addEffect(effectId)
+		}
+		return hex(id)
+	}
+
+	areas := map[string]bool{}
+
+	for _, area := range model.GetAreas(zone) {
+		areas[hex(area.GetId())] = true
+		file.Areas = append(file.Areas, Area{Id: hex(area.GetId()), Name: area.GetName()})
+
+		for _, spawner := range model.GetAreaSpawners(area.GetId()) {
+			file.Spawners = append(file.Spawners, exportSpawner(spawner, inZone, addSkill, addTemplate, &warnings))
+		}
+	}
+
+	for _, room := range rooms {
+		r := Room{
+			Id:               hex(room.GetId()),
+			Location:         room.GetLocation(),
+			Title:            room.GetTitle(),
+			Description:      room.GetDescription(),
+			NightDescription: room.GetNightDescription(),
+			Exits:            room.GetExits(),
+			Script:           room.GetScript(),
+			Flags:            room.GetFlags(),
+			Terrain:          room.GetTerrain(),
+			Cash:             room.GetCash(),
+		}
+
+		if extras := room.GetExtraDescriptions(); len(extras) > 0 {
+			r.Extras = extras
+		}
+
+		if areas[hex(room.GetAreaId())] {
+			r.Area = hex(room.GetAreaId())
+		}
+
+		for _, dir := range r.Exits {
+			if room.IsLocked(dir) {
+				r.Locked = append(r.Locked, dir)
+			}
+
+			if door, found := room.GetDoor(dir); found {
+				d := Door{Direction: dir, Name: door.Name, Difficulty: door.Difficulty, Closed: room.IsClosed(dir)}
+				if door.KeyId != nil {
+					d.Key = addTemplate(door.KeyId)
+				}
+				r.Doors = append(r.Doors, d)
+			}
+
+			if room.IsHidden(dir) {
+				r.Hidden = append(r.Hidden, dir)
+			}
+
+			if restriction := room.GetRestriction(dir); !restriction.IsEmpty() {
+				l := Limit{Direction: dir, MinExperience: restriction.MinExperience}
+				if restriction.SkillId != nil {
+					l.Skill = addSkill(restriction.SkillId)
+				}
+				if restriction.TemplateId != nil {
+					l.Template = addTemplate(restriction.TemplateId)
+				}
+				r.Limits = append(r.Limits, l)
+			}
+		}
+
+		for _, item := range model.ItemsIn(room.GetId()) {
+			i := Item{Template: addTemplate(item.GetTemplateId())}
+			if i.Template == "" {
+				continue
+			}
+
+			for _, content := range model.ItemsIn(item.GetId()) {
+				if template := addTemplate(content.GetTemplateId()); template != "" {
+					i.Contents = append(i.Contents, template)
+				}
+			}
+			r.Items = append(r.Items, i)
+		}
+
+		for name, id := range room.GetLinks() {
+			if !inZone[hex(id)] {
+				warnings = append(warnings, fmt.Sprintf("Link '%s' in room %v leads out of the zone", name, r.Location))
+				continue
+			}
+
+			if r.Links == nil {
+				r.Links = map[string]string{}
+			}
+			r.Links[name] = hex(id)
+		}
+
+		for _, action := range room.GetActions() {
+			a := RoomAction{Trigger: action.Trigger, Command: action.Command}
+
+			for _, response := range action.Responses {
+				target := ""
+
+				switch response.Kind {
+				case types.ResponseTeleport:
+					target = hex(response.Target)
+					if !inZone[target] {
+						warnings = append(warnings, fmt.Sprintf("Action '%s' in room %v teleports out of the zone", action, r.Location))
+						continue
+					}
+				case types.ResponseItem:
+					if target = addTemplate(response.Target); target == "" {
+						continue
+					}
+				case types.ResponseEffect:
+					if target = addEffect(response.Target); target == "" {
+						continue
+					}
+				}
+
+				a.Responses = append(a.Responses, RoomResponse{
+					Kind:        response.Kind,
+					Message:     response.Message,
+					RoomMessage: response.RoomMessage,
+					Direction:   response.Direction,
+					Target:      target,
+				})
+			}
+
+			r.Actions = append(r.Actions, a)
+		}
+
+		file.Rooms = append(file.Rooms, r)
+
+		if store := model.StoreIn(room.GetId()); store != nil {
+			s := Store{
+				Id:         hex(store.GetId()),
+				Name:       store.GetName(),
+				Room:       r.Id,
+				Cash:       store.GetCash(),
+				BuyRate:    store.GetBuyRate(),
+				SellRate:   store.GetSellRate(),
+				SpendLimit: store.GetSpendLimit(),
+				Categories: store.GetCategories(),
+			}
+
+			for _, entry := range store.GetStock() {
+				if id := addTemplate(entry.TemplateId); id != "" {
+					s.Stock = append(s.Stock, StockEntry{
+						Template: id,
+						Quantity: entry.Quantity,
+						Interval: entry.Interval,
+					})
+				}
+			}
+
+			file.Stores = append(file.Stores, s)
+		}
+	}
+
+	for id, template := range templates {
+		t := Template{
+			Id:       id,
+			Name:     template.GetName(),
+			Value:    template.GetValue(),
+			Weight:   template.GetWeight(),
+			Capacity: template.GetCapacity(),
+			Category: template.GetCategory(),
+			Script:   template.GetScript(),
+			Light:    template.IsLight(),
+		}
+
+		if extras := template.GetExtraDescriptions(); len(extras) > 0 {
+			t.Extras = extras
+		}
+
+		file.Templates = append(file.Templates, t)
+	}
+
+	for id, skill := range skills {
+		s := Skill{Id: id, Name: skill.GetName()}
+		for _, effectId := range skill.GetEffects() {
+			if _, found := effects[hex(effectId)]; found {
+				s.Effects = append(s.Effects, hex(effectId))
+			}
+		}
+		file.Skills = append(file.Skills, s)
+	}
+
+	for id, effect := range effects {
+		file.Effects = append(file.Effects, Effect{
+			Id:       id,
+			Name:     effect.GetName(),
+			Type:     effect.GetType(),
+			Power:    effect.GetPower(),
+			Cost:     effect.GetCost(),
+			Variance: effect.GetVariance(),
+			Speed:    effect.GetSpeed(),
+			Time:     effect.GetTime(),
+		})
+	}
+
+	file.sort()
+	return file, warnings
+}
+
+func exportSpawner(spawner types.Spawner, inZone map[string]bool, addSkill, addTemplate func(types.Id) string, warnings *[]string) Spawner {
+	loot := spawner.GetLoot()
+
+	s := Spawner{
+		Id:           hex(spawner.GetId()),
+		Name:         spawner.GetName(),
+		Area:         hex(spawner.GetAreaId()),
+		Health:       spawner.GetHealth(),
+		Strength:     spawner.GetStrength(),
+		Cash:         spawner.GetCash(),
+		Count:        spawner.GetCount(),
+		Roaming:      spawner.GetRoaming(),
+		Conversation: spawner.GetConversation(),
+		Script:       spawner.GetScript(),
+		RespawnDelay: spawner.GetRespawnDelay(),
+		MaxPerRoom:   spawner.GetMaxPerRoom(),
+		Schedule:     spawner.GetSchedule(),
+		Paused:       spawner.IsPaused(),
+		Loot: Loot{
+			Rolls:   loot.Rolls,
+			MinCash: loot.MinCash,
+			MaxCash: loot.MaxCash,
+			OnDeath: loot.OnDeath,
+		},
+	}
+
+	for _, drop := range loot.Drops {
+		if id := addTemplate(drop.TemplateId); id != "" {
+			s.Loot.Drops = append(s.Loot.Drops, LootDrop{
+				Template:   id,
+				Weight:     drop.Weight,
+				Guaranteed: drop.Guaranteed,
+			})
+		}
+	}
+
+	for _, skillId := range spawner.GetSkills() {
+		if id := addSkill(skillId); id != "" {
+			s.Skills = append(s.Skills, id)
+		}
+	}
+
+	for _, templateId := range spawner.GetEquipment() {
+		if id := addTemplate(templateId); id != "" {
+			s.Equipment = append(s.Equipment, id)
+		}
+	}
+
+	for _, id := range spawner.GetRoomIds() {
+		if inZone[hex(id)] {
+			s.Rooms = append(s.Rooms, hex(id))
+		} else {
+			*warnings = append(*warnings, fmt.Sprintf("Spawner %s uses a room outside of the zone", s.Name))
+		}
+	}
+
+	sort.Strings(s.Rooms)
+	return s
+}
+
+// Sorts everything in the file so that exporting the same zone twice produces
+// identical output
+func (self *File) sort() {
+	sort.Slice(self.Areas, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Areas[i].Name, self.Areas[j].Name)
+	})
+
+	sort.Slice(self.Rooms, func(i, j int) bool {
+		a, b := self.Rooms[i].Location, self.Rooms[j].Location
+		if a.Z != b.Z {
+			return a.Z < b.Z
+		}
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+
+	sort.Slice(self.Templates, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Templates[i].Name, self.Templates[j].Name)
+	})
+
+	sort.Slice(self.Skills, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Skills[i].Name, self.Skills[j].Name)
+	})
+
+	sort.Slice(self.Effects, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Effects[i].Name, self.Effects[j].Name)
+	})
+
+	sort.Slice(self.Spawners, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Spawners[i].Name, self.Spawners[j].Name)
+	})
+
+	sort.Slice(self.Stores, func(i, j int) bool {
+		return naturalsort.NaturalLessThan(self.Stores[i].Name, self.Stores[j].Name)
+	})
+}
+
+// Conflicts lists the objects in the file whose names are already taken. A
+// zone or area conflict prevents the file from being imported. Templates,
+// skills and effects that already exist are reused rather than recreated.
+func Conflicts(file *File, zoneName string) []Conflict {
+	var conflicts []Conflict
+
+	if zoneName == "" {
+		zoneName = file.Zone
+	}
+
+	if model.GetZoneByName(zoneName) != nil {
+		conflicts = append(conflicts, Conflict{types.ZoneType, zoneName})
+	}
+
+	for _, area := range file.Areas {
+		if model.GetAreaByName(area.Name) != nil {
+			conflicts = append(conflicts, Conflict{types.AreaType, area.Name})
+		}
+	}
+
+	for _, template := range file.Templates {
+		if model.GetTemplateByName(template.Name) != nil {
+			conflicts = append(conflicts, Conflict{types.TemplateType, template.Name})
+		}
+	}
+
+	for _, skill := range file.Skills {
+		if model.GetSkillByName(skill.Name) != nil {
+			conflicts = append(conflicts, Conflict{types.SkillType, skill.Name})
+		}
+	}
+
+	for _, effect := range file.Effects {
+		if model.GetEffectByName(effect.Name) != nil {
+			conflicts = append(conflicts, Conflict{types.EffectType, effect.Name})
+		}
+	}
+
+	return conflicts
+}
+
+// Validate checks that every reference in the file is to an object which is
+// also in the file, that ids and names aren't used twice, and that no two
+// rooms share a location
+func (self *File) Validate() error {
+	var names []Conflict
+	for _, e := range self.Effects {
+		names = append(names, Conflict{types.EffectType, e.Name})
+	}
+	for _, s := range self.Skills {
+		names = append(names, Conflict{types.SkillType, s.Name})
+	}
+	for _, t := range self.Templates {
+		names = append(names, Conflict{types.TemplateType, t.Name})
+	}
+	for _, a := range self.Areas {
+		names = append(names, Conflict{types.AreaType, a.Name})
+	}
+
+	// Objects are matched up with the ones on the server by name, so two
+	// with the same name would end up as one
+	seen := map[Conflict]bool{}
+	for _, name := range names {
+		name.Name = utils.FormatName(name.Name)
+		if seen[name] {
+			return fmt.Errorf("More than one %s named '%s'", strings.ToLower(string(name.Type)), name.Name)
+		}
+		seen[name] = true
+	}
+
+	var ids []string
+	for _, e := range self.Effects {
+		ids = append(ids, e.Id)
+	}
+	for _, s := range self.Skills {
+		ids = append(ids, s.Id)
+	}
+	for _, t := range self.Templates {
+		ids = append(ids, t.Id)
+	}
+	for _, a := range self.Areas {
+		ids = append(ids, a.Id)
+	}
+
+	locations := map[types.Coordinate]bool{}
+	for _, r := range self.Rooms {
+		if locations[r.Location] {
+			return fmt.Errorf("More than one room at %v", r.Location)
+		}
+		locations[r.Location] = true
+		ids = append(ids, r.Id)
+	}
+
+	known := map[string]bool{}
+	for _, id := range ids {
+		if known[id] {
+			return fmt.Errorf("More than one object with id %s", id)
+		}
+		known[id] = true
+	}
+
+	var refs []string
+	for _, s := range self.Skills {
+		refs = append(refs, s.Effects...)
+	}
+	for _, r := range self.Rooms {
+		if r.Area != "" {
+			refs = append(refs, r.Area)
+		}
+		for _, target := range r.Links {
+			refs = append(refs, target)
+		}
+		for _, door := range r.Doors {
+			if door.Key != "" {
+				refs = append(refs, door.Key)
+			}
+		}
+		for _, item := range r.Items {
+			refs = append(refs, item.Template)
+			refs = append(refs, item.Contents...)
+		}
+		for _, limit := range r.Limits {
+			if limit.Skill != "" {
+				refs = append(refs, limit.Skill)
+			}
+			if limit.Template != "" {
+				refs = append(refs, limit.Template)
+			}
+		}
+		for _, action := range r.Actions {
+			for _, response := range action.Responses {
+				if response.Target != "" {
+					refs = append(refs, response.Target)
+				}
+			}
+		}
+	}
+	for _, s := range self.Spawners {
+		refs = append(refs, s.Area)
+		refs = append(refs, s.Skills...)
+		refs = append(refs, s.Equipment...)
+		refs = append(refs, s.Rooms...)
+		for _, drop := range s.Loot.Drops {
+			refs = append(refs, drop.Template)
+		}
+	}
+	for _, s := range self.Stores {
+		refs = append(refs, s.Room)
+		for _, entry := range s.Stock {
+			refs = append(refs, entry.Template)
+		}
+	}
+
+	for _, ref := range refs {
+		if !known[ref] {
+			return fmt.Errorf("Reference to unknown object: %s", ref)
+		}
+	}
+
+	return nil
+}
+
+// Import creates the contents of the file as a new zone. If zoneName is empty
+// the zone keeps the name it was exported with. Nothing is created if the
+// file is invalid or has a zone or area conflict.
+func Import(file *File, zoneName string) (types.Zone, error) {
+	if file.Version != Version {
+		return nil, fmt.Errorf("Unsupported world file version: %v", file.Version)
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+
+	if zoneName == "" {
+		zoneName = file.Zone
+	}
+
+	for _, conflict := range Conflicts(file, zoneName) {
+		if conflict.Type == types.ZoneType || conflict.Type == types.AreaType {
+			return nil, fmt.Errorf("%s", conflict)
+		}
+	}
+
+	zone, err := model.CreateZone(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := map[string]types.Id{}
+
+	for _, e := range file.Effects {
+		if existing := model.GetEffectByName(e.Name); existing != nil {
+			ids[e.Id] = existing.GetId()
+			continue
+		}
+
+		effect := model.CreateEffect(e.Name)
+		effect.SetType(e.Type)
+		effect.SetPower(e.Power)
+		effect.SetCost(e.Cost)
+		effect.SetVariance(e.Variance)
+		effect.SetSpeed(e.Speed)
+		effect.SetTime(e.Time)
+		ids[e.Id] = effect.GetId()
+	}
+
+	for _, s := range file.Skills {
+		if existing := model.GetSkillByName(s.Name); existing != nil {
+			ids[s.Id] = existing.GetId()
+			continue
+		}
+
+		skill := model.CreateSkill(s.Name)
+		for _, effectId := range s.Effects {
+			skill.AddEffect(ids[effectId])
+		}
+		ids[s.Id] = skill.GetId()
+	}
+
+	for _, t := range file.Templates {
+		if existing := model.GetTemplateByName(t.Name); existing != nil {
+			ids[t.Id] = existing.GetId()
+			continue
+		}
+
+		template := model.CreateTemplate(t.Name)
+		template.SetValue(t.Value)
+		template.SetWeight(t.Weight)
+		template.SetCapacity(t.Capacity)
+		template.SetCategory(t.Category)
+		template.SetScript(t.Script)
+		template.SetLight(t.Light)
+
+		for keyword, description := range t.Extras {
+			template.SetExtraDescription(keyword, description)
+		}
+		ids[t.Id] = template.GetId()
+	}
+
+	var areas []types.Id
+
+	// Undoes the zone and its areas if they can't all be created, which
+	// shouldn't happen to a valid file
+	abort := func(err error) (types.Zone, error) {
+		for _, id := range areas {
+			model.DeleteArea(id)
+		}
+		model.DeleteZone(zone.GetId())
+		return nil, err
+	}
+
+	for _, a := range file.Areas {
+		area, err := model.CreateArea(a.Name, zone)
+		if err != nil {
+			return abort(err)
+		}
+		ids[a.Id] = area.GetId()
+		areas = append(areas, area.GetId())
+	}
+
+	rooms := map[string]types.Room{}
+
+	for _, r := range file.Rooms {
+		room, err := model.CreateRoom(zone, r.Location)
+		if err != nil {
+			return abort(err)
+		}
+
+		ids[r.Id] = room.GetId()
+		rooms[r.Id] = room
+
+		if r.Area != "" {
+			room.SetAreaId(ids[r.Area])
+		}
+
+		room.SetTitle(r.Title)
+		room.SetDescription(r.Description)
+		room.SetNightDescription(r.NightDescription)
+		room.SetScript(r.Script)
+		room.SetTerrain(r.Terrain)
+		room.AddCash(r.Cash)
+
+		for _, flag := range r.Flags {
+			room.SetFlag(flag, true)
+		}
+
+		for keyword, description := range r.Extras {
+			room.SetExtraDescription(keyword, description)
+		}
+
+		for _, i := range r.Items {
+			item := model.CreateItem(ids[i.Template])
+			item.SetContainerId(room.GetId(), nil)
+
+			for _, templateId := range i.Contents {
+				content := model.CreateItem(ids[templateId])
+				content.SetContainerId(item.GetId(), nil)
+			}
+		}
+
+		for _, dir := range r.Exits {
+			room.SetExitEnabled(dir, true)
+		}
+
+		for _, d := range r.Doors {
+			door := types.Door{Name: d.Name, Difficulty: d.Difficulty}
+			if d.Key != "" {
+				door.KeyId = ids[d.Key]
+			}
+			room.SetDoor(d.Direction, door)
+			room.SetClosed(d.Direction, d.Closed)
+		}
+
+		for _, dir := range r.Locked {
+			if _, found := room.GetDoor(dir); !found {
+				// Written before exits had doors
+				room.SetDoor(dir, types.DefaultDoor)
+			}
+			room.SetLocked(dir, true)
+		}
+
+		for _, dir := range r.Hidden {
+			room.SetHidden(dir, true)
+		}
+
+		for _, l := range r.Limits {
+			restriction := types.ExitRestriction{MinExperience: l.MinExperience}
+			if l.Skill != "" {
+				restriction.SkillId = ids[l.Skill]
+			}
+			if l.Template != "" {
+				restriction.TemplateId = ids[l.Template]
+			}
+			room.SetRestriction(l.Direction, restriction)
+		}
+	}
+
+	// Links and actions are set once every room exists since they can point
+	// anywhere in the zone
+	for _, r := range file.Rooms {
+		for name, target := range r.Links {
+			rooms[r.Id].SetLink(name, ids[target])
+		}
+
+		for _, a := range r.Actions {
+			action := types.RoomAction{Trigger: a.Trigger, Command: a.Command}
+			for _, response := range a.Responses {
+				var target types.Id
+				if response.Target != "" {
+					target = ids[response.Target]
+				}
+
+				action.Responses = append(action.Responses, types.RoomResponse{
+					Kind:        response.Kind,
+					Message:     response.Message,
+					RoomMessage: response.RoomMessage,
+					Direction:   response.Direction,
+					Target:      target,
+				})
+			}
+			rooms[r.Id].AddAction(action)
+		}
+	}
+
+	for _, s := range file.Spawners {
+		importSpawner(s, ids)
+	}
+
+	for _, s := range file.Stores {
+		store := model.CreateStore(s.Name, ids[s.Room])
+		store.AddCash(s.Cash)
+		store.SetBuyRate(s.BuyRate)
+		store.SetSellRate(s.SellRate)
+		store.SetSpendLimit(s.SpendLimit)
+
+		for _, category := range s.Categories {
+			store.AddCategory(category)
+		}
+
+		for _, entry := range s.Stock {
+			store.SetStock(ids[entry.Template], entry.Quantity, entry.Interval)
+		}
+	}
+
+	return zone, nil
+}
+
+func importSpawner(s Spawner, ids map[string]types.Id) {
+	spawner := model.CreateSpawner(s.Name, ids[s.Area])
+	spawner.SetHealth(s.Health)
+	spawner.SetStrength(s.Strength)
+	spawner.AddCash(s.Cash)
+	spawner.SetCount(s.Count)
+	spawner.SetRoaming(s.Roaming)
+	spawner.SetConversation(s.Conversation)
+	spawner.SetScript(s.Script)
+	spawner.SetRespawnDelay(s.RespawnDelay)
+	spawner.SetMaxPerRoom(s.MaxPerRoom)
+	spawner.SetSchedule(s.Schedule)
+	spawner.SetPaused(s.Paused)
+
+	loot := types.LootTable{
+		Rolls:   s.Loot.Rolls,
+		MinCash: s.Loot.MinCash,
+		MaxCash: s.Loot.MaxCash,
+		OnDeath: s.Loot.OnDeath,
+	}
+
+	for _, drop := range s.Loot.Drops {
+		loot.Drops = append(loot.Drops, types.LootDrop{
+			TemplateId: ids[drop.Template],
+			Weight:     drop.Weight,
+			Guaranteed: drop.Guaranteed,
+		})
+	}
+
+	spawner.SetLoot(loot)
+
+	for _, id := range s.Skills {
+		spawner.AddSkill(ids[id])
+	}
+
+	for _, id := range s.Equipment {
+		spawner.AddEquipment(ids[id])
+	}
+
+	for _, id := range s.Rooms {
+		spawner.AddRoom(ids[id])
+	}
+}
+
+func Write(w io.Writer, file *File) error {
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func Read(r io.Reader) (*File, error) {
+	var file File
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	if file.Version != Version {
+		return nil, fmt.Errorf("Unsupported world file version: %v", file.Version)
+	}
+
+	return &file, nil
+}
+
+func Save(path string, file *File) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return Write(f, file)
+}
+
+func Load(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Read(f)
+}
@@ -0,0 +1,122 @@
+package worldfile
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Cristofori/kmud/types"
+)
+
+func testFile() *File {
+	return &File{
+		Version: Version,
+		Zone:    "Town",
+		Areas:   []Area{{Id: "a1", Name: "Market"}},
+		Rooms: []Room{
+			{Id: "r1", Location: types.Coordinate{X: 0, Y: 0, Z: 0}, Area: "a1", Title: "Square",
+				Extras: map[string]string{"fountain": "A stone fountain."},
+				Exits:  []types.Direction{types.DirectionEast}, Links: map[string]string{"well": "r2"},
+				Hidden: []types.Direction{types.DirectionEast},
+				Items:  []Item{{Template: "t1"}, {Template: "t1", Contents: []string{"t1"}}},
+				Limits: []Limit{{Direction: types.DirectionEast, MinExperience: 10, Skill: "s1", Template: "t1"}},
+				Actions: []RoomAction{{Trigger: types.TriggerCommand, Command: "jump in", Responses: []RoomResponse{
+					{Kind: types.ResponseMessage, Message: "You jump into the well"},
+					{Kind: types.ResponseTeleport, Target: "r2"},
+					{Kind: types.ResponseEffect, Target: "e1"}}}}},
+			{Id: "r2", Location: types.Coordinate{X: 1, Y: 0, Z: 0}, Title: "Well", NightDescription: "Stars glint in the water.",
+				Flags: []types.RoomFlag{types.RoomDark, types.RoomNoRecall}, Terrain: types.TerrainWater,
+				Exits: []types.Direction{types.DirectionWest}, Locked: []types.Direction{types.DirectionWest},
+				Doors: []Door{{Direction: types.DirectionWest, Name: "gate", Key: "t1", Difficulty: 40, Closed: true}}},
+		},
+		Templates: []Template{{Id: "t1", Name: "Sword", Value: 10, Weight: 5, Script: "hums = true"}},
+		Skills:    []Skill{{Id: "s1", Name: "Slash", Effects: []string{"e1"}}},
+		Effects:   []Effect{{Id: "e1", Name: "Cut", Type: types.HitpointEffect, Power: 5}},
+		Spawners: []Spawner{{Id: "sp1", Name: "Guard", Area: "a1", Skills: []string{"s1"},
+			Equipment: []string{"t1"}, Rooms: []string{"r1"}, Schedule: types.SpawnAlways,
+			Loot: Loot{Drops: []LootDrop{{Template: "t1", Weight: 1}}}}},
+		Stores: []Store{{Id: "st1", Name: "Smithy", Room: "r2", Stock: []StockEntry{{Template: "t1", Quantity: 2}}}},
+	}
+}
+
+func Test_ReadWrite(t *testing.T) {
+	file := testFile()
+
+	var buf bytes.Buffer
+	if err := Write(&buf, file); err != nil {
+		t.Fatalf("Write() failed: %s", err)
+	}
+
+	read, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read() failed: %s", err)
+	}
+
+	if !reflect.DeepEqual(read, file) {
+		t.Errorf("Read() == %+v, want %+v", read, file)
+	}
+}
+
+func Test_ReadVersion(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"Version": 999, "Zone": "Town"}`))
+	if err == nil {
+		t.Errorf("Read() of an unknown version succeeded")
+	}
+}
+
+func Test_Validate(t *testing.T) {
+	if err := testFile().Validate(); err != nil {
+		t.Errorf("Validate() of a valid file failed: %s", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(*File)
+	}{
+		{"room area", func(f *File) { f.Rooms[0].Area = "missing" }},
+		{"link", func(f *File) { f.Rooms[0].Links["well"] = "missing" }},
+		{"skill effect", func(f *File) { f.Skills[0].Effects = []string{"missing"} }},
+		{"spawner skill", func(f *File) { f.Spawners[0].Skills = []string{"missing"} }},
+		{"loot", func(f *File) { f.Spawners[0].Loot.Drops[0].Template = "missing" }},
+		{"store room", func(f *File) { f.Stores[0].Room = "missing" }},
+		{"stock", func(f *File) { f.Stores[0].Stock[0].Template = "missing" }},
+		{"limit skill", func(f *File) { f.Rooms[0].Limits[0].Skill = "missing" }},
+		{"limit item", func(f *File) { f.Rooms[0].Limits[0].Template = "missing" }},
+		{"door key", func(f *File) { f.Rooms[1].Doors[0].Key = "missing" }},
+		{"room item", func(f *File) { f.Rooms[0].Items[0].Template = "missing" }},
+		{"item contents", func(f *File) { f.Rooms[0].Items[1].Contents[0] = "missing" }},
+		{"action target", func(f *File) { f.Rooms[0].Actions[0].Responses[1].Target = "missing" }},
+		{"location", func(f *File) { f.Rooms[1].Location = f.Rooms[0].Location }},
+		{"template name", func(f *File) { f.Templates = append(f.Templates, Template{Id: "t2", Name: "sword"}) }},
+		{"area name", func(f *File) { f.Areas = append(f.Areas, Area{Id: "a2", Name: "Market"}) }},
+		{"id", func(f *File) { f.Templates = append(f.Templates, Template{Id: "r1", Name: "Shield"}) }},
+	}
+
+	for _, test := range tests {
+		file := testFile()
+		test.corrupt(file)
+
+		if err := file.Validate(); err == nil {
+			t.Errorf("Validate() with a broken %s succeeded", test.name)
+		}
+	}
+}
+
+func Test_Path(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"town", filepath.Join(Dir, "town.json")},
+		{"town.json", filepath.Join(Dir, "town.json")},
+		{"../../etc/passwd", filepath.Join(Dir, "passwd.json")},
+	}
+
+	for _, test := range tests {
+		got := Path(test.name)
+		if got != test.want {
+			t.Errorf("Path(%q) == %q, want %q", test.name, got, test.want)
+		}
+	}
+}